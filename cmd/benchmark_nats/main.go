@@ -7,6 +7,7 @@ import (
 
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
 	"github.com/nats-io/nats.go"
+	"github.com/shopspring/decimal"
 )
 
 func main() {
@@ -34,7 +35,7 @@ func main() {
 				Qty:           1000,
 				CumQty:        1000,
 				LeavesQty:     1000,
-				Price:         1000,
+				Price:         decimal.NewFromInt(1000),
 				ExecID:        "ExecID",
 				LastExecID:    "LastExecID",
 				Timestamp:     now,
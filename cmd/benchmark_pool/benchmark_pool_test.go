@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
 )
 
 var orderPool = sync.Pool{
@@ -29,7 +30,7 @@ func BenchmarkNewOrder(b *testing.B) {
 			Side:         "Side",
 			Type:         "Type",
 			TimeInForce:  "TimeInForce",
-			Price:        1000,
+			Price:        decimal.NewFromInt(1000),
 			Quantity:     100,
 			Account:      "Account",
 			TransactTime: time.Now(),
@@ -52,7 +53,7 @@ func BenchmarkPoolOrder(b *testing.B) {
 		s.Side = "Side"
 		s.Type = "Type"
 		s.TimeInForce = "TimeInForce"
-		s.Price = 1000
+		s.Price = decimal.NewFromInt(1000)
 		s.Quantity = 100
 		s.Account = "Account"
 		s.TransactTime = time.Now()
@@ -69,7 +70,7 @@ func BenchmarkPoolOrder(b *testing.B) {
 		s.Side = ""
 		s.Type = ""
 		s.TimeInForce = ""
-		s.Price = 0
+		s.Price = decimal.Zero
 		s.Quantity = 0
 		s.Account = ""
 		s.TransactTime = time.Now()
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/boot"
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/shard"
+)
+
+// engine runs one matching engine shard, gateways reach it through
+// shard.Router (see cmd/oms -shards).
+func main() {
+	var addr, instrumentFile, riskFile, ledgerFile, positionsFile, accountsFile, auditFile, recoverFile string
+	var configWatch time.Duration
+	var pendingAcks bool
+	var node int64
+	flag.StringVar(&addr, "listen", "127.0.0.1:7001", "shard address, host:port or unix:/path")
+	flag.StringVar(&instrumentFile, "instruments", "./config/market_data.json", "instrument master file")
+	flag.StringVar(&riskFile, "risk", "./config/risk.yaml", "pre-trade risk chain config")
+	flag.StringVar(&ledgerFile, "ledger", "", "cash ledger config, empty disables cash checks")
+	flag.StringVar(&positionsFile, "positions", "", "account holdings config, empty disables holdings checks")
+	flag.StringVar(&accountsFile, "accounts", "", "app config of the account database the shards share cash and holdings in, required by -ledger and -positions")
+	flag.StringVar(&auditFile, "audit", "./audit.log", "operator audit log")
+	flag.StringVar(&recoverFile, "recover", "", "app config of the order event database to recover from, empty starts with empty books")
+	flag.DurationVar(&configWatch, "config-watch", 0, "reload -risk and -instruments when the files change, 0 reloads on request only")
+	flag.BoolVar(&pendingAcks, "pending-acks", true, "acknowledge requests PendingNew, PendingCancel and PendingReplace before the book result")
+	flag.Int64Var(&node, "node", -1, "ID node of this shard, unique across engines and OMS instances (0-1023), required")
+	flag.Parse()
+
+	if err := boot.SetNode(node, true); err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	instruments, err := instrument.NewStoreFromFile(instrumentFile)
+	if err != nil {
+		panic(err)
+	}
+
+	server := shard.NewServer(&shard.ServerConfig{Addr: addr})
+	startup := &boot.Config{
+		LedgerFile:    ledgerFile,
+		PositionsFile: positionsFile,
+		AuditFile:     auditFile,
+		PendingAcks:   pendingAcks,
+		Reload: &oms.ReloadConfig{
+			RiskFile:       riskFile,
+			InstrumentFile: instrumentFile,
+			WatchInterval:  configWatch,
+		},
+	}
+	// cash and holdings are shared by every shard, never a copy per shard
+	if ledgerFile != "" || positionsFile != "" {
+		if accountsFile == "" {
+			panic("-ledger and -positions need the shared account database of -accounts")
+		}
+		if startup.Accounts, err = boot.OpenRepo(accountsFile); err != nil {
+			panic(err)
+		}
+	}
+	if recoverFile != "" {
+		if startup.Events, err = boot.OpenRepo(recoverFile); err != nil {
+			panic(err)
+		}
+	}
+	engine, release, err := boot.Start(ctx, server, instruments, startup)
+	if err != nil {
+		panic(err)
+	}
+	defer release()
+	server.AddOmsInstance(engine)
+	if err := server.Start(ctx); err != nil {
+		panic(err)
+	}
+	fmt.Printf("engine shard listening on %s\n", server.Addr())
+
+	<-sigs
+	fmt.Println("Shutting down...")
+	cancel()
+	engine.Stop()
+}
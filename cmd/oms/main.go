@@ -2,19 +2,45 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/boot"
 	fixgateway "github.com/joripage/orderbook-dev/pkg/oms/fix"
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/shard"
 )
 
 func main() {
+	var shards, ledgerFile, positionsFile, auditFile, recoverFile, adminAddr string
+	var configWatch time.Duration
+	var pendingAcks bool
+	var node int64
+	flag.StringVar(&shards, "shards", "", "comma separated engine shards (cmd/engine), empty runs the engine in process")
+	flag.StringVar(&ledgerFile, "ledger", "", "cash ledger config, empty disables cash checks")
+	flag.StringVar(&positionsFile, "positions", "", "account holdings config, empty disables holdings checks")
+	flag.StringVar(&auditFile, "audit", "./audit.log", "operator audit log, engines keep their own with -shards")
+	flag.StringVar(&recoverFile, "recover", "", "app config of the order event database to recover from, empty starts with empty books")
+	flag.DurationVar(&configWatch, "config-watch", 0, "reload risk and market config when the files change, 0 reloads on /admin/config/reload only")
+	flag.BoolVar(&pendingAcks, "pending-acks", true, "acknowledge requests PendingNew, PendingCancel and PendingReplace before the book result")
+	flag.StringVar(&adminAddr, "admin", "localhost:6061", "admin endpoint address, requests carry the bearer token of $ADMIN_TOKEN")
+	flag.Int64Var(&node, "node", -1, "ID node of this instance, unique across OMS instances and engines (0-1023), required with -shards")
+	flag.Parse()
+
+	if err := boot.SetNode(node, shards != ""); err != nil {
+		panic(err)
+	}
+
 	go func() {
 		http.ListenAndServe("localhost:6060", nil)
 	}()
@@ -27,12 +53,72 @@ func main() {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	throttle, err := fixgateway.LoadThrottleConfig("./config/throttle.yaml")
+	if err != nil {
+		panic(err)
+	}
+	instruments, err := instrument.NewStoreFromFile("./config/market_data.json")
+	if err != nil {
+		panic(err)
+	}
 	fixGateway := fixgateway.NewFixGateway(&fixgateway.FixGatewayConfig{
 		ConfigFilepath: "./config/fixserver.cfg",
+		Throttle:       throttle,
+		Prices:         instruments,
+	})
+	// kill switches and config reloads change trading, they are served on
+	// their own listener behind a token, never next to pprof
+	admin := http.NewServeMux()
+	admin.Handle("/admin/kill-switch", oms.KillSwitchHandler(fixGateway))
+
+	// operators read the throttle counters next to pprof
+	http.HandleFunc("/debug/throttle", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(fixGateway.ThrottleStats())
 	})
-	oms := oms.NewOMS(fixGateway)
-	fixGateway.AddOmsInstance(oms)
-	oms.Start(ctx)
+
+	if shards != "" {
+		router := shard.NewRouter(&shard.RouterConfig{
+			Shards:      strings.Split(shards, ","),
+			DialTimeout: 10 * time.Second,
+		}, fixGateway)
+		fixGateway.AddOmsInstance(router)
+		admin.Handle("/admin/config/reload", oms.ConfigReloadHandler(router))
+		if err := router.Start(ctx); err != nil {
+			panic(err)
+		}
+	} else {
+		startup := &boot.Config{
+			LedgerFile:    ledgerFile,
+			PositionsFile: positionsFile,
+			AuditFile:     auditFile,
+			PendingAcks:   pendingAcks,
+			Reload: &oms.ReloadConfig{
+				RiskFile:       "./config/risk.yaml",
+				InstrumentFile: "./config/market_data.json",
+				WatchInterval:  configWatch,
+			},
+		}
+		if recoverFile != "" {
+			if startup.Events, err = boot.OpenRepo(recoverFile); err != nil {
+				panic(err)
+			}
+		}
+		// balance and position files belong to this one process
+		engine, release, err := boot.Start(ctx, fixGateway, instruments, startup)
+		if err != nil {
+			panic(err)
+		}
+		defer release()
+		fixGateway.AddOmsInstance(engine)
+		admin.Handle("/admin/config/reload", oms.ConfigReloadHandler(engine))
+		engine.Start(ctx)
+	}
+	go func() {
+		if err := http.ListenAndServe(adminAddr, oms.RequireToken(os.Getenv("ADMIN_TOKEN"), admin)); err != nil {
+			fmt.Printf("admin listener err=%v\n", err)
+		}
+	}()
 	fmt.Println("FIX client started. Press Ctrl+C to exit.")
 
 	// chờ signal
@@ -66,6 +66,7 @@ func main() {
 	w := worker.NewWorker(sqlRepo)
 	// go w.StartConsumer(ctx, js, "ORDERS.events", "order_worker")
 	go w.StartConsumerKafka(ctx, "ORDERS.events", "order_worker")
+	go w.StartConfigConsumerKafka(ctx)
 
 	// Add test order
 	// store.AddOrder(ctx, &oms.Order{
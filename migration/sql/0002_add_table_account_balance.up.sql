@@ -0,0 +1,6 @@
+CREATE TABLE
+    IF NOT EXISTS account_balances (
+        account TEXT PRIMARY KEY,
+        cash DECIMAL NOT NULL DEFAULT 0,
+        "updated_at" timestamptz
+    );
@@ -0,0 +1,8 @@
+CREATE TABLE
+    IF NOT EXISTS account_positions (
+        account TEXT NOT NULL,
+        symbol TEXT NOT NULL,
+        quantity BIGINT NOT NULL DEFAULT 0,
+        "updated_at" timestamptz,
+        PRIMARY KEY (account, symbol)
+    );
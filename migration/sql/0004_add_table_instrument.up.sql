@@ -0,0 +1,19 @@
+CREATE TABLE
+    IF NOT EXISTS instruments (
+        symbol TEXT PRIMARY KEY,
+        exchange TEXT NOT NULL,
+        stock_type TEXT,
+        isin TEXT,
+        name TEXT,
+        board_lot BIGINT NOT NULL DEFAULT 1,
+        ceil DECIMAL NOT NULL DEFAULT 0,
+        ref DECIMAL NOT NULL DEFAULT 0,
+        floor DECIMAL NOT NULL DEFAULT 0,
+        trading_session_id TEXT,
+        prior_close_price DECIMAL NOT NULL DEFAULT 0,
+        is_suspended BOOLEAN NOT NULL DEFAULT FALSE,
+        halt_state TEXT,
+        market_halt_state TEXT,
+        security_status TEXT,
+        "updated_at" timestamptz
+    );
@@ -0,0 +1,2 @@
+ALTER TABLE order_events
+    ADD COLUMN IF NOT EXISTS config_version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE order_events
+    DROP COLUMN IF EXISTS last_qty,
+    DROP COLUMN IF EXISTS last_price,
+    DROP COLUMN IF EXISTS avg_price;
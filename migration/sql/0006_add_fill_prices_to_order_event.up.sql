@@ -0,0 +1,4 @@
+ALTER TABLE order_events
+    ADD COLUMN IF NOT EXISTS last_qty BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_price DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS avg_price DECIMAL NOT NULL DEFAULT 0;
@@ -0,0 +1,19 @@
+ALTER TABLE order_events
+    DROP COLUMN IF EXISTS symbol,
+    DROP COLUMN IF EXISTS security_id,
+    DROP COLUMN IF EXISTS exchange,
+    DROP COLUMN IF EXISTS side,
+    DROP COLUMN IF EXISTS type,
+    DROP COLUMN IF EXISTS time_in_force,
+    DROP COLUMN IF EXISTS stop_price,
+    DROP COLUMN IF EXISTS peg_type,
+    DROP COLUMN IF EXISTS peg_offset,
+    DROP COLUMN IF EXISTS hidden,
+    DROP COLUMN IF EXISTS short_sell,
+    DROP COLUMN IF EXISTS account,
+    DROP COLUMN IF EXISTS transact_time,
+    DROP COLUMN IF EXISTS board,
+    DROP COLUMN IF EXISTS session_id,
+    DROP COLUMN IF EXISTS list_id,
+    DROP COLUMN IF EXISTS quote_id,
+    DROP COLUMN IF EXISTS cum_value;
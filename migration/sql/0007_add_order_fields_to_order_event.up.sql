@@ -0,0 +1,19 @@
+ALTER TABLE order_events
+    ADD COLUMN IF NOT EXISTS symbol TEXT,
+    ADD COLUMN IF NOT EXISTS security_id TEXT,
+    ADD COLUMN IF NOT EXISTS exchange TEXT,
+    ADD COLUMN IF NOT EXISTS side TEXT,
+    ADD COLUMN IF NOT EXISTS type TEXT,
+    ADD COLUMN IF NOT EXISTS time_in_force TEXT,
+    ADD COLUMN IF NOT EXISTS stop_price DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS peg_type TEXT,
+    ADD COLUMN IF NOT EXISTS peg_offset DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS short_sell BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS account TEXT,
+    ADD COLUMN IF NOT EXISTS transact_time TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS board TEXT,
+    ADD COLUMN IF NOT EXISTS session_id TEXT,
+    ADD COLUMN IF NOT EXISTS list_id TEXT,
+    ADD COLUMN IF NOT EXISTS quote_id TEXT,
+    ADD COLUMN IF NOT EXISTS cum_value DECIMAL NOT NULL DEFAULT 0;
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS orders_symbol_idx;
+DROP INDEX IF EXISTS orders_account_idx;
+DROP INDEX IF EXISTS orders_status_idx;
+DROP INDEX IF EXISTS orders_order_id_idx;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS stop_price,
+    DROP COLUMN IF EXISTS board,
+    DROP COLUMN IF EXISTS session_id,
+    DROP COLUMN IF EXISTS list_id,
+    DROP COLUMN IF EXISTS quote_id,
+    DROP COLUMN IF EXISTS seq;
+
+ALTER TABLE order_events
+    DROP COLUMN IF EXISTS seq;
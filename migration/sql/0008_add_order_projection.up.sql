@@ -0,0 +1,15 @@
+ALTER TABLE order_events
+    ADD COLUMN IF NOT EXISTS seq BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS stop_price DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS board TEXT,
+    ADD COLUMN IF NOT EXISTS session_id TEXT,
+    ADD COLUMN IF NOT EXISTS list_id TEXT,
+    ADD COLUMN IF NOT EXISTS quote_id TEXT,
+    ADD COLUMN IF NOT EXISTS seq BIGINT NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS orders_order_id_idx ON orders (order_id);
+CREATE INDEX IF NOT EXISTS orders_status_idx ON orders (status);
+CREATE INDEX IF NOT EXISTS orders_account_idx ON orders (account);
+CREATE INDEX IF NOT EXISTS orders_symbol_idx ON orders (symbol);
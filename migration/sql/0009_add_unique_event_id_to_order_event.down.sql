@@ -0,0 +1 @@
+DROP INDEX IF EXISTS order_events_event_id_idx;
@@ -0,0 +1,13 @@
+-- a redelivered event was stored again with the ExecID of the first copy
+DELETE FROM order_events a
+    USING order_events b
+    WHERE a.id > b.id
+        AND a.event_id = b.event_id
+        AND a.exec_id IS NOT DISTINCT FROM b.exec_id;
+
+-- older event IDs repeat for repeated statuses of an order: name events
+-- with a seq as new ones are, and keep those stored before seq apart by row
+UPDATE order_events SET event_id = order_id || '-' || seq WHERE seq > 0;
+UPDATE order_events SET event_id = event_id || '-' || id WHERE seq = 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS order_events_event_id_idx ON order_events (event_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS account_position_locks;
+DROP TABLE IF EXISTS account_reservations;
@@ -0,0 +1,18 @@
+-- cash reserved and holdings locked by the open orders of each engine node,
+-- a node clears its rows at startup and rebuilds them from its live orders
+CREATE TABLE
+    IF NOT EXISTS account_reservations (
+        account TEXT NOT NULL,
+        node BIGINT NOT NULL,
+        reserved DECIMAL NOT NULL DEFAULT 0,
+        PRIMARY KEY (account, node)
+    );
+
+CREATE TABLE
+    IF NOT EXISTS account_position_locks (
+        account TEXT NOT NULL,
+        symbol TEXT NOT NULL,
+        node BIGINT NOT NULL,
+        locked BIGINT NOT NULL DEFAULT 0,
+        PRIMARY KEY (account, symbol, node)
+    );
@@ -0,0 +1,12 @@
+-- risk and market config in force, one row per version of each node, with
+-- the content of every file read so a restart puts it back in force
+CREATE TABLE
+    IF NOT EXISTS config_events (
+        node BIGINT NOT NULL,
+        version BIGINT NOT NULL,
+        operator TEXT NOT NULL DEFAULT '',
+        digest TEXT NOT NULL,
+        files JSONB NOT NULL,
+        timestamp TIMESTAMPTZ NOT NULL,
+        PRIMARY KEY (node, version)
+    );
@@ -0,0 +1,2 @@
+ALTER TABLE order_events
+    ALTER COLUMN peg_offset TYPE DOUBLE PRECISION;
@@ -36,6 +36,9 @@ type ProducerConfig struct {
 	BatchBytes   int64
 	BatchTimeout time.Duration
 	RequiredAcks kafka.RequiredAcks
+	// Sync makes Publish wait for the write, and the acks of RequiredAcks,
+	// instead of queueing the message.
+	Sync bool
 }
 
 type Producer struct {
@@ -62,8 +65,8 @@ func NewProducer(cfg ProducerConfig) *Producer {
 		BatchBytes:             cfg.BatchBytes,
 		BatchTimeout:           cfg.BatchTimeout,
 		AllowAutoTopicCreation: true,
-		RequiredAcks:           kafka.RequireNone,
-		Async:                  true,
+		RequiredAcks:           cfg.RequiredAcks,
+		Async:                  !cfg.Sync,
 	}
 	return &Producer{w: wr}
 }
@@ -123,6 +126,42 @@ type ConsumerGroup struct {
 
 var ErrSkipCommit = errors.New("skip commit")
 
+// retryableError marks a failure that goes away on its own, a database down.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Retryable marks err as transient: Run retries the batch with backoff until
+// it succeeds, it is never sent to the DLQ.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+func IsRetryable(err error) bool {
+	var r *retryableError
+	return errors.As(err, &r)
+}
+
+// DeadLetterError is returned by a handler that processed its batch except
+// Messages, which can never be: Run sends them to the DLQ and commits the
+// batch.
+type DeadLetterError struct {
+	Messages []Message
+	Err      error
+}
+
+func (e *DeadLetterError) Error() string {
+	return fmt.Sprintf("%d dead letters: %v", len(e.Messages), e.Err)
+}
+
+func (e *DeadLetterError) Unwrap() error { return e.Err }
+
 func NewConsumerGroup(cfg ConsumerConfig) (*ConsumerGroup, error) {
 	if cfg.WorkerCount <= 0 {
 		cfg.WorkerCount = 4
@@ -157,9 +196,14 @@ func NewConsumerGroup(cfg ConsumerConfig) (*ConsumerGroup, error) {
 		MaxBytes:    10 << 20,
 	})
 
+	// a message is committed once in the DLQ, the write must be acked first
 	var prod *Producer
 	if cfg.DLQTopic != "" {
-		prod = NewProducer(ProducerConfig{Brokers: cfg.Brokers})
+		prod = NewProducer(ProducerConfig{
+			Brokers:      cfg.Brokers,
+			RequiredAcks: kafka.RequireAll,
+			Sync:         true,
+		})
 	}
 
 	return &ConsumerGroup{r: rd, cfg: cfg, prodForDLQ: prod}, nil
@@ -243,33 +287,8 @@ func (cg *ConsumerGroup) Run(ctx context.Context, handler func(context.Context,
 				for i, m := range ms {
 					wrapped[i] = wrapMessage(m)
 				}
-				var attempt int
-				for {
-					err := handler(ctx, wrapped)
-					if err == nil {
-						if cg.cfg.AutoCommit {
-							_ = cg.r.CommitMessages(ctx, ms...)
-						}
-						break
-					}
-					attempt++
-					if attempt > cg.cfg.MaxRetries {
-						if cg.cfg.DLQTopic != "" && cg.prodForDLQ != nil {
-							for _, m := range ms {
-								_ = cg.prodForDLQ.Publish(ctx, cg.cfg.DLQTopic, m.Key, m.Value, headersToMap(m.Headers))
-							}
-						}
-						if cg.cfg.AutoCommit {
-							_ = cg.r.CommitMessages(ctx, ms...)
-						}
-						break
-					}
-					backoff := backoffDuration(cg.cfg.BackoffMin, cg.cfg.BackoffMax, attempt)
-					select {
-					case <-time.After(backoff):
-					case <-ctx.Done():
-						return
-					}
+				if !cg.process(ctx, handler, ms, wrapped) {
+					return
 				}
 			}
 			done <- struct{}{}
@@ -292,6 +311,87 @@ func (cg *ConsumerGroup) Run(ctx context.Context, handler func(context.Context,
 	}
 }
 
+// process runs handler on a batch until it is done with: handled, or its
+// dead letters are in the DLQ. The batch is only committed once its dead
+// letters are in the DLQ. It returns false when ctx ends first.
+func (cg *ConsumerGroup) process(ctx context.Context, handler func(context.Context, []Message) error, ms []kafka.Message, wrapped []Message) bool {
+	dead, ok := cg.handle(ctx, handler, wrapped, cg.cfg.MaxRetries)
+	for attempt := 1; ok; attempt++ {
+		if cg.deadLetter(ctx, dead) {
+			if cg.cfg.AutoCommit {
+				_ = cg.r.CommitMessages(ctx, ms...)
+			}
+			return true
+		}
+		ok = cg.wait(ctx, attempt)
+	}
+	return false
+}
+
+// handle runs handler on msgs and returns the ones to send to the DLQ. A
+// retryable error is retried without limit, any other error up to retries
+// times. A batch that still fails is handled one message at a time, only the
+// messages that fail on their own are dead letters. It returns false when ctx
+// ends first.
+func (cg *ConsumerGroup) handle(ctx context.Context, handler func(context.Context, []Message) error, msgs []Message, retries int) ([]Message, bool) {
+	var attempt int
+	for {
+		err := handler(ctx, msgs)
+		var dl *DeadLetterError
+		switch {
+		case err == nil:
+			return nil, true
+		case errors.As(err, &dl):
+			return dl.Messages, true
+		case IsRetryable(err):
+		case attempt < retries:
+		case len(msgs) == 1:
+			return msgs, true
+		default:
+			// the batch already used its retries, a message failing on its
+			// own is dead at once
+			var dead []Message
+			for i := range msgs {
+				d, ok := cg.handle(ctx, handler, msgs[i:i+1], 0)
+				if !ok {
+					return nil, false
+				}
+				dead = append(dead, d...)
+			}
+			return dead, true
+		}
+
+		attempt++
+		if !cg.wait(ctx, attempt) {
+			return nil, false
+		}
+	}
+}
+
+// wait sleeps the backoff of attempt, false when ctx ends first.
+func (cg *ConsumerGroup) wait(ctx context.Context, attempt int) bool {
+	select {
+	case <-time.After(backoffDuration(cg.cfg.BackoffMin, cg.cfg.BackoffMax, attempt)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// deadLetter publishes msgs to the DLQ, false when a write failed. Without a
+// DLQ topic dead letters are dropped.
+func (cg *ConsumerGroup) deadLetter(ctx context.Context, msgs []Message) bool {
+	if cg.cfg.DLQTopic == "" || cg.prodForDLQ == nil {
+		return true
+	}
+	for _, m := range msgs {
+		if err := cg.prodForDLQ.Publish(ctx, cg.cfg.DLQTopic, m.Key, m.Value, m.Headers); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
 func wrapMessage(m kafka.Message) Message {
 	headers := map[string]string{}
 	for _, h := range m.Headers {
@@ -309,14 +409,6 @@ func wrapMessage(m kafka.Message) Message {
 	}
 }
 
-func headersToMap(hs []kafka.Header) map[string]string {
-	out := map[string]string{}
-	for _, h := range hs {
-		out[h.Key] = string(h.Value)
-	}
-	return out
-}
-
 func backoffDuration(min, max time.Duration, attempt int) time.Duration {
 	if attempt < 1 {
 		attempt = 1
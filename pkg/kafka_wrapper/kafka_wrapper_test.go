@@ -0,0 +1,34 @@
+package kafkawrapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHandleDeadLettersOnlyFailingMessages(t *testing.T) {
+	cg := &ConsumerGroup{cfg: ConsumerConfig{MaxRetries: 2, BackoffMin: time.Millisecond, BackoffMax: time.Millisecond}}
+	msgs := []Message{{Offset: 1}, {Offset: 2}, {Offset: 3}}
+
+	var calls int
+	dead, ok := cg.handle(context.Background(), func(_ context.Context, batch []Message) error {
+		calls++
+		for _, m := range batch {
+			if m.Offset == 2 {
+				return errors.New("bad row")
+			}
+		}
+		return nil
+	}, msgs, cg.cfg.MaxRetries)
+	if !ok {
+		t.Fatal("expected the batch to be handled")
+	}
+	if len(dead) != 1 || dead[0].Offset != 2 {
+		t.Fatalf("expected only offset 2 dead, got %+v", dead)
+	}
+	// the batch with its retries, then each message once
+	if calls != 3+3 {
+		t.Fatalf("expected 6 handler calls, got %d", calls)
+	}
+}
@@ -0,0 +1,52 @@
+package misc
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadYAML reads the YAML file path into cfg, environment variables in the
+// file are expanded.
+func LoadYAML(path string, cfg any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return ParseYAML(data, cfg)
+}
+
+// ParseYAML reads the content of a YAML file into cfg like LoadYAML.
+func ParseYAML(data []byte, cfg any) error {
+	return yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), cfg)
+}
+
+// ReadJSONFile reads the JSON file path into v, a missing file leaves v
+// as it is.
+func ReadJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteJSONFile replaces the JSON file path with v, written to a temporary
+// file then renamed so a crash never leaves a partial file.
+func WriteJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,98 @@
+package oms
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+// KillSwitchAdmin engages and releases kill switches, an OMS or a gateway
+// that runs them in turn with its order requests.
+type KillSwitchAdmin interface {
+	EngageKillSwitch(ctx context.Context, ks *model.KillSwitch) error
+	ReleaseKillSwitch(ctx context.Context, ks *model.KillSwitch) error
+	KillSwitches(ctx context.Context) ([]model.KillSwitch, error)
+}
+
+// KillSwitchHandler serves the kill switch admin endpoint:
+//   - GET    lists the engaged switches
+//   - POST   engages the switch in the JSON body
+//   - DELETE releases the switch of the scope and value in the JSON body
+//
+// The operator of a request defaults to the X-Operator header.
+func KillSwitchHandler(o KillSwitchAdmin) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			switches, err := o.KillSwitches(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(switches)
+			return
+		}
+
+		ks := &model.KillSwitch{}
+		if err := json.NewDecoder(r.Body).Decode(ks); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ks.Operator == "" {
+			ks.Operator = r.Header.Get("X-Operator")
+		}
+
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			err = o.EngageKillSwitch(r.Context(), ks)
+		case http.MethodDelete:
+			err = o.ReleaseKillSwitch(r.Context(), ks)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ConfigReloadHandler serves the config reload admin endpoint, a POST
+// reloads the risk and market config files and answers the version in
+// force. The operator is the X-Operator header.
+func ConfigReloadHandler(o IOMS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		version, err := o.ReloadConfig(r.Context(), r.Header.Get("X-Operator"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]int64{"version": version})
+	})
+}
+
+// RequireToken serves h to requests carrying "Authorization: Bearer <token>"
+// only, an empty token refuses every request.
+func RequireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
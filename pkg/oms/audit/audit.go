@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one operator action, written as a JSON line.
+type Entry struct {
+	Time   time.Time   `json:"time"`
+	Actor  string      `json:"actor"`
+	Action string      `json:"action"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// Recorded is an entry read back from a log file, Detail is left as JSON.
+type Recorded struct {
+	Time   time.Time       `json:"time"`
+	Actor  string          `json:"actor"`
+	Action string          `json:"action"`
+	Detail json.RawMessage `json:"detail,omitempty"`
+}
+
+// Log appends entries to a writer, a nil Log records nothing.
+type Log struct {
+	mu   sync.Mutex
+	w    io.Writer
+	c    io.Closer
+	path string
+}
+
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// NewFileLog appends to the file at path, creating it when missing.
+func NewFileLog(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{w: f, c: f, path: path}, nil
+}
+
+// Entries reads back the entries of a file log, oldest first. A log that is
+// not a file has none.
+func (l *Log) Entries() ([]Recorded, error) {
+	if l == nil || l.path == "" {
+		return nil, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Recorded
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Recorded
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Record writes an entry, a failed write is logged and never blocks the
+// action it records.
+func (l *Log) Record(actor, action string, detail interface{}) {
+	if l == nil {
+		return
+	}
+
+	data, err := json.Marshal(Entry{
+		Time:   time.Now(),
+		Actor:  actor,
+		Action: action,
+		Detail: detail,
+	})
+	if err != nil {
+		log.Printf("audit marshal action=%s err=%v", action, err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		log.Printf("audit write action=%s err=%v", action, err)
+	}
+}
+
+func (l *Log) Close() error {
+	if l == nil || l.c == nil {
+		return nil
+	}
+	return l.c.Close()
+}
@@ -0,0 +1,42 @@
+package oms
+
+import (
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/orderbook"
+)
+
+// resolveBoard validates qty against the board lot of symbol and returns the
+// board the order trades on:
+//   - multiple of board lot -> main board
+//   - less than board lot   -> odd-lot board, limit orders resting in the book only
+//   - otherwise (mixed lot) -> rejected
+func (s *OMS) resolveBoard(symbol string, qty int64, orderType model.OrderType, timeInForce model.OrderTimeInForce) (model.OrderBoard, error) {
+	if s.instruments == nil {
+		return model.OrderBoardMain, nil
+	}
+	instrument, ok := s.instruments.Get(symbol)
+	if !ok || instrument.BoardLot <= 1 {
+		return model.OrderBoardMain, nil
+	}
+
+	if qty%instrument.BoardLot == 0 {
+		return model.OrderBoardMain, nil
+	}
+	if qty > instrument.BoardLot {
+		return "", errInvalidBoardLot
+	}
+
+	if orderType != model.OrderTypeLimit ||
+		timeInForce == model.OrderTimeInForceIOC || timeInForce == model.OrderTimeInForceFOK {
+		return "", errInvalidOddLotOrder
+	}
+	return model.OrderBoardOddLot, nil
+}
+
+// bookManager returns the books of the board the order trades on.
+func (s *OMS) bookManager(order *model.Order) *orderbook.OrderBookManager {
+	if order.Board == model.OrderBoardOddLot {
+		return s.oddLotManager
+	}
+	return s.orderbookManager
+}
@@ -0,0 +1,39 @@
+package oms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+func TestBoardLotRouting(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{
+		Instruments: instrument.NewStore([]*instrument.Instrument{
+			{Symbol: "TEST", Exchange: "HOSE", BoardLot: 100},
+		}),
+	})
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 150)); err != errInvalidBoardLot {
+		t.Fatalf("expected mixed lot rejected, got %v", err)
+	}
+	if err := s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeMarket, 100, 50)); err != errInvalidOddLotOrder {
+		t.Fatalf("expected odd-lot market order rejected, got %v", err)
+	}
+
+	// odd-lot and round-lot orders never meet
+	s.AddOrder(ctx, newAddOrder("B3", model.OrderSideBuy, model.OrderTypeLimit, 100, 50))
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 100))
+	if r := gw.lastReport("B3"); r.Board != model.OrderBoardOddLot || r.Status != model.OrderStatusNew {
+		t.Fatalf("expected odd-lot order resting, got %+v", r)
+	}
+
+	s.AddOrder(ctx, newAddOrder("S2", model.OrderSideSell, model.OrderTypeLimit, 100, 30))
+	if r := gw.lastReport("B3"); r.Status != model.OrderStatusPartiallyFilled || r.CumQuantity != 30 {
+		t.Fatalf("expected odd-lot match of 30, got %+v", r)
+	}
+}
@@ -0,0 +1,171 @@
+// Package boot holds the startup shared by the processes running an OMS,
+// cmd/oms in process and the engine shards of cmd/engine.
+package boot
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joripage/orderbook-dev/config"
+	postgres_wrapper "github.com/joripage/orderbook-dev/pkg/infra/postgres"
+	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/audit"
+	"github.com/joripage/orderbook-dev/pkg/oms/idgen"
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/ledger"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
+	"github.com/joripage/orderbook-dev/pkg/oms/repo"
+)
+
+var errNodeRequired = errors.New("-node is required when running sharded, unique across engines and OMS instances")
+
+// Config lists what an OMS loads before it takes requests.
+type Config struct {
+	// LedgerFile and PositionsFile enable the cash and holdings checks,
+	// empty disables them.
+	LedgerFile    string
+	PositionsFile string
+	// Accounts keeps the cash and holdings every engine shard shares, nil
+	// keeps them in the files named by the ledger and positions configs,
+	// for a single process.
+	Accounts  repo.IRepo
+	AuditFile string
+	// Events recovers the orders and the config of this node, nil starts
+	// with empty books.
+	Events      repo.IRepo
+	PendingAcks bool
+	Reload      *oms.ReloadConfig
+}
+
+// SetNode sets the ID node of this process. Snowflake IDs collide when two
+// processes share a node, so a negative (unset) node is refused when
+// sharded and means 0 for a single process.
+func SetNode(node int64, sharded bool) error {
+	if node < 0 {
+		if sharded {
+			return errNodeRequired
+		}
+		node = 0
+	}
+
+	ids, err := idgen.NewGenerator(&idgen.Config{Node: node})
+	if err != nil {
+		return err
+	}
+	model.SetIDGenerator(ids)
+	return nil
+}
+
+// OpenRepo connects to the OMS database of the app config file path.
+func OpenRepo(path string) (repo.IRepo, error) {
+	appCfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := postgres_wrapper.InitPostgres(appCfg.OmsDB)
+	if err != nil {
+		return nil, err
+	}
+	return repo.NewRepo(db), nil
+}
+
+// Start builds the OMS of this node, loads its config and recovers its
+// orders and kill switches. The OMS is ready to take requests from gateway,
+// the returned func releases what Start opened once it is stopped.
+func Start(ctx context.Context, gateway oms.OrderGateway, instruments *instrument.Store, cfg *Config) (*oms.OMS, func(), error) {
+	cash, err := newLedger(ctx, cfg, instruments)
+	if err != nil {
+		return nil, nil, err
+	}
+	positions, err := newPositions(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	auditLog, err := audit.NewFileLog(cfg.AuditFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() { auditLog.Close() }
+
+	// the risk chain is built by the first config reload
+	engine := oms.NewOMS(gateway, &oms.OMSConfig{
+		Instruments: instruments,
+		Ledger:      cash,
+		Positions:   positions,
+		Audit:       auditLog,
+		PendingAcks: cfg.PendingAcks,
+		Reload:      cfg.Reload,
+	})
+	if err := recoverEngine(ctx, engine, cfg.Events); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return engine, release, nil
+}
+
+// recoverEngine puts the config of the previous run back in force, then
+// rebuilds the orders of this node and its kill switches.
+func recoverEngine(ctx context.Context, engine *oms.OMS, events repo.IRepo) error {
+	// the startup config keeps the version of the previous run when unchanged
+	if events != nil {
+		if err := engine.ResumeConfig(ctx, events.ConfigEvent()); err != nil {
+			return err
+		}
+	}
+	if _, err := engine.ReloadConfig(ctx, "startup"); err != nil {
+		return err
+	}
+	engine.WatchConfig(ctx)
+
+	if events != nil {
+		return engine.Recover(ctx, events.OrderEvent())
+	}
+	return engine.RestoreKillSwitches(ctx)
+}
+
+func newLedger(ctx context.Context, cfg *Config, instruments *instrument.Store) (*ledger.Ledger, error) {
+	if cfg.LedgerFile == "" {
+		return nil, nil
+	}
+	ledgerCfg, err := ledger.LoadConfig(cfg.LedgerFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var store ledger.Store
+	if cfg.Accounts != nil {
+		// the live orders of this node reserve again when they are recovered
+		shared := cfg.Accounts.AccountBalance(model.Node())
+		if err := shared.ResetReservations(ctx); err != nil {
+			return nil, err
+		}
+		store = shared
+	} else if store, err = ledger.NewFileStore(ledgerCfg.BalanceFile); err != nil {
+		return nil, err
+	}
+	return ledger.NewLedger(ctx, ledgerCfg, store, instruments)
+}
+
+func newPositions(ctx context.Context, cfg *Config) (*position.Positions, error) {
+	if cfg.PositionsFile == "" {
+		return nil, nil
+	}
+	positionsCfg, err := position.LoadConfig(cfg.PositionsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var store position.Store
+	if cfg.Accounts != nil {
+		// the live orders of this node lock again when they are recovered
+		shared := cfg.Accounts.AccountPosition(model.Node())
+		if err := shared.ResetLocks(ctx); err != nil {
+			return nil, err
+		}
+		store = shared
+	} else if store, err = position.NewFileStore(positionsCfg.PositionFile); err != nil {
+		return nil, err
+	}
+	return position.NewPositions(ctx, positionsCfg, store)
+}
@@ -0,0 +1,78 @@
+package boot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func TestSetNodeRequiredWhenSharded(t *testing.T) {
+	if err := SetNode(-1, true); err != errNodeRequired {
+		t.Fatalf("expected the node required, got %v", err)
+	}
+	if err := SetNode(-1, false); err != nil || model.Node() != 0 {
+		t.Fatalf("expected node 0 for a single process, got %d err=%v", model.Node(), err)
+	}
+	if err := SetNode(7, true); err != nil || model.Node() != 7 {
+		t.Fatalf("expected node 7, got %d err=%v", model.Node(), err)
+	}
+}
+
+type nopGateway struct{}
+
+func (nopGateway) Start(ctx context.Context) error                        { return nil }
+func (nopGateway) OnOrderReport(ctx context.Context, args ...interface{}) {}
+
+func TestStartLoadsFileStores(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("balances.json", `[{"account": "A1", "cash": "1000"}]`)
+	cfg := &Config{
+		LedgerFile:    write("ledger.yaml", "balance_file: "+filepath.Join(dir, "balances.json")),
+		PositionsFile: write("positions.yaml", "position_file: "+filepath.Join(dir, "positions.json")),
+		AuditFile:     filepath.Join(dir, "audit.log"),
+		Reload: &oms.ReloadConfig{
+			RiskFile:       write("risk.yaml", "rules: []"),
+			InstrumentFile: write("market_data.json", `[{"symbol": "TEST", "exchange": "HOSE"}]`),
+		},
+	}
+	if err := SetNode(-1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, release, err := Start(context.Background(), nopGateway{}, instrument.NewStore(nil), cfg)
+	if err != nil {
+		t.Fatalf("start err=%v", err)
+	}
+	defer release()
+	defer engine.Stop()
+
+	if v := engine.ConfigVersion(); v != 1 {
+		t.Fatalf("expected the startup config at version 1, got %d", v)
+	}
+	// A1 holds the 1000 of the balance file
+	buy := func(id string, price int64) error {
+		return engine.AddOrder(context.Background(), &model.AddOrder{
+			GatewayID: id, Account: "A1", Symbol: "TEST", Side: model.OrderSideBuy, Type: model.OrderTypeLimit,
+			Price: decimal.NewFromInt(price), Quantity: decimal.NewFromInt(10),
+		})
+	}
+	if err := buy("B1", 200); err == nil {
+		t.Fatal("expected a buy above the cash of A1 to be rejected")
+	}
+	if err := buy("B2", 100); err != nil {
+		t.Fatalf("expected a buy within the cash of A1, got %v", err)
+	}
+}
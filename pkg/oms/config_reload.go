@@ -0,0 +1,266 @@
+package oms
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
+)
+
+// ReloadConfig names the files ReloadConfig reads.
+type ReloadConfig struct {
+	// RiskFile is the risk chain config, it names the tick size and limit
+	// price files.
+	RiskFile string
+	// InstrumentFile is the instrument master, empty keeps the one given in
+	// OMSConfig.
+	InstrumentFile string
+	// WatchInterval is how often WatchConfig checks the files, zero reloads
+	// on ReloadConfig only.
+	WatchInterval time.Duration
+}
+
+// configFiles reads the config files once and keeps their content, which is
+// what a ConfigEvent records.
+type configFiles struct {
+	read  func(path string) ([]byte, error)
+	files map[string][]byte
+}
+
+func (f *configFiles) ReadFile(path string) ([]byte, error) {
+	if data, ok := f.files[path]; ok {
+		return data, nil
+	}
+	data, err := f.read(path)
+	if err != nil {
+		return nil, err
+	}
+	f.files[path] = data
+	return data, nil
+}
+
+func (f *configFiles) digest() string {
+	paths := make([]string, 0, len(f.files))
+	for path := range f.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		h.Write(f.files[path])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ReloadConfig reads the config files, validates them and swaps the risk
+// rules and the instrument master in one step. Nothing changes when a file
+// is invalid. A new version is recorded in the event log when the content
+// changed, the version in force is returned.
+func (s *OMS) ReloadConfig(ctx context.Context, operator string) (int64, error) {
+	if s.reload == nil {
+		return 0, errReloadDisabled
+	}
+
+	files := &configFiles{read: os.ReadFile, files: make(map[string][]byte)}
+	return s.applyConfig(operator, files, 0)
+}
+
+// ApplyConfigEvent puts in force the config recorded in ev, a replay applies
+// it before the order events of its version.
+func (s *OMS) ApplyConfigEvent(ev *model.ConfigEvent) error {
+	if s.reload == nil {
+		return errReloadDisabled
+	}
+
+	files := &configFiles{read: func(path string) ([]byte, error) {
+		return nil, &os.PathError{Op: "replay", Path: path, Err: os.ErrNotExist}
+	}, files: ev.Files}
+	_, err := s.applyConfig(ev.Operator, files, ev.Version)
+	return err
+}
+
+// ConfigEventLoader reads the latest stored config event of a node, nil
+// when there is none. repo.IConfigEvent is one.
+type ConfigEventLoader interface {
+	Latest(ctx context.Context, node int64) (*model.ConfigEvent, error)
+}
+
+// ResumeConfig puts back in force the latest stored config event of this
+// node. The startup reload that follows keeps its version when the files are
+// unchanged and records the next one otherwise, a version is never reused
+// across restarts. An event that no longer applies, such as one of files
+// that moved, only hands on its version.
+func (s *OMS) ResumeConfig(ctx context.Context, loader ConfigEventLoader) error {
+	ev, err := loader.Latest(ctx, model.Node())
+	if err != nil || ev == nil {
+		return err
+	}
+
+	if err := s.ApplyConfigEvent(ev); err != nil {
+		log.Printf("resume config version=%d err=%v", ev.Version, err)
+		s.raiseConfigVersion(ev.Version)
+	}
+	return nil
+}
+
+// ConfigVersion returns the version of the config in force, 0 before the
+// first reload.
+func (s *OMS) ConfigVersion() int64 {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	return s.configVersion
+}
+
+func (s *OMS) raiseConfigVersion(version int64) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.configVersion = max(s.configVersion, version)
+}
+
+// applyConfig builds everything from files before touching the OMS, version
+// zero records a new version.
+func (s *OMS) applyConfig(operator string, files *configFiles, version int64) (int64, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	data, err := files.ReadFile(s.reload.RiskFile)
+	if err != nil {
+		return 0, err
+	}
+	riskCfg, err := riskrule.ParseConfig(data)
+	if err != nil {
+		return 0, err
+	}
+
+	var instruments []*instrument.Instrument
+	if s.reload.InstrumentFile != "" {
+		if s.instruments == nil {
+			return 0, errNoInstrumentStore
+		}
+		if data, err = files.ReadFile(s.reload.InstrumentFile); err != nil {
+			return 0, err
+		}
+		if instruments, err = instrument.Parse(data); err != nil {
+			return 0, err
+		}
+		if err := instrument.Validate(instruments); err != nil {
+			return 0, err
+		}
+	}
+
+	deps := &riskrule.Deps{ReadFile: files.ReadFile}
+	if s.ledger != nil {
+		deps.Cash = s.ledger
+	}
+	if s.positions != nil {
+		deps.Positions = s.positions
+	}
+	if s.instruments != nil {
+		deps.InstrumentMaster = s.instruments
+	}
+	chain, err := riskrule.NewChain(riskCfg, deps)
+	if err != nil {
+		return 0, err
+	}
+
+	digest := files.digest()
+	if version == 0 && digest == s.configDigest {
+		return s.ConfigVersion(), nil
+	}
+
+	// everything is valid, swap
+	chain.SetPriceSource(s)
+	replay := version != 0
+	s.configMu.Lock()
+	s.risk.Replace(chain)
+	if instruments != nil {
+		s.instruments.Replace(instruments)
+	}
+	if !replay {
+		version = s.configVersion + 1
+	}
+	s.configVersion = version
+	s.configMu.Unlock()
+	s.configDigest = digest
+	s.configPaths = make(map[string]time.Time, len(files.files))
+	for path := range files.files {
+		s.configPaths[path] = modTime(path)
+	}
+
+	if replay {
+		return version, nil
+	}
+	s.eventstore.AddConfigEvent(&model.ConfigEvent{
+		Node:      model.Node(),
+		Version:   version,
+		Operator:  operator,
+		Digest:    digest,
+		Files:     files.files,
+		Timestamp: time.Now(),
+	})
+	s.audit.Record(operator, "config.reload", map[string]interface{}{
+		"version": version,
+		"digest":  digest,
+	})
+
+	return version, nil
+}
+
+// WatchConfig starts the background work of the risk rules and reloads the
+// config when one of its files changes, until ctx is done.
+func (s *OMS) WatchConfig(ctx context.Context) {
+	s.risk.Start(ctx)
+	if s.reload == nil || s.reload.WatchInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.reload.WatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !s.configChanged() {
+				continue
+			}
+			if _, err := s.ReloadConfig(ctx, "watcher"); err != nil {
+				log.Printf("config reload err=%v", err)
+			}
+		}
+	}()
+}
+
+func (s *OMS) configChanged() bool {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	for path, loaded := range s.configPaths {
+		if !modTime(path).Equal(loaded) {
+			return true
+		}
+	}
+	return false
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,189 @@
+package oms
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+const testRiskConfig = `
+rules:
+  - limit_price
+  - tick_size
+tick_size_file: %DIR%/tick_size.json
+limit_price:
+  instrument_file: %DIR%/market_data.json
+`
+
+const testTickSize = `{"exchanges": {"HOSE": {"default": [{"maxPrice": 0, "step": 1}]}}}`
+
+func writeConfig(t *testing.T, dir, name, content string) {
+	t.Helper()
+	content = replaceDir(content, dir)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s err=%v", name, err)
+	}
+}
+
+func replaceDir(s, dir string) string {
+	return strings.ReplaceAll(s, "%DIR%", dir)
+}
+
+func newReloadOMS(t *testing.T, dir string) (*OMS, *mockOrderGateway) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{
+		Instruments: instrument.NewStore(nil),
+		Reload: &ReloadConfig{
+			RiskFile:       filepath.Join(dir, "risk.yaml"),
+			InstrumentFile: filepath.Join(dir, "market_data.json"),
+		},
+	})
+	return s, gw
+}
+
+func TestReloadConfigSwapsRulesAndVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "risk.yaml", testRiskConfig)
+	writeConfig(t, dir, "tick_size.json", testTickSize)
+	writeConfig(t, dir, "market_data.json", `[{"symbol": "TEST", "exchange": "HOSE", "ceil": 11, "ref": 10, "floor": 9}]`)
+
+	s, gw := newReloadOMS(t, dir)
+	defer s.Stop()
+	ctx := context.Background()
+
+	if version, err := s.ReloadConfig(ctx, "ops"); err != nil || version != 1 {
+		t.Fatalf("first reload version=%d err=%v", version, err)
+	}
+	if err := s.AddOrder(ctx, newAddOrder("R1", model.OrderSideBuy, model.OrderTypeLimit, 12, 100)); err == nil {
+		t.Fatal("expected price above ceil to be rejected")
+	}
+	if version, _ := s.ReloadConfig(ctx, "ops"); version != 1 {
+		t.Fatalf("expected unchanged files to keep version 1, got %d", version)
+	}
+
+	// an invalid file leaves the config in force untouched
+	writeConfig(t, dir, "market_data.json", `[{"symbol": "TEST", "exchange": "HOSE", "ceil": 11, "ref": 10, "floor": 12}]`)
+	if _, err := s.ReloadConfig(ctx, "ops"); err == nil {
+		t.Fatal("expected invalid band to fail the reload")
+	}
+	writeConfig(t, dir, "market_data.json", `[{"symbol": "TEST", "exchange": "HOSE", "ceil": 13, "ref": 12, "floor": 11}]`)
+	writeConfig(t, dir, "risk.yaml", "rules: [unknown]")
+	if _, err := s.ReloadConfig(ctx, "ops"); err == nil {
+		t.Fatal("expected unknown rule to fail the reload")
+	}
+	if s.ConfigVersion() != 1 {
+		t.Fatalf("expected version 1 after failed reloads, got %d", s.ConfigVersion())
+	}
+	if i, _ := s.instruments.Get("TEST"); i.Ceil != 11 {
+		t.Fatalf("expected instrument master untouched, got %+v", i)
+	}
+
+	writeConfig(t, dir, "risk.yaml", testRiskConfig)
+	if version, err := s.ReloadConfig(ctx, "ops"); err != nil || version != 2 {
+		t.Fatalf("reload version=%d err=%v", version, err)
+	}
+	if err := s.AddOrder(ctx, newAddOrder("R2", model.OrderSideBuy, model.OrderTypeLimit, 12, 100)); err != nil {
+		t.Fatalf("expected new band to accept the order, err=%v", err)
+	}
+	if r := gw.lastReport("R2"); r == nil || r.Status != model.OrderStatusNew {
+		t.Fatalf("unexpected report %+v", r)
+	}
+}
+
+func TestApplyConfigEventReplaysRecordedFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := newReloadOMS(t, dir)
+	defer s.Stop()
+
+	riskFile := filepath.Join(dir, "risk.yaml")
+	ev := &model.ConfigEvent{
+		Version: 7,
+		Files: map[string][]byte{
+			riskFile:                               []byte(replaceDir(testRiskConfig, dir)),
+			filepath.Join(dir, "tick_size.json"):   []byte(testTickSize),
+			filepath.Join(dir, "market_data.json"): []byte(`[{"symbol": "TEST", "exchange": "HOSE", "ceil": 11, "ref": 10, "floor": 9}]`),
+		},
+	}
+	// nothing is on disk, everything comes from the event
+	if err := s.ApplyConfigEvent(ev); err != nil {
+		t.Fatalf("apply err=%v", err)
+	}
+	if s.ConfigVersion() != 7 {
+		t.Fatalf("expected version 7, got %d", s.ConfigVersion())
+	}
+	if err := s.AddOrder(context.Background(), newAddOrder("E1", model.OrderSideBuy, model.OrderTypeLimit, 12, 100)); err == nil {
+		t.Fatal("expected replayed band to reject the order")
+	}
+}
+
+// latestConfig is the stored config event of the previous run.
+type latestConfig struct {
+	ev *model.ConfigEvent
+}
+
+func (l latestConfig) Latest(context.Context, int64) (*model.ConfigEvent, error) {
+	return l.ev, nil
+}
+
+// storedConfig records the files of dir as a config event of version.
+func storedConfig(t *testing.T, dir string, version int64) *model.ConfigEvent {
+	files := make(map[string][]byte)
+	for _, name := range []string{"risk.yaml", "tick_size.json", "market_data.json"} {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[path] = data
+	}
+	return &model.ConfigEvent{Version: version, Files: files}
+}
+
+func TestStartupReloadResumesStoredConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "risk.yaml", testRiskConfig)
+	writeConfig(t, dir, "tick_size.json", testTickSize)
+	writeConfig(t, dir, "market_data.json", `[{"symbol": "TEST", "exchange": "HOSE", "ceil": 11, "ref": 10, "floor": 9}]`)
+	stored := storedConfig(t, dir, 5)
+	ctx := context.Background()
+
+	// unchanged files keep the stored version
+	s, gw := newReloadOMS(t, dir)
+	defer s.Stop()
+	if err := s.ResumeConfig(ctx, latestConfig{stored}); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := s.ReloadConfig(ctx, "startup"); err != nil || version != 5 {
+		t.Fatalf("expected the startup config to keep version 5, got %d err=%v", version, err)
+	}
+	_ = s.AddOrder(ctx, newAddOrder("R1", model.OrderSideBuy, model.OrderTypeLimit, 10, 100))
+	if r := gw.lastReport("R1"); r == nil || r.Status != model.OrderStatusNew {
+		t.Fatalf("expected R1 accepted, got %+v", r)
+	}
+
+	// changed files take the next version
+	writeConfig(t, dir, "market_data.json", `[{"symbol": "TEST", "exchange": "HOSE", "ceil": 13, "ref": 12, "floor": 11}]`)
+	s2, _ := newReloadOMS(t, dir)
+	defer s2.Stop()
+	if err := s2.ResumeConfig(ctx, latestConfig{stored}); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := s2.ReloadConfig(ctx, "startup"); err != nil || version != 6 {
+		t.Fatalf("expected the startup config to record version 6, got %d err=%v", version, err)
+	}
+
+	// a stored config of other files still hands on its version
+	s3, _ := newReloadOMS(t, t.TempDir())
+	defer s3.Stop()
+	if err := s3.ResumeConfig(ctx, latestConfig{stored}); err != nil {
+		t.Fatal(err)
+	}
+	if s3.ConfigVersion() != 5 {
+		t.Fatalf("expected version 5 handed on, got %d", s3.ConfigVersion())
+	}
+}
@@ -3,8 +3,59 @@ package oms
 import "errors"
 
 var (
-	errDuplicateOrder     = errors.New("dupplicate order")
-	errOrderIDNotFound    = errors.New("orderID not found")
-	errGatewayIDNotFound  = errors.New("gatewayID not found")
-	errInvalidOrderStatus = errors.New("invalid order status")
+	errDuplicateOrder       = errors.New("dupplicate order")
+	errOrderIDNotFound      = errors.New("orderID not found")
+	errGatewayIDNotFound    = errors.New("gatewayID not found")
+	errInvalidOrderStatus   = errors.New("invalid order status")
+	errInvalidOrderList     = errors.New("invalid order list")
+	errInvalidBoardLot      = errors.New("quantity is not a multiple of board lot")
+	errInvalidOddLotOrder   = errors.New("odd-lot order must be a resting limit order")
+	errUnknownSymbol        = errors.New("unknown symbol")
+	errPriceOutOfBand       = errors.New("price out of ceil/floor band")
+	errInvalidQuantity      = errors.New("invalid quantity")
+	errInvalidStopPrice     = errors.New("stop order needs a positive stop price")
+	errNoPegReference       = errors.New("no peg price behind the opposite side")
+	errInvalidPegOrder      = errors.New("pegged order must be a main board limit order")
+	errInvalidHiddenOrder   = errors.New("hidden order must be a limit order")
+	errInvalidQuote         = errors.New("invalid quote")
+	errInvalidQuoteSpread   = errors.New("quote bid must be below offer")
+	errQuoteSide            = errors.New("quote sides are managed through quotes")
+	errInvalidPutThrough    = errors.New("put-through needs one buy and one sell side")
+	errInvalidKillSwitch    = errors.New("invalid kill switch scope")
+	errKillSwitchNotFound   = errors.New("kill switch not engaged")
+	errReloadDisabled       = errors.New("config reload not configured")
+	errNoInstrumentStore    = errors.New("no instrument master to reload")
+	errUnsupportedOrderType = errors.New("unsupported order type")
+	errUnsupportedSide      = errors.New("unsupported side")
+	errRecoverAfterStart    = errors.New("recovery must run before start")
 )
+
+// Errors names the errors returned by the OMS. Remote callers, such as the
+// shard router, send the name so errors.Is still matches on the other side.
+var Errors = map[string]error{
+	"duplicate_order":        errDuplicateOrder,
+	"order_id_not_found":     errOrderIDNotFound,
+	"gateway_id_not_found":   errGatewayIDNotFound,
+	"invalid_order_status":   errInvalidOrderStatus,
+	"invalid_order_list":     errInvalidOrderList,
+	"invalid_board_lot":      errInvalidBoardLot,
+	"invalid_odd_lot_order":  errInvalidOddLotOrder,
+	"unknown_symbol":         errUnknownSymbol,
+	"price_out_of_band":      errPriceOutOfBand,
+	"invalid_quantity":       errInvalidQuantity,
+	"invalid_stop_price":     errInvalidStopPrice,
+	"no_peg_reference":       errNoPegReference,
+	"invalid_peg_order":      errInvalidPegOrder,
+	"invalid_hidden_order":   errInvalidHiddenOrder,
+	"invalid_quote":          errInvalidQuote,
+	"invalid_quote_spread":   errInvalidQuoteSpread,
+	"quote_side":             errQuoteSide,
+	"invalid_put_through":    errInvalidPutThrough,
+	"invalid_kill_switch":    errInvalidKillSwitch,
+	"kill_switch_not_found":  errKillSwitchNotFound,
+	"reload_disabled":        errReloadDisabled,
+	"no_instrument_store":    errNoInstrumentStore,
+	"unsupported_order_type": errUnsupportedOrderType,
+	"unsupported_side":       errUnsupportedSide,
+	"recover_after_start":    errRecoverAfterStart,
+}
@@ -4,6 +4,8 @@ import "github.com/joripage/orderbook-dev/pkg/oms/model"
 
 type EventStore interface {
 	AddEvent(ev *model.OrderEvent)
+	AddConfigEvent(ev *model.ConfigEvent)
+	RestoreEvent(ev *model.OrderEvent)
 	TrackClOrdChain(orderID, clOrdID, origClOrdID string)
 	GetLatestGatewayID(orderID string) string
 	GetOrigGatewayID(clOrdID string) string
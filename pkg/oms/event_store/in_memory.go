@@ -1,6 +1,8 @@
 package eventstore
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,6 +17,11 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+const (
+	configEventTopic = "CONFIG.events"
+	configEventKey   = "config"
+)
+
 type InMemoryEventStore struct {
 	mu sync.RWMutex
 
@@ -55,10 +62,12 @@ func NewInMemoryEventStore() *InMemoryEventStore {
 		prod:       prod,
 	}
 	store.sq.Start(func(msg interface{}) error {
-		if v, ok := msg.(*model.OrderEvent); ok {
-			// _ = v
+		switch v := msg.(type) {
+		case *model.OrderEvent:
 			// store.publish(v)
 			store.publishKafka(context.Background(), v)
+		case *model.ConfigEvent:
+			store.publishConfigKafka(context.Background(), v)
 		}
 		return nil
 	})
@@ -81,8 +90,7 @@ func (s *InMemoryEventStore) AddEvent(event *model.OrderEvent) {
 	// update store
 	// s.orders[event.OrderID] = append(s.orders[event.OrderID], event)
 
-	// update ClOrdID chain
-	s.TrackClOrdChain(event.OrderID, event.GatewayID, event.OrigGatewayID)
+	s.trackEvent(event)
 
 	s.sq.Shard(event.OrderID, event)
 	// s.dispatcher <- event
@@ -92,6 +100,29 @@ func (s *InMemoryEventStore) AddEvent(event *model.OrderEvent) {
 	// }
 }
 
+// RestoreEvent tracks the ClOrdID chain of a recovered event, the event is
+// already in the log and is not published again.
+func (s *InMemoryEventStore) RestoreEvent(event *model.OrderEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trackEvent(event)
+}
+
+// trackEvent updates the ClOrdID chain, a rejected duplicate leaves the
+// ClOrdID on the order that owns it.
+func (s *InMemoryEventStore) trackEvent(event *model.OrderEvent) {
+	if owner := s.gatewayIDToOrderID[event.GatewayID]; event.ExecType != model.ExecTypeRejected || owner == "" || owner == event.OrderID {
+		s.TrackClOrdChain(event.OrderID, event.GatewayID, event.OrigGatewayID)
+	}
+}
+
+// AddConfigEvent queues ev behind the order events already added, so the
+// event log orders it between the events of the previous and new config.
+func (s *InMemoryEventStore) AddConfigEvent(ev *model.ConfigEvent) {
+	s.sq.Shard(configEventKey, ev)
+}
+
 // TrackClOrdChain updates the chain between ClOrdID and OrigClOrdID
 func (s *InMemoryEventStore) TrackClOrdChain(orderID, gatewayID, origGatewayID string) {
 	// always set the latest ClOrdID
@@ -222,6 +253,28 @@ func (s *InMemoryEventStore) runDispatcher() {
 	// }
 }
 
+// publishConfigKafka gzips the event, the instrument master alone is above
+// the 1MB batch of the producer.
+func (s *InMemoryEventStore) publishConfigKafka(ctx context.Context, event *model.ConfigEvent) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(event); err != nil {
+		log.Println(err)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		log.Println(err)
+		return err
+	}
+	err := s.prod.Publish(ctx, configEventTopic, []byte(configEventKey), buf.Bytes(), map[string]string{"content-encoding": "gzip"})
+	if err != nil {
+		log.Println("publish config error: ", err)
+		return err
+	}
+
+	return nil
+}
+
 func (s *InMemoryEventStore) publishKafka(ctx context.Context, event *model.OrderEvent) error {
 	data, err := json.Marshal(event)
 	if err != nil {
@@ -10,9 +10,14 @@ import (
 
 	"github.com/joripage/go_util/pkg/shardqueue"
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/fix44/massquote"
+	"github.com/quickfixgo/fix44/newordercross"
+	"github.com/quickfixgo/fix44/neworderlist"
 	"github.com/quickfixgo/fix44/newordersingle"
 	"github.com/quickfixgo/fix44/ordercancelreplacerequest"
 	"github.com/quickfixgo/fix44/ordercancelrequest"
+	"github.com/quickfixgo/fix44/quote"
 	"github.com/quickfixgo/quickfix"
 	"github.com/quickfixgo/quickfix/log/file"
 	"github.com/quickfixgo/tag"
@@ -28,22 +33,29 @@ type Application struct {
 	shardQueue    *shardqueue.Shardqueue
 
 	fixGateway *FixGateway
+
+	sessionThrottle *throttle
+	accountThrottle *throttle
 }
 
 type AppConfig struct {
 	enableQueue      bool
 	enableShardQueue bool
+	throttle         *ThrottleConfig
 }
 
 type inboundMsg struct {
 	msg       *quickfix.Message
 	sessionID quickfix.SessionID
+	// cmd is a request that does not come through FIX, see execute
+	cmd func()
 }
 
 type outboundMsg struct {
 	// msg       *quickfix.Message
-	order     model.Order
-	sessionID *quickfix.SessionID
+	order        model.Order
+	cancelReject *model.CancelReject
+	sessionID    *quickfix.SessionID
 }
 
 const (
@@ -58,10 +70,18 @@ func newApplication(cfg AppConfig, fixGateway *FixGateway) *Application {
 		quickEvent:    make(chan bool, 1),
 		fixGateway:    fixGateway,
 	}
+	if cfg.throttle != nil {
+		app.sessionThrottle = newThrottle(cfg.throttle.SessionRate, cfg.throttle.SessionBurst)
+		app.accountThrottle = newThrottle(cfg.throttle.AccountRate, cfg.throttle.AccountBurst)
+	}
 
 	app.AddRoute(newordersingle.Route(app.onNewOrderSingle))
 	app.AddRoute(ordercancelrequest.Route(app.onOrderCancelRequest))
 	app.AddRoute(ordercancelreplacerequest.Route(app.onOrderCancelReplaceRequest))
+	app.AddRoute(neworderlist.Route(app.onNewOrderList))
+	app.AddRoute(newordercross.Route(app.onNewOrderCross))
+	app.AddRoute(quote.Route(app.onQuote))
+	app.AddRoute(massquote.Route(app.onMassQuote))
 
 	if app.cfg.enableShardQueue {
 		app.shardQueue = shardqueue.NewShardQueue(numShards, queueSize)
@@ -103,6 +123,7 @@ func startApp(config_filepath string, fixGateway *FixGateway) (*Application, err
 	app := newApplication(AppConfig{
 		enableQueue: true,
 		// enableShardQueue: true,
+		throttle: fixGateway.cfg.Throttle,
 	}, fixGateway)
 
 	logFactory, _ := file.NewLogFactory(appSettings)
@@ -137,8 +158,11 @@ func (a Application) OnCreate(sessionID quickfix.SessionID) {}
 // OnLogon implemented as part of Application interface
 func (a Application) OnLogon(sessionID quickfix.SessionID) {}
 
-// OnLogout implemented as part of Application interface
-func (a Application) OnLogout(sessionID quickfix.SessionID) {}
+// OnLogout implemented as part of Application interface, pulls the quotes of
+// the session
+func (a Application) OnLogout(sessionID quickfix.SessionID) {
+	a.fixGateway.CancelSessionQuotes(context.Background(), sessionID)
+}
 
 // ToAdmin implemented as part of Application interface
 func (a Application) ToAdmin(msg *quickfix.Message, sessionID quickfix.SessionID) {}
@@ -155,12 +179,16 @@ func (a Application) FromAdmin(msg *quickfix.Message, sessionID quickfix.Session
 
 // FromApp implemented as part of Application interface, uses Router on incoming application messages
 func (a *Application) FromApp(msg *quickfix.Message, sessionID quickfix.SessionID) (reject quickfix.MessageRejectError) {
+	// throttle before queueing so a flood never fills the dispatcher
+	if ok, rejectErr := a.throttle(msg, sessionID); !ok {
+		return rejectErr
+	}
 
 	if a.cfg.enableShardQueue {
-		a.shardQueue.Shard(getRoutingKey(msg, sessionID), &inboundMsg{msg, sessionID})
+		a.shardQueue.Shard(getRoutingKey(msg, sessionID), &inboundMsg{msg: msg, sessionID: sessionID})
 		return nil
 	} else if a.cfg.enableQueue {
-		a.dispatcher <- &inboundMsg{msg, sessionID}
+		a.dispatcher <- &inboundMsg{msg: msg, sessionID: sessionID}
 		return nil
 	}
 
@@ -181,15 +209,35 @@ func getRoutingKey(msg *quickfix.Message, sessionID quickfix.SessionID) string {
 
 func (a *Application) runDispatcher() {
 	for msg := range a.dispatcher {
+		if msg.cmd != nil {
+			msg.cmd()
+			continue
+		}
 		if err := a.Route(msg.msg, msg.sessionID); err != nil {
 			log.Println("Route error", err)
 		}
 	}
 }
 
+// execute runs fn on the dispatcher in turn with the FIX requests and
+// returns its error. Without the dispatcher queue fn runs at once.
+func (a *Application) execute(fn func() error) error {
+	if !a.cfg.enableQueue || a.cfg.enableShardQueue {
+		return fn()
+	}
+	done := make(chan error, 1)
+	a.dispatcher <- &inboundMsg{cmd: func() { done <- fn() }}
+	return <-done
+}
+
 func (a *Application) runDispatcherOut() {
 	for msg := range a.dispatcherOut {
-		err := orderReportToExecutionReport(msg.order, msg.sessionID)
+		var err error
+		if msg.cancelReject != nil {
+			err = cancelRejectToOrderCancelReject(*msg.cancelReject, msg.sessionID)
+		} else {
+			err = orderReportToExecutionReport(msg.order, a.fixGateway.priceDecimals(msg.order.Symbol), msg.sessionID)
+		}
 		if err != nil {
 			log.Printf("send err=%v", err)
 		}
@@ -211,6 +259,9 @@ func (a *Application) onNewOrderSingle(msg newordersingle.NewOrderSingle, sessio
 	securityType, _ := msg.GetSecurityType()
 	securityID, _ := msg.GetSecurityID()
 	maxFloor, _ := msg.GetMaxFloor()
+	stopPx, _ := msg.GetStopPx()
+	execInst, _ := msg.GetExecInst()
+	pegOffsetValue, _ := msg.GetPegOffsetValue()
 
 	m := &NewOrderSingle{
 		SessionID: &sessionID,
@@ -229,6 +280,10 @@ func (a *Application) onNewOrderSingle(msg newordersingle.NewOrderSingle, sessio
 		SecurityType:      securityType,
 		SecurityID:        securityID,
 		MaxFloor:          maxFloor,
+		Hidden:            msg.HasMaxFloor() && maxFloor.IsZero(),
+		StopPx:            stopPx,
+		ExecInst:          execInst,
+		PegOffsetValue:    pegOffsetValue,
 	}
 	a.fixGateway.AddOrder(context.Background(), m)
 
@@ -304,3 +359,173 @@ func (a *Application) onOrderCancelReplaceRequest(msg ordercancelreplacerequest.
 
 	return nil
 }
+
+func (a *Application) onNewOrderList(msg neworderlist.NewOrderList, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	listID, _ := msg.GetListID()
+	// ContingencyType(1385) is not part of the FIX 4.4 dictionary, read it from the body
+	contingencyType, _ := msg.Body.GetString(tag.ContingencyType)
+	noOrders, err := msg.GetNoOrders()
+	if err != nil {
+		return err
+	}
+
+	m := &NewOrderList{
+		SessionID: &sessionID,
+
+		ListID:          listID,
+		ContingencyType: enum.ContingencyType(contingencyType),
+	}
+	for i := 0; i < noOrders.Len(); i++ {
+		order := noOrders.Get(i)
+		clOrdID, _ := order.GetClOrdID()
+		account, _ := order.GetAccount()
+		symbol, _ := order.GetSymbol()
+		side, _ := order.GetSide()
+		ordType, _ := order.GetOrdType()
+		price, _ := order.GetPrice()
+		stopPx, _ := order.GetStopPx()
+		orderQty, _ := order.GetOrderQty()
+		timeInForce, _ := order.GetTimeInForce()
+		securityID, _ := order.GetSecurityID()
+
+		m.Orders = append(m.Orders, &NewOrderSingle{
+			SessionID: &sessionID,
+
+			Account:     account,
+			ClOrdID:     clOrdID,
+			Symbol:      symbol,
+			SecurityID:  securityID,
+			OrdType:     ordType,
+			Price:       price,
+			StopPx:      stopPx,
+			TimeInForce: timeInForce,
+			Side:        side,
+			OrderQty:    orderQty,
+		})
+	}
+	a.fixGateway.AddOrderList(context.Background(), m)
+
+	return nil
+}
+
+func (a *Application) onNewOrderCross(msg newordercross.NewOrderCross, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	crossID, _ := msg.GetCrossID()
+	crossType, _ := msg.GetCrossType()
+	symbol, _ := msg.GetSymbol()
+	securityID, _ := msg.GetSecurityID()
+	ordType, _ := msg.GetOrdType()
+	price, _ := msg.GetPrice()
+	transactTime, _ := msg.GetTransactTime()
+	noSides, err := msg.GetNoSides()
+	if err != nil {
+		return err
+	}
+
+	m := &NewOrderCross{
+		SessionID: &sessionID,
+
+		CrossID:      crossID,
+		CrossType:    crossType,
+		Symbol:       symbol,
+		SecurityID:   securityID,
+		OrdType:      ordType,
+		Price:        price,
+		TransactTime: transactTime,
+	}
+	for i := 0; i < noSides.Len(); i++ {
+		side := noSides.Get(i)
+		sideValue, _ := side.GetSide()
+		clOrdID, _ := side.GetClOrdID()
+		account, _ := side.GetAccount()
+		orderQty, _ := side.GetOrderQty()
+
+		m.Sides = append(m.Sides, &CrossSide{
+			Side:     sideValue,
+			ClOrdID:  clOrdID,
+			Account:  account,
+			OrderQty: orderQty,
+		})
+	}
+	a.fixGateway.AddCross(context.Background(), m)
+
+	return nil
+}
+
+func (a *Application) onQuote(msg quote.Quote, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	quoteID, _ := msg.GetQuoteID()
+	account, _ := msg.GetAccount()
+	symbol, _ := msg.GetSymbol()
+	securityID, _ := msg.GetSecurityID()
+	bidPx, _ := msg.GetBidPx()
+	bidSize, _ := msg.GetBidSize()
+	offerPx, _ := msg.GetOfferPx()
+	offerSize, _ := msg.GetOfferSize()
+	transactTime, _ := msg.GetTransactTime()
+
+	m := &Quote{
+		SessionID: &sessionID,
+
+		QuoteID:      quoteID,
+		Account:      account,
+		Symbol:       symbol,
+		SecurityID:   securityID,
+		BidPx:        bidPx,
+		BidSize:      bidSize,
+		OfferPx:      offerPx,
+		OfferSize:    offerSize,
+		TransactTime: transactTime,
+	}
+	a.fixGateway.Quote(context.Background(), m)
+
+	return nil
+}
+
+func (a *Application) onMassQuote(msg massquote.MassQuote, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	quoteID, _ := msg.GetQuoteID()
+	account, _ := msg.GetAccount()
+	noQuoteSets, err := msg.GetNoQuoteSets()
+	if err != nil {
+		return err
+	}
+
+	m := &MassQuote{
+		SessionID: &sessionID,
+
+		QuoteID: quoteID,
+		Account: account,
+	}
+	for i := 0; i < noQuoteSets.Len(); i++ {
+		set := noQuoteSets.Get(i)
+		quoteSetID, _ := set.GetQuoteSetID()
+		noQuoteEntries, err := set.GetNoQuoteEntries()
+		if err != nil {
+			return err
+		}
+
+		quoteSet := &QuoteSet{QuoteSetID: quoteSetID}
+		for j := 0; j < noQuoteEntries.Len(); j++ {
+			entry := noQuoteEntries.Get(j)
+			quoteEntryID, _ := entry.GetQuoteEntryID()
+			symbol, _ := entry.GetSymbol()
+			securityID, _ := entry.GetSecurityID()
+			bidPx, _ := entry.GetBidPx()
+			bidSize, _ := entry.GetBidSize()
+			offerPx, _ := entry.GetOfferPx()
+			offerSize, _ := entry.GetOfferSize()
+
+			quoteSet.Entries = append(quoteSet.Entries, &QuoteEntry{
+				QuoteEntryID: quoteEntryID,
+				Symbol:       symbol,
+				SecurityID:   securityID,
+				BidPx:        bidPx,
+				BidSize:      bidSize,
+				OfferPx:      offerPx,
+				OfferSize:    offerSize,
+			})
+		}
+		m.QuoteSets = append(m.QuoteSets, quoteSet)
+	}
+	a.fixGateway.MassQuote(context.Background(), m)
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package fixgateway
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExecuteWaitsForQueuedRequests(t *testing.T) {
+	app := newApplication(AppConfig{enableQueue: true}, &FixGateway{})
+	release := make(chan struct{})
+	app.dispatcher <- &inboundMsg{cmd: func() { <-release }}
+
+	ran := make(chan struct{})
+	go app.execute(func() error {
+		close(ran)
+		return nil
+	})
+	select {
+	case <-ran:
+		t.Fatal("expected the command to wait for the queued request")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected the command to run after the queued request")
+	}
+}
@@ -3,11 +3,14 @@ package fixgateway
 import (
 	"context"
 	"log"
+	"strings"
 	"sync"
 
 	"github.com/joripage/orderbook-dev/pkg/oms"
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
 	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+	"github.com/shopspring/decimal"
 )
 
 type FixGateway struct {
@@ -20,10 +23,25 @@ type FixGateway struct {
 
 	requestMapping sync.Map
 	sessionMapping sync.Map
+
+	// accounts that quoted through a session, for cancel on disconnect
+	quoteMu       sync.Mutex
+	quoteAccounts map[quickfix.SessionID]map[string]struct{}
 }
 
 type FixGatewayConfig struct {
 	ConfigFilepath string
+	// Throttle limits inbound messages per session and account, nil
+	// disables throttling.
+	Throttle *ThrottleConfig
+	// Prices gives the price precision of execution reports, nil sends
+	// whole prices.
+	Prices PricePrecision
+}
+
+// PricePrecision returns the number of decimals of the prices of symbol.
+type PricePrecision interface {
+	PriceDecimals(symbol string) int32
 }
 
 func NewFixGateway(cfg *FixGatewayConfig) *FixGateway {
@@ -33,11 +51,19 @@ func NewFixGateway(cfg *FixGatewayConfig) *FixGateway {
 		// orderCancelRequestMapping: sync.Map{},
 		requestMapping: sync.Map{},
 		sessionMapping: sync.Map{},
+		quoteAccounts:  make(map[quickfix.SessionID]map[string]struct{}),
 	}
 
 	return fm
 }
 
+func (s *FixGateway) priceDecimals(symbol string) int32 {
+	if s.cfg.Prices == nil {
+		return 0
+	}
+	return s.cfg.Prices.PriceDecimals(symbol)
+}
+
 func (s *FixGateway) AddOmsInstance(o oms.IOMS) {
 	s.omsInstance = o
 }
@@ -52,11 +78,105 @@ func (s *FixGateway) Start(ctx context.Context) error {
 	return nil
 }
 
+// EngageKillSwitch engages ks in turn with the FIX requests, its mass cancel
+// never races the fills of an order request.
+func (s *FixGateway) EngageKillSwitch(ctx context.Context, ks *model.KillSwitch) error {
+	return s.execute(func() error { return s.omsInstance.EngageKillSwitch(ctx, ks) })
+}
+
+// ReleaseKillSwitch releases ks in turn with the FIX requests.
+func (s *FixGateway) ReleaseKillSwitch(ctx context.Context, ks *model.KillSwitch) error {
+	return s.execute(func() error { return s.omsInstance.ReleaseKillSwitch(ctx, ks) })
+}
+
+func (s *FixGateway) KillSwitches(ctx context.Context) ([]model.KillSwitch, error) {
+	return s.omsInstance.KillSwitches(ctx)
+}
+
+// execute runs fn on the dispatcher of the FIX requests once the gateway is
+// started, before that no request is routed and fn runs at once.
+func (s *FixGateway) execute(fn func() error) error {
+	if s.app == nil {
+		return fn()
+	}
+	return s.app.execute(fn)
+}
+
 func (s *FixGateway) AddOrder(ctx context.Context, newOrderSingle *NewOrderSingle) {
+	s.AddRequestToMap(newOrderSingle.ClOrdID, newOrderSingle.SessionID)
+
+	// a refused order is answered by a Rejected execution report
+	_ = s.omsInstance.AddOrder(ctx, toAddOrder(newOrderSingle))
+}
+
+// AddOrderList accepts a contingent order list:
+//   - ContingencyType 1 (OCO): two orders, a fill on one cancels the other
+//   - ContingencyType 2 (OTO): two orders, the second one is activated once
+//     the first one is filled
+func (s *FixGateway) AddOrderList(ctx context.Context, newOrderList *NewOrderList) {
+	contingencyType := map[enum.ContingencyType]model.ContingencyType{
+		enum.ContingencyType_ONE_CANCELS_THE_OTHER:  model.ContingencyTypeOCO,
+		enum.ContingencyType_ONE_TRIGGERS_THE_OTHER: model.ContingencyTypeOTO,
+	}[newOrderList.ContingencyType]
+
+	addOrderList := &model.AddOrderList{
+		ListID:          newOrderList.ListID,
+		ContingencyType: contingencyType,
+	}
+	for _, newOrderSingle := range newOrderList.Orders {
+		s.AddRequestToMap(newOrderSingle.ClOrdID, newOrderSingle.SessionID)
+		addOrderList.Orders = append(addOrderList.Orders, toAddOrder(newOrderSingle))
+	}
+
+	err := s.omsInstance.AddOrderList(ctx, addOrderList)
+	if err != nil {
+		log.Printf("add order list ListID=%s err=%v", newOrderList.ListID, err)
+	}
+}
+
+// AddCross accepts a put-through deal: one buy and one sell side agreed at
+// the cross price, booked outside the continuous book. A refused deal is
+// answered by a Rejected execution report on each side.
+func (s *FixGateway) AddCross(ctx context.Context, newOrderCross *NewOrderCross) {
+	putThrough := &model.PutThrough{
+		CrossID:      newOrderCross.CrossID,
+		Symbol:       newOrderCross.Symbol,
+		SecurityID:   newOrderCross.SecurityID,
+		Price:        newOrderCross.Price,
+		TransactTime: newOrderCross.TransactTime,
+	}
+	mismatch := false
+	for _, side := range newOrderCross.Sides {
+		if !putThrough.Quantity.IsZero() && !putThrough.Quantity.Equal(side.OrderQty) {
+			mismatch = true
+		}
+		s.AddRequestToMap(side.ClOrdID, newOrderCross.SessionID)
+
+		switch side.Side {
+		case enum.Side_BUY:
+			putThrough.BuyGatewayID, putThrough.BuyAccount = side.ClOrdID, side.Account
+		case enum.Side_SELL:
+			putThrough.SellGatewayID, putThrough.SellAccount = side.ClOrdID, side.Account
+		}
+		putThrough.Quantity = side.OrderQty
+	}
+	// sides of different quantities cannot cross, the OMS rejects a zero
+	// quantity with reason IncorrectQuantity
+	if mismatch {
+		putThrough.Quantity = decimal.Zero
+	}
+
+	err := s.omsInstance.PutThrough(ctx, putThrough)
+	if err != nil {
+		log.Printf("put-through CrossID=%s err=%v", newOrderCross.CrossID, err)
+	}
+}
 
+func toAddOrder(newOrderSingle *NewOrderSingle) *model.AddOrder {
 	orderType := map[enum.OrdType]model.OrderType{
 		enum.OrdType_LIMIT:  model.OrderTypeLimit,
 		enum.OrdType_MARKET: model.OrderTypeMarket,
+		enum.OrdType_STOP:   model.OrderTypeStop,
 		//check iceberg
 	}[enum.OrdType(newOrderSingle.OrdType)]
 	// var visibleQty int
@@ -65,6 +185,22 @@ func (s *FixGateway) AddOrder(ctx context.Context, newOrderSingle *NewOrderSingl
 		// visibleQty = int(maxFloor.IntPart())
 	}
 
+	// pegged order: OrdType=P, peg reference in ExecInst(18)
+	var pegType model.OrderPegType
+	if enum.OrdType(newOrderSingle.OrdType) == enum.OrdType_PEGGED {
+		orderType = model.OrderTypeLimit
+		for _, inst := range strings.Fields(string(newOrderSingle.ExecInst)) {
+			switch enum.ExecInst(inst) {
+			case enum.ExecInst_PRIMARY_PEG:
+				pegType = model.OrderPegTypePrimary
+			case enum.ExecInst_MARKET_PEG:
+				pegType = model.OrderPegTypeMarket
+			case enum.ExecInst_MID_PRICE_PEG:
+				pegType = model.OrderPegTypeMidpoint
+			}
+		}
+	}
+
 	timeInForce := map[enum.TimeInForce]model.OrderTimeInForce{
 		enum.TimeInForce_DAY:                 model.OrderTimeInForceDAY,
 		enum.TimeInForce_FILL_OR_KILL:        model.OrderTimeInForceFOK,
@@ -73,13 +209,12 @@ func (s *FixGateway) AddOrder(ctx context.Context, newOrderSingle *NewOrderSingl
 	}[enum.TimeInForce(newOrderSingle.TimeInForce)]
 
 	side := map[enum.Side]model.OrderSide{
-		enum.Side_BUY:  model.OrderSideBuy,
-		enum.Side_SELL: model.OrderSideSell,
+		enum.Side_BUY:        model.OrderSideBuy,
+		enum.Side_SELL:       model.OrderSideSell,
+		enum.Side_SELL_SHORT: model.OrderSideSell,
 	}[enum.Side(newOrderSingle.Side)]
 
-	s.AddRequestToMap(newOrderSingle.ClOrdID, newOrderSingle.SessionID)
-
-	s.omsInstance.AddOrder(ctx, &model.AddOrder{
+	return &model.AddOrder{
 		GatewayID:  newOrderSingle.ClOrdID,
 		Account:    newOrderSingle.Account,
 		Symbol:     newOrderSingle.Symbol,
@@ -87,11 +222,24 @@ func (s *FixGateway) AddOrder(ctx context.Context, newOrderSingle *NewOrderSingl
 		// Exchange:     newOrderSingle.Exchange,
 		Type:         orderType,
 		Price:        newOrderSingle.Price,
+		StopPrice:    newOrderSingle.StopPx,
+		PegType:      pegType,
+		PegOffset:    newOrderSingle.PegOffsetValue,
+		Hidden:       newOrderSingle.Hidden,
+		ShortSell:    newOrderSingle.Side == enum.Side_SELL_SHORT,
 		TimeInForce:  timeInForce,
 		Side:         side,
 		TransactTime: newOrderSingle.TransactTime,
 		Quantity:     newOrderSingle.OrderQty,
-	})
+		SessionID:    sessionString(newOrderSingle.SessionID),
+	}
+}
+
+func sessionString(sessionID *quickfix.SessionID) string {
+	if sessionID == nil {
+		return ""
+	}
+	return sessionID.String()
 }
 
 func (s *FixGateway) ModifyOrder(ctx context.Context, req *OrderCancelReplaceRequest) {
@@ -102,6 +250,7 @@ func (s *FixGateway) ModifyOrder(ctx context.Context, req *OrderCancelReplaceReq
 		NewQuantity:   req.OrderQty,
 		GatewayID:     req.ClOrdID,
 		OrigGatewayID: req.OrigClOrdID,
+		Symbol:        req.Symbol,
 	})
 }
 
@@ -111,6 +260,7 @@ func (s *FixGateway) CancelOrder(ctx context.Context, req *OrderCancelRequest) {
 	s.omsInstance.CancelOrder(ctx, &model.CancelOrder{
 		GatewayID:     req.ClOrdID,
 		OrigGatewayID: req.OrigClOrdID,
+		Symbol:        req.Symbol,
 	})
 }
 
@@ -119,16 +269,16 @@ func (s *FixGateway) OnOrderReport(ctx context.Context, args ...interface{}) {
 		return
 	}
 
-	if order, ok := args[0].(model.Order); ok {
-
-		sessionID, err := s.GetRequestByClOrdID(order.GatewayID)
+	switch report := args[0].(type) {
+	case model.Order:
+		sessionID, err := s.GetRequestByClOrdID(report.GatewayID)
 		if err != nil {
-			log.Printf("match OrderID=%s not found", order.GatewayID)
+			log.Printf("match OrderID=%s not found", report.GatewayID)
 			return
 		}
 
 		s.app.dispatcherOut <- &outboundMsg{
-			order:     order,
+			order:     report,
 			sessionID: sessionID,
 		}
 
@@ -137,5 +287,16 @@ func (s *FixGateway) OnOrderReport(ctx context.Context, args ...interface{}) {
 		// 	log.Printf("send err=%v", err)
 		// 	return
 		// }
+	case model.CancelReject:
+		sessionID, err := s.GetRequestByClOrdID(report.GatewayID)
+		if err != nil {
+			log.Printf("cancel reject ClOrdID=%s not found", report.GatewayID)
+			return
+		}
+
+		s.app.dispatcherOut <- &outboundMsg{
+			cancelReject: &report,
+			sessionID:    sessionID,
+		}
 	}
 }
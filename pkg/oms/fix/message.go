@@ -8,7 +8,9 @@ import (
 
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
 	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
 	"github.com/quickfixgo/fix44/executionreport"
+	"github.com/quickfixgo/fix44/ordercancelreject"
 	"github.com/quickfixgo/quickfix"
 	"github.com/shopspring/decimal"
 )
@@ -32,10 +34,61 @@ var (
 		model.OrderStatusPendingReplace:     enum.OrdStatus_PENDING_REPLACE,
 	}
 
+	ExecTypeMapping map[model.OrderExecType]enum.ExecType = map[model.OrderExecType]enum.ExecType{
+		model.ExecTypeNew:            enum.ExecType_NEW,
+		model.ExecTypeDoneForDay:     enum.ExecType_DONE_FOR_DAY,
+		model.ExecTypeCanceled:       enum.ExecType_CANCELED,
+		model.ExecTypeReplaced:       enum.ExecType_REPLACED,
+		model.ExecTypePendingCancel:  enum.ExecType_PENDING_CANCEL,
+		model.ExecTypeStopped:        enum.ExecType_STOPPED,
+		model.ExecTypeRejected:       enum.ExecType_REJECTED,
+		model.ExecTypeSuspended:      enum.ExecType_SUSPENDED,
+		model.ExecTypePendingNew:     enum.ExecType_PENDING_NEW,
+		model.ExecTypeCalculated:     enum.ExecType_CALCULATED,
+		model.ExecTypeExpired:        enum.ExecType_EXPIRED,
+		model.ExecTypeRestated:       enum.ExecType_RESTATED,
+		model.ExecTypePendingReplace: enum.ExecType_PENDING_REPLACE,
+		model.ExecTypeTrade:          enum.ExecType_TRADE,
+		model.ExecTypeTradeCorrect:   enum.ExecType_TRADE_CORRECT,
+		model.ExecTypeTradeCancel:    enum.ExecType_TRADE_CANCEL,
+		model.ExecTypeOrderStatus:    enum.ExecType_ORDER_STATUS,
+	}
+
 	SideMapping map[model.OrderSide]enum.Side = map[model.OrderSide]enum.Side{
 		model.OrderSideBuy:  enum.Side_BUY,
 		model.OrderSideSell: enum.Side_SELL,
 	}
+
+	OrdRejReasonMapping map[model.OrderRejectReason]enum.OrdRejReason = map[model.OrderRejectReason]enum.OrdRejReason{
+		model.RejectReasonBrokerOption:          enum.OrdRejReason_BROKER,
+		model.RejectReasonUnknownSymbol:         enum.OrdRejReason_UNKNOWN_SYMBOL,
+		model.RejectReasonExchangeClosed:        enum.OrdRejReason_EXCHANGE_CLOSED,
+		model.RejectReasonOrderExceedsLimit:     enum.OrdRejReason_ORDER_EXCEEDS_LIMIT,
+		model.RejectReasonTooLateToEnter:        enum.OrdRejReason_TOO_LATE_TO_ENTER,
+		model.RejectReasonUnknownOrder:          enum.OrdRejReason_UNKNOWN_ORDER,
+		model.RejectReasonDuplicateOrder:        enum.OrdRejReason_DUPLICATE_ORDER,
+		model.RejectReasonUnsupportedOrder:      enum.OrdRejReason_UNSUPPORTED_ORDER_CHARACTERISTIC,
+		model.RejectReasonIncorrectQuantity:     enum.OrdRejReason_INCORRECT_QUANTITY,
+		model.RejectReasonUnknownAccount:        enum.OrdRejReason_UNKNOWN_ACCOUNT,
+		model.RejectReasonPriceExceedsBand:      enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND,
+		model.RejectReasonInvalidPriceIncrement: enum.OrdRejReason_INVALID_PRICE_INCREMENT,
+		model.RejectReasonOther:                 enum.OrdRejReason_OTHER,
+	}
+
+	// reasons without a CxlRejReason(102) counterpart are sent as OTHER
+	CxlRejReasonMapping map[model.OrderRejectReason]enum.CxlRejReason = map[model.OrderRejectReason]enum.CxlRejReason{
+		model.RejectReasonBrokerOption:          enum.CxlRejReason_BROKER,
+		model.RejectReasonTooLateToEnter:        enum.CxlRejReason_TOO_LATE_TO_CANCEL,
+		model.RejectReasonUnknownOrder:          enum.CxlRejReason_UNKNOWN_ORDER,
+		model.RejectReasonDuplicateOrder:        enum.CxlRejReason_DUPLICATE_CLORDID,
+		model.RejectReasonPriceExceedsBand:      enum.CxlRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND,
+		model.RejectReasonInvalidPriceIncrement: enum.CxlRejReason_INVALID_PRICE_INCREMENT,
+	}
+
+	CxlRejResponseToMapping map[model.CancelRejectResponseTo]enum.CxlRejResponseTo = map[model.CancelRejectResponseTo]enum.CxlRejResponseTo{
+		model.CancelRejectResponseToCancel:  enum.CxlRejResponseTo_ORDER_CANCEL_REQUEST,
+		model.CancelRejectResponseToReplace: enum.CxlRejResponseTo_ORDER_CANCEL_REPLACE_REQUEST,
+	}
 )
 
 // ----- Pool setup -----
@@ -90,11 +143,15 @@ func resetMessage(m *quickfix.Message) {
 
 var execReportPool = NewMessagePool()
 
+// avgPxExtraDecimals is the precision AvgPx(6) has beyond the instrument
+// price, an average of fills falls between ticks.
+const avgPxExtraDecimals = 2
+
 // var newOrderSinglePool = NewMessagePool()
 
 var reportCount = int64(0)
 
-func orderReportToExecutionReport(order model.Order, sessionID *quickfix.SessionID) error {
+func orderReportToExecutionReport(order model.Order, priceDecimals int32, sessionID *quickfix.SessionID) error {
 	atomic.AddInt64(&reportCount, 1)
 	fmt.Println(reportCount)
 
@@ -111,57 +168,83 @@ func orderReportToExecutionReport(order model.Order, sessionID *quickfix.Session
 	// 	field.NewCumQty(decimal.NewFromInt(order.CumQuantity), 2),
 	// 	field.NewAvgPx(decimal.NewFromFloat(order.AvgPrice), 2),
 	// )
+	setExecutionReport(execReportMsg, order, priceDecimals)
+
+	err := quickfix.SendToTarget(execReportMsg, *sessionID)
+	if err != nil {
+		log.Printf("send err=%v", err)
+		return err
+	}
 
+	execReportPool.Put(msg)
+
+	return nil
+}
+
+// setExecutionReport fills msg from order, a rejected order carries
+// OrdRejReason(103) and Text(58). Prices are sent with the precision of the
+// instrument, AvgPx(6) with avgPxExtraDecimals more.
+func setExecutionReport(execReportMsg executionreport.ExecutionReport, order model.Order, priceDecimals int32) {
 	execReportMsg.SetMsgType(enum.MsgType_EXECUTION_REPORT)
 	execReportMsg.SetOrderID(order.OrderID)
 	execReportMsg.SetExecID(order.ExecID) //think again if it should be in Order model
-	execReportMsg.SetExecType(enum.ExecType(order.ExecType))
-	execReportMsg.SetOrdStatus(enum.OrdStatus(OrderStatusMapping[order.Status]))
+	execReportMsg.SetExecType(ExecTypeMapping[order.ExecType])
+	execReportMsg.SetOrdStatus(OrderStatusMapping[order.Status])
 	execReportMsg.SetSide(enum.Side(SideMapping[order.Side]))
+	if order.ShortSell {
+		execReportMsg.SetSide(enum.Side_SELL_SHORT)
+	}
 	execReportMsg.SetLeavesQty(decimal.NewFromInt(order.LeavesQuantity), 2)
 	execReportMsg.SetCumQty(decimal.NewFromInt(order.CumQuantity), 2)
-	execReportMsg.SetAvgPx(decimal.NewFromFloat(order.AvgPrice), 2)
+	execReportMsg.SetAvgPx(order.AvgPrice, priceDecimals+avgPxExtraDecimals)
 
 	execReportMsg.SetClOrdID(order.GatewayID)
 	execReportMsg.SetOrigClOrdID(order.OrigGatewayID)
 	execReportMsg.SetAccount(order.Account)
 	execReportMsg.SetAccountType(enum.AccountType(order.Account))
 	execReportMsg.SetOrderQty(decimal.NewFromInt(order.Quantity), 0)
-	execReportMsg.SetPrice(decimal.NewFromFloat(order.Price), 0)
+	execReportMsg.SetPrice(order.Price, priceDecimals)
 	execReportMsg.SetTimeInForce(enum.TimeInForce(order.TimeInForce))
 	execReportMsg.SetTransactTime(order.TransactTime)
 	execReportMsg.SetLastQty(decimal.NewFromInt(order.LastQuantity), 0)
-	execReportMsg.SetLastPx(decimal.NewFromFloat(order.LastPrice), 0)
+	execReportMsg.SetLastPx(order.LastPrice, priceDecimals)
 	execReportMsg.SetExecID(order.ExecID)
 
-	switch order.Status {
-	case model.OrderStatusPendingNew:
-		execReportMsg.SetExecType(enum.ExecType_PENDING_NEW)
-		execReportMsg.SetOrdStatus(enum.OrdStatus_PENDING_NEW)
-	case model.OrderStatusNew:
-		execReportMsg.SetExecType(enum.ExecType_NEW)
-		execReportMsg.SetOrdStatus(enum.OrdStatus_NEW)
-	case model.OrderStatusPartiallyFilled:
-		execReportMsg.SetExecType(enum.ExecType_TRADE)
-		execReportMsg.SetOrdStatus(enum.OrdStatus_PARTIALLY_FILLED)
-	case model.OrderStatusFilled:
-		execReportMsg.SetExecType(enum.ExecType_TRADE)
-		execReportMsg.SetOrdStatus(enum.OrdStatus_FILLED)
-	case model.OrderStatusCanceled:
-		execReportMsg.SetExecType(enum.ExecType_CANCELED)
-		execReportMsg.SetOrdStatus(enum.OrdStatus_CANCELED)
-	case model.OrderStatusReplaced:
-		execReportMsg.SetExecType(enum.ExecType_REPLACED)
-		execReportMsg.SetOrdStatus(enum.OrdStatus_REPLACED)
+	if order.ListID != "" {
+		execReportMsg.SetListID(order.ListID)
+	}
+	if order.Type == model.OrderTypeStop {
+		execReportMsg.SetStopPx(order.StopPrice, priceDecimals)
+	}
+	if order.Status == model.OrderStatusRejected {
+		execReportMsg.SetOrdRejReason(OrdRejReasonMapping[order.RejectReason])
+		execReportMsg.SetText(order.Text)
 	}
+}
 
-	err := quickfix.SendToTarget(execReportMsg, *sessionID)
+func cancelRejectToOrderCancelReject(cancelReject model.CancelReject, sessionID *quickfix.SessionID) error {
+	msg := ordercancelreject.New(
+		field.NewOrderID(cancelReject.OrderID),
+		field.NewClOrdID(cancelReject.GatewayID),
+		field.NewOrigClOrdID(cancelReject.OrigGatewayID),
+		field.NewOrdStatus(OrderStatusMapping[cancelReject.Status]),
+		field.NewCxlRejResponseTo(CxlRejResponseToMapping[cancelReject.ResponseTo]),
+	)
+	if cancelReject.Account != "" {
+		msg.SetAccount(cancelReject.Account)
+	}
+	reason, ok := CxlRejReasonMapping[cancelReject.Reason]
+	if !ok {
+		reason = enum.CxlRejReason_OTHER
+	}
+	msg.SetCxlRejReason(reason)
+	msg.SetText(cancelReject.Text)
+
+	err := quickfix.SendToTarget(msg, *sessionID)
 	if err != nil {
 		log.Printf("send err=%v", err)
 		return err
 	}
 
-	execReportPool.Put(msg)
-
 	return nil
 }
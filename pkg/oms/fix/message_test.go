@@ -8,6 +8,7 @@ import (
 	"github.com/quickfixgo/field"
 	"github.com/quickfixgo/fix44/executionreport"
 	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/tag"
 
 	"github.com/shopspring/decimal"
 
@@ -47,6 +48,14 @@ import (
 // 	msg.Trailer.Init()
 // }
 
+func getExecReport() executionreport.ExecutionReport {
+	return executionreport.FromMessage(execReportPool.Get())
+}
+
+func putExecReport(msg executionreport.ExecutionReport) {
+	execReportPool.Put(msg.Message)
+}
+
 // ----- Bench target function -----
 
 func orderReportToExecutionReportForBenchmark(order *model.Order) quickfix.Messagable {
@@ -58,13 +67,13 @@ func orderReportToExecutionReportForBenchmark(order *model.Order) quickfix.Messa
 		field.NewSide(enum.Side(order.Side)),
 		field.NewLeavesQty(decimal.NewFromInt(order.LeavesQuantity), 2),
 		field.NewCumQty(decimal.NewFromInt(order.CumQuantity), 2),
-		field.NewAvgPx(decimal.NewFromFloat(order.AvgPrice), 2),
+		field.NewAvgPx(order.AvgPrice, 2),
 	)
 	execReportMsg.SetClOrdID(order.GatewayID)
 	execReportMsg.SetOrigClOrdID(order.OrigGatewayID)
 	execReportMsg.SetAccount(order.Account)
 	execReportMsg.SetOrderQty(decimal.NewFromInt(order.Quantity), 0)
-	execReportMsg.SetPrice(decimal.NewFromFloat(order.Price), 0)
+	execReportMsg.SetPrice(order.Price, 0)
 	execReportMsg.SetTransactTime(order.TransactTime)
 	return execReportMsg
 }
@@ -78,12 +87,12 @@ func orderReportToExecutionReportPool(order *model.Order) quickfix.Messagable {
 	execReportMsg.Set(field.NewSide(enum.Side(order.Side)))
 	execReportMsg.Set(field.NewLeavesQty(decimal.NewFromInt(order.LeavesQuantity), 2))
 	execReportMsg.Set(field.NewCumQty(decimal.NewFromInt(order.CumQuantity), 2))
-	execReportMsg.Set(field.NewAvgPx(decimal.NewFromFloat(order.AvgPrice), 2))
+	execReportMsg.Set(field.NewAvgPx(order.AvgPrice, 2))
 	execReportMsg.SetClOrdID(order.GatewayID)
 	execReportMsg.SetOrigClOrdID(order.OrigGatewayID)
 	execReportMsg.SetAccount(order.Account)
 	execReportMsg.SetOrderQty(decimal.NewFromInt(order.Quantity), 0)
-	execReportMsg.SetPrice(decimal.NewFromFloat(order.Price), 0)
+	execReportMsg.SetPrice(order.Price, 0)
 	execReportMsg.SetTransactTime(order.TransactTime)
 
 	putExecReport(execReportMsg) // trả về pool
@@ -100,12 +109,12 @@ var testOrder = &model.Order{
 	Side:           "1",
 	LeavesQuantity: 100,
 	CumQuantity:    0,
-	AvgPrice:       100.5,
+	AvgPrice:       decimal.RequireFromString("100.5"),
 	GatewayID:      "C1",
 	OrigGatewayID:  "C0",
 	Account:        "ACC1",
 	Quantity:       100,
-	Price:          100.5,
+	Price:          decimal.RequireFromString("100.5"),
 	TransactTime:   time.Now(),
 }
 
@@ -120,3 +129,70 @@ func BenchmarkExecReportPool(b *testing.B) {
 		_ = orderReportToExecutionReportPool(testOrder)
 	}
 }
+
+func TestRejectedExecutionReport(t *testing.T) {
+	order := model.Order{
+		OrderID:      "O1",
+		GatewayID:    "C1",
+		Side:         model.OrderSideBuy,
+		Status:       model.OrderStatusRejected,
+		ExecType:     model.ExecTypeRejected,
+		RejectReason: model.RejectReasonDuplicateOrder,
+		Text:         "dupplicate order",
+	}
+	msg := executionreport.FromMessage(quickfix.NewMessage())
+	setExecutionReport(msg, order, 0)
+
+	if status, _ := msg.GetOrdStatus(); status != enum.OrdStatus_REJECTED {
+		t.Fatalf("expected OrdStatus rejected, got %v", status)
+	}
+	if execType, _ := msg.GetExecType(); execType != enum.ExecType_REJECTED {
+		t.Fatalf("expected ExecType rejected, got %v", execType)
+	}
+	if reason, _ := msg.GetOrdRejReason(); reason != enum.OrdRejReason_DUPLICATE_ORDER {
+		t.Fatalf("expected OrdRejReason duplicate, got %v", reason)
+	}
+	if text, _ := msg.GetText(); text != order.Text {
+		t.Fatalf("expected Text %q, got %q", order.Text, text)
+	}
+}
+
+func TestExecutionReportPricePrecision(t *testing.T) {
+	order := model.Order{
+		OrderID:      "O1",
+		GatewayID:    "C1",
+		Side:         model.OrderSideBuy,
+		Status:       model.OrderStatusPartiallyFilled,
+		ExecType:     model.ExecTypeTrade,
+		Price:        decimal.RequireFromString("10.5"),
+		LastPrice:    decimal.RequireFromString("10.4"),
+		AvgPrice:     decimal.RequireFromString("10.43333333"),
+		Quantity:     300,
+		LastQuantity: 100,
+	}
+
+	tests := []struct {
+		decimals             int32
+		price, lastPx, avgPx string
+	}{
+		{0, "11", "10", "10.43"},
+		{1, "10.5", "10.4", "10.433"},
+	}
+	for _, tt := range tests {
+		msg := executionreport.FromMessage(quickfix.NewMessage())
+		setExecutionReport(msg, order, tt.decimals)
+
+		price, _ := msg.Body.GetString(tag.Price)
+		lastPx, _ := msg.Body.GetString(tag.LastPx)
+		avgPx, _ := msg.Body.GetString(tag.AvgPx)
+		if price != tt.price {
+			t.Errorf("decimals=%d: expected Price %s, got %s", tt.decimals, tt.price, price)
+		}
+		if lastPx != tt.lastPx {
+			t.Errorf("decimals=%d: expected LastPx %s, got %s", tt.decimals, tt.lastPx, lastPx)
+		}
+		if avgPx != tt.avgPx {
+			t.Errorf("decimals=%d: expected AvgPx %s, got %s", tt.decimals, tt.avgPx, avgPx)
+		}
+	}
+}
@@ -30,7 +30,19 @@ type NewOrderSingle struct {
 	OrderQty          decimal.Decimal
 	MaturityMonthYear string
 
-	MaxFloor decimal.Decimal
+	MaxFloor       decimal.Decimal
+	Hidden         bool // MaxFloor(111) sent as zero
+	StopPx         decimal.Decimal
+	ExecInst       enum.ExecInst
+	PegOffsetValue decimal.Decimal
+}
+
+type NewOrderList struct {
+	SessionID *quickfix.SessionID
+
+	ListID          string
+	ContingencyType enum.ContingencyType
+	Orders          []*NewOrderSingle
 }
 
 type OrderCancelRequest struct {
@@ -74,3 +86,60 @@ type OrderCancelReplaceRequest struct {
 	TimeInForce       enum.TimeInForce
 	MaturityMonthYear string
 }
+
+type NewOrderCross struct {
+	SessionID *quickfix.SessionID
+
+	CrossID      string
+	CrossType    enum.CrossType
+	Symbol       string
+	SecurityID   string
+	OrdType      enum.OrdType
+	Price        decimal.Decimal
+	TransactTime time.Time
+	Sides        []*CrossSide
+}
+
+type CrossSide struct {
+	Side     enum.Side
+	ClOrdID  string
+	Account  string
+	OrderQty decimal.Decimal
+}
+
+type Quote struct {
+	SessionID *quickfix.SessionID
+
+	QuoteID      string
+	Account      string
+	Symbol       string
+	SecurityID   string
+	BidPx        decimal.Decimal
+	BidSize      decimal.Decimal
+	OfferPx      decimal.Decimal
+	OfferSize    decimal.Decimal
+	TransactTime time.Time
+}
+
+type MassQuote struct {
+	SessionID *quickfix.SessionID
+
+	QuoteID   string
+	Account   string
+	QuoteSets []*QuoteSet
+}
+
+type QuoteSet struct {
+	QuoteSetID string
+	Entries    []*QuoteEntry
+}
+
+type QuoteEntry struct {
+	QuoteEntryID string
+	Symbol       string
+	SecurityID   string
+	BidPx        decimal.Decimal
+	BidSize      decimal.Decimal
+	OfferPx      decimal.Decimal
+	OfferSize    decimal.Decimal
+}
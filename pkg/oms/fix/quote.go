@@ -0,0 +1,162 @@
+package fixgateway
+
+import (
+	"context"
+	"log"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/fix44/massquoteacknowledgement"
+	"github.com/quickfixgo/fix44/quotestatusreport"
+	"github.com/quickfixgo/quickfix"
+)
+
+// Quote replaces the two-sided quote of the account on the symbol and
+// answers with a QuoteStatusReport(35=AI). Fills of the quote come back as
+// execution reports with the ClOrdID of the side, QuoteID-B or QuoteID-S.
+func (s *FixGateway) Quote(ctx context.Context, quote *Quote) {
+	s.trackQuoteAccount(*quote.SessionID, quote.Account)
+
+	q := &model.Quote{
+		QuoteID:      quote.QuoteID,
+		Account:      quote.Account,
+		Symbol:       quote.Symbol,
+		SecurityID:   quote.SecurityID,
+		BidPx:        quote.BidPx,
+		BidSize:      quote.BidSize,
+		OfferPx:      quote.OfferPx,
+		OfferSize:    quote.OfferSize,
+		TransactTime: quote.TransactTime,
+		SessionID:    quote.SessionID.String(),
+	}
+	s.addQuoteSidesToMap(q, quote.SessionID)
+	err := s.omsInstance.Quote(ctx, q)
+
+	msg := quotestatusreport.New(field.NewQuoteID(quote.QuoteID))
+	msg.SetAccount(quote.Account)
+	msg.SetSymbol(quote.Symbol)
+	msg.SetQuoteStatus(enum.QuoteStatus_ACCEPTED)
+	if err != nil {
+		msg.SetQuoteStatus(enum.QuoteStatus_REJECTED)
+		msg.SetText(err.Error())
+	}
+	if err := quickfix.SendToTarget(msg, *quote.SessionID); err != nil {
+		log.Printf("send quote status QuoteID=%s err=%v", quote.QuoteID, err)
+	}
+}
+
+// MassQuote applies every quote entry on its own and answers with a
+// MassQuoteAck(35=b) listing the rejected entries. Fills come back with the
+// ClOrdID QuoteID-QuoteEntryID-B or -S.
+func (s *FixGateway) MassQuote(ctx context.Context, massQuote *MassQuote) {
+	s.trackQuoteAccount(*massQuote.SessionID, massQuote.Account)
+
+	req := &model.MassQuote{
+		QuoteID:   massQuote.QuoteID,
+		Account:   massQuote.Account,
+		SessionID: massQuote.SessionID.String(),
+	}
+	for _, set := range massQuote.QuoteSets {
+		for _, entry := range set.Entries {
+			req.Quotes = append(req.Quotes, &model.Quote{
+				QuoteEntryID: entry.QuoteEntryID,
+				Symbol:       entry.Symbol,
+				SecurityID:   entry.SecurityID,
+				BidPx:        entry.BidPx,
+				BidSize:      entry.BidSize,
+				OfferPx:      entry.OfferPx,
+				OfferSize:    entry.OfferSize,
+			})
+		}
+	}
+	for _, q := range req.Quotes {
+		q.QuoteID = massQuote.QuoteID
+		s.addQuoteSidesToMap(q, massQuote.SessionID)
+	}
+
+	errs := s.omsInstance.MassQuote(ctx, req)
+	msg := newMassQuoteAck(massQuote, errs)
+	if err := quickfix.SendToTarget(msg, *massQuote.SessionID); err != nil {
+		log.Printf("send mass quote ack QuoteID=%s err=%v", massQuote.QuoteID, err)
+	}
+}
+
+// newMassQuoteAck builds the ack of massQuote, errs holds one result per
+// entry in message order. The ack is rejected when no entry was accepted.
+func newMassQuoteAck(massQuote *MassQuote, errs []error) massquoteacknowledgement.MassQuoteAcknowledgement {
+	msg := massquoteacknowledgement.New(field.NewQuoteStatus(enum.QuoteStatus_ACCEPTED))
+	msg.SetQuoteID(massQuote.QuoteID)
+	msg.SetAccount(massQuote.Account)
+
+	sets := massquoteacknowledgement.NewNoQuoteSetsRepeatingGroup()
+	rejected, i := 0, 0
+	for _, set := range massQuote.QuoteSets {
+		var entries *massquoteacknowledgement.NoQuoteEntriesRepeatingGroup
+		for _, entry := range set.Entries {
+			err := errs[i]
+			i++
+			if err == nil {
+				continue
+			}
+			rejected++
+			if entries == nil {
+				group := massquoteacknowledgement.NewNoQuoteEntriesRepeatingGroup()
+				entries = &group
+			}
+			e := entries.Add()
+			e.SetQuoteEntryID(entry.QuoteEntryID)
+			e.SetSymbol(entry.Symbol)
+			e.SetQuoteEntryRejectReason(enum.QuoteEntryRejectReason_OTHER)
+		}
+		if entries != nil {
+			quoteSet := sets.Add()
+			quoteSet.SetQuoteSetID(set.QuoteSetID)
+			quoteSet.SetTotNoQuoteEntries(entries.Len())
+			quoteSet.SetNoQuoteEntries(*entries)
+		}
+	}
+
+	if rejected > 0 {
+		msg.SetNoQuoteSets(sets)
+		if rejected == len(errs) {
+			msg.SetQuoteStatus(enum.QuoteStatus_REJECTED)
+		}
+	}
+
+	return msg
+}
+
+// addQuoteSidesToMap routes the execution reports of both sides of q to
+// sessionID.
+func (s *FixGateway) addQuoteSidesToMap(q *model.Quote, sessionID *quickfix.SessionID) {
+	s.AddRequestToMap(q.SideGatewayID(model.OrderSideBuy), sessionID)
+	s.AddRequestToMap(q.SideGatewayID(model.OrderSideSell), sessionID)
+}
+
+func (s *FixGateway) trackQuoteAccount(sessionID quickfix.SessionID, account string) {
+	s.quoteMu.Lock()
+	defer s.quoteMu.Unlock()
+
+	accounts, ok := s.quoteAccounts[sessionID]
+	if !ok {
+		accounts = make(map[string]struct{})
+		s.quoteAccounts[sessionID] = accounts
+	}
+	accounts[account] = struct{}{}
+}
+
+// CancelSessionQuotes pulls every quote entered through sessionID, called on
+// logout so a disconnected market maker never leaves quotes in the book.
+func (s *FixGateway) CancelSessionQuotes(ctx context.Context, sessionID quickfix.SessionID) {
+	s.quoteMu.Lock()
+	accounts := s.quoteAccounts[sessionID]
+	delete(s.quoteAccounts, sessionID)
+	s.quoteMu.Unlock()
+
+	for account := range accounts {
+		if err := s.omsInstance.CancelQuotes(ctx, account, ""); err != nil {
+			log.Printf("cancel quotes account=%s err=%v", account, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package fixgateway
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+)
+
+func TestNewMassQuoteAckListsRejectedEntries(t *testing.T) {
+	massQuote := &MassQuote{
+		QuoteID: "MQ1",
+		Account: "MM",
+		QuoteSets: []*QuoteSet{
+			{QuoteSetID: "1", Entries: []*QuoteEntry{{QuoteEntryID: "1", Symbol: "AAA"}, {QuoteEntryID: "2", Symbol: "BBB"}}},
+			{QuoteSetID: "2", Entries: []*QuoteEntry{{QuoteEntryID: "1", Symbol: "CCC"}}},
+		},
+	}
+
+	msg := newMassQuoteAck(massQuote, []error{nil, errors.New("invalid quote"), nil})
+	if status, _ := msg.GetQuoteStatus(); status != enum.QuoteStatus_ACCEPTED {
+		t.Fatalf("expected accepted, got %s", status)
+	}
+	sets, err := msg.GetNoQuoteSets()
+	if err != nil || sets.Len() != 1 {
+		t.Fatalf("expected one quote set, err=%v", err)
+	}
+	set := sets.Get(0)
+	if id, _ := set.GetQuoteSetID(); id != "1" {
+		t.Fatalf("expected set 1, got %s", id)
+	}
+	entries, _ := set.GetNoQuoteEntries()
+	if entries.Len() != 1 {
+		t.Fatalf("expected one rejected entry, got %d", entries.Len())
+	}
+	if symbol, _ := entries.Get(0).GetSymbol(); symbol != "BBB" {
+		t.Fatalf("expected BBB rejected, got %s", symbol)
+	}
+
+	msg = newMassQuoteAck(massQuote, []error{errors.New("x"), errors.New("x"), errors.New("x")})
+	if status, _ := msg.GetQuoteStatus(); status != enum.QuoteStatus_REJECTED {
+		t.Fatalf("expected rejected, got %s", status)
+	}
+}
@@ -0,0 +1,177 @@
+package fixgateway
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/misc"
+	"github.com/quickfixgo/fix44/logout"
+	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/tag"
+)
+
+type ThrottleAction string
+
+const (
+	// ThrottleActionReject answers a throttled message with a
+	// BusinessMessageReject (35=j).
+	ThrottleActionReject ThrottleAction = "reject"
+	// ThrottleActionDisconnect logs the session out.
+	ThrottleActionDisconnect ThrottleAction = "disconnect"
+)
+
+// ThrottleConfig sets token-bucket throttles on inbound application messages,
+// a zero rate disables the throttle of that level.
+type ThrottleConfig struct {
+	SessionRate  float64 `yaml:"session_rate"` // messages per second
+	SessionBurst int     `yaml:"session_burst"`
+	AccountRate  float64 `yaml:"account_rate"`
+	AccountBurst int     `yaml:"account_burst"`
+
+	Action ThrottleAction `yaml:"action"`
+}
+
+// ThrottleStats counts the messages of one session or account.
+type ThrottleStats struct {
+	Key       string `json:"key"`
+	Allowed   int64  `json:"allowed"`
+	Throttled int64  `json:"throttled"`
+}
+
+// tokenBucket refills rate tokens per second up to burst, every message
+// takes one token.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+
+	allowed   int64
+	throttled int64
+}
+
+type throttle struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newThrottle(rate float64, burst int) *throttle {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &throttle{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token of key, a nil throttle allows everything.
+func (t *throttle) allow(key string) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	b, ok := t.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: t.burst, last: now}
+		t.buckets[key] = b
+	}
+	b.tokens = min(t.burst, b.tokens+now.Sub(b.last).Seconds()*t.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		b.throttled++
+		return false
+	}
+	b.tokens--
+	b.allowed++
+	return true
+}
+
+func (t *throttle) stats() []ThrottleStats {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make([]ThrottleStats, 0, len(t.buckets))
+	for key, b := range t.buckets {
+		stats = append(stats, ThrottleStats{Key: key, Allowed: b.allowed, Throttled: b.throttled})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats
+}
+
+const throttleText = "throttle limit exceeded"
+
+// BusinessRejectReason(380) OTHER
+const businessRejectOther = 0
+
+// throttle takes a token of the session and of the account of msg. A
+// throttled message is dropped, rejectErr is nil when the session is logged
+// out instead of rejected.
+func (a *Application) throttle(msg *quickfix.Message, sessionID quickfix.SessionID) (ok bool, rejectErr quickfix.MessageRejectError) {
+	ok = a.sessionThrottle.allow(sessionID.String())
+	if ok {
+		if account, err := msg.Body.GetString(tag.Account); err == nil && account != "" {
+			ok = a.accountThrottle.allow(account)
+		}
+	}
+	if ok {
+		return true, nil
+	}
+
+	if a.cfg.throttle.Action == ThrottleActionDisconnect {
+		logoutMsg := logout.New()
+		logoutMsg.SetText(throttleText)
+		if err := quickfix.SendToTarget(logoutMsg, sessionID); err != nil {
+			log.Printf("throttle logout session=%s err=%v", sessionID, err)
+		}
+		return false, nil
+	}
+
+	refID, _ := msg.Body.GetString(tag.ClOrdID)
+	return false, quickfix.NewBusinessMessageRejectErrorWithRefID(throttleText, businessRejectOther, refID, nil)
+}
+
+// ThrottleReport lists the throttle counters of every session and account.
+type ThrottleReport struct {
+	Sessions []ThrottleStats `json:"sessions"`
+	Accounts []ThrottleStats `json:"accounts"`
+}
+
+func (s *FixGateway) ThrottleStats() ThrottleReport {
+	if s.app == nil {
+		return ThrottleReport{}
+	}
+	return ThrottleReport{
+		Sessions: s.app.sessionThrottle.stats(),
+		Accounts: s.app.accountThrottle.stats(),
+	}
+}
+
+// LoadThrottleConfig reads a throttle config from a YAML file (see
+// misc.LoadYAML).
+func LoadThrottleConfig(path string) (*ThrottleConfig, error) {
+	cfg := &ThrottleConfig{}
+	if err := misc.LoadYAML(path, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,72 @@
+package fixgateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/fix44/newordersingle"
+	"github.com/quickfixgo/quickfix"
+)
+
+func TestTokenBucketRefills(t *testing.T) {
+	now := time.Unix(0, 0)
+	th := newThrottle(10, 2)
+	th.now = func() time.Time { return now }
+
+	if !th.allow("S") || !th.allow("S") {
+		t.Fatal("expected the burst to be allowed")
+	}
+	if th.allow("S") {
+		t.Fatal("expected the third message to be throttled")
+	}
+	if !th.allow("OTHER") {
+		t.Fatal("expected keys to have their own bucket")
+	}
+
+	now = now.Add(100 * time.Millisecond) // one token at 10/s
+	if !th.allow("S") || th.allow("S") {
+		t.Fatal("expected one refilled token")
+	}
+
+	stats := th.stats()
+	if len(stats) != 2 || stats[1].Key != "S" || stats[1].Allowed != 3 || stats[1].Throttled != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestApplicationThrottlesAccount(t *testing.T) {
+	app := newApplication(AppConfig{throttle: &ThrottleConfig{
+		AccountRate:  1,
+		AccountBurst: 1,
+		Action:       ThrottleActionReject,
+	}}, nil)
+	sessionID := quickfix.SessionID{BeginString: "FIX.4.4", SenderCompID: "OMS", TargetCompID: "CLIENT"}
+
+	newMsg := func(clOrdID, account string) *quickfix.Message {
+		order := newordersingle.New(
+			field.NewClOrdID(clOrdID),
+			field.NewSide(enum.Side_BUY),
+			field.NewTransactTime(time.Now()),
+			field.NewOrdType(enum.OrdType_LIMIT),
+		)
+		order.SetAccount(account)
+		return order.ToMessage()
+	}
+
+	if ok, _ := app.throttle(newMsg("C1", "A1"), sessionID); !ok {
+		t.Fatal("expected first order to pass")
+	}
+	ok, rejectErr := app.throttle(newMsg("C2", "A1"), sessionID)
+	if ok || rejectErr == nil || !rejectErr.IsBusinessReject() || rejectErr.BusinessRejectRefID() != "C2" {
+		t.Fatalf("expected business reject of C2, got ok=%v err=%v", ok, rejectErr)
+	}
+	if ok, _ := app.throttle(newMsg("C3", "A2"), sessionID); !ok {
+		t.Fatal("expected another account to pass")
+	}
+	// no session throttle configured
+	if app.sessionThrottle != nil {
+		t.Fatal("expected session throttle disabled")
+	}
+}
@@ -0,0 +1,142 @@
+package idgen
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIDsAreUniqueAndIncreasing(t *testing.T) {
+	g, err := NewGenerator(&Config{Node: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, perWorker = 8, 10_000
+	var mu sync.Mutex
+	seen := make(map[int64]struct{}, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prev := int64(-1)
+			local := make([]int64, 0, perWorker)
+			for i := 0; i < perWorker; i++ {
+				id := g.Next()
+				if id <= prev {
+					t.Errorf("id %d not after %d", id, prev)
+					return
+				}
+				prev = id
+				local = append(local, id)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range local {
+				if _, ok := seen[id]; ok {
+					t.Errorf("duplicate id %d", id)
+					return
+				}
+				seen[id] = struct{}{}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNodesNeverCollide(t *testing.T) {
+	clock := FixedClock(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	a, _ := NewGenerator(&Config{Node: 1, Now: clock})
+	b, _ := NewGenerator(&Config{Node: 2, Now: clock})
+
+	if n := Node(b.Next()); n != 2 {
+		t.Fatalf("expected node 2, got %d", n)
+	}
+
+	seen := make(map[int64]struct{})
+	for i := 0; i < 10_000; i++ {
+		for _, id := range []int64{a.Next(), b.Next()} {
+			if _, ok := seen[id]; ok {
+				t.Fatalf("duplicate id %d", id)
+			}
+			seen[id] = struct{}{}
+		}
+	}
+}
+
+func TestStringsSortLikeIDs(t *testing.T) {
+	g, _ := NewGenerator(&Config{Node: MaxNode})
+	ids := make([]string, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		ids = append(ids, g.NextString())
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Fatal("expected IDs to sort as strings")
+	}
+	id, err := Parse(ids[0])
+	if err != nil || Format(id) != ids[0] {
+		t.Fatalf("expected %s to round trip, got %d err=%v", ids[0], id, err)
+	}
+}
+
+func TestClockGoingBack(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	g, _ := NewGenerator(&Config{Now: func() time.Time { return now }})
+
+	first := g.Next()
+	now = now.Add(-time.Minute)
+	if second := g.Next(); second <= first {
+		t.Fatalf("expected %d after %d with the clock back", second, first)
+	}
+}
+
+func TestSequenceOverflowBorrowsNextMillisecond(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	g, _ := NewGenerator(&Config{Now: FixedClock(start)})
+
+	var last int64
+	for i := 0; i <= maxSequence+1; i++ {
+		last = g.Next()
+	}
+	if got := Time(last); !got.Equal(start.Add(time.Millisecond)) {
+		t.Fatalf("expected the next millisecond, got %v", got)
+	}
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	clock := FixedClock(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	a, _ := NewGenerator(&Config{Node: 3, Now: clock})
+	b, _ := NewGenerator(&Config{Node: 3, Now: clock})
+	for i := 0; i < 10_000; i++ {
+		if x, y := a.Next(), b.Next(); x != y {
+			t.Fatalf("replay diverged at %d: %d != %d", i, x, y)
+		}
+	}
+}
+
+func TestObserveSurvivesRestart(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	before, _ := NewGenerator(&Config{Now: FixedClock(now)})
+	var last int64
+	for i := 0; i < 100; i++ {
+		last = before.Next()
+	}
+
+	// restarted with a clock behind the last ID of the log
+	after, _ := NewGenerator(&Config{Now: FixedClock(now.Add(-time.Second))})
+	after.Observe(last)
+	if id := after.Next(); id <= last {
+		t.Fatalf("expected %d after %d", id, last)
+	}
+}
+
+func TestInvalidNode(t *testing.T) {
+	for _, node := range []int64{-1, MaxNode + 1} {
+		if _, err := NewGenerator(&Config{Node: node}); !errors.Is(err, ErrInvalidNode) {
+			t.Fatalf("node %d: expected ErrInvalidNode, got %v", node, err)
+		}
+	}
+}
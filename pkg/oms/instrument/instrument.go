@@ -0,0 +1,141 @@
+package instrument
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Instrument is one entry of the instrument master (config/market_data.json).
+type Instrument struct {
+	Symbol           string  `json:"symbol"`
+	Exchange         string  `json:"exchange"`
+	StockType        string  `json:"stock_type"`
+	ISIN             string  `json:"isin"`
+	Name             string  `json:"name"`
+	BoardLot         int64   `json:"board_lot"`
+	Ceil             float64 `json:"ceil"`
+	Ref              float64 `json:"ref"`
+	Floor            float64 `json:"floor"`
+	TradingSessionID string  `json:"trading_session_id"`
+	PriorClosePrice  float64 `json:"prior_close_price"`
+	IsSuspended      bool    `json:"is_suspended"`
+	HaltState        string  `json:"halt_state"`
+	MarketHaltState  string  `json:"market_halt_state"`
+	SecurityStatus   string  `json:"security_status"`
+	// PriceDecimals is the number of decimals of order prices, 0 for whole
+	// VND.
+	PriceDecimals int32 `json:"price_decimals"`
+}
+
+// Store keeps the instrument master in memory, keyed by symbol and ISIN.
+type Store struct {
+	mu          sync.RWMutex
+	instruments map[string]*Instrument
+	isins       map[string]*Instrument
+}
+
+func NewStore(instruments []*Instrument) *Store {
+	s := &Store{}
+	s.Replace(instruments)
+	return s
+}
+
+// Load instrument master từ file JSON
+func NewStoreFromFile(path string) (*Store, error) {
+	instruments, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStore(instruments), nil
+}
+
+func LoadFile(path string) ([]*Instrument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse reads the instrument master from the content of a JSON file.
+func Parse(data []byte) ([]*Instrument, error) {
+	var instruments []*Instrument
+	if err := json.Unmarshal(data, &instruments); err != nil {
+		return nil, err
+	}
+
+	return instruments, nil
+}
+
+// Get looks symbol up as a symbol then as an ISIN, some clients send the
+// ISIN in Symbol(55).
+func (s *Store) Get(symbol string) (*Instrument, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if instrument, ok := s.instruments[symbol]; ok {
+		return instrument, true
+	}
+	instrument, ok := s.isins[symbol]
+	return instrument, ok
+}
+
+// PriceDecimals returns the price precision of symbol, 0 when unknown.
+func (s *Store) PriceDecimals(symbol string) int32 {
+	if instrument, ok := s.Get(symbol); ok {
+		return instrument.PriceDecimals
+	}
+	return 0
+}
+
+// Replace swaps the whole instrument master.
+func (s *Store) Replace(instruments []*Instrument) {
+	m := make(map[string]*Instrument, len(instruments))
+	isins := make(map[string]*Instrument, len(instruments))
+	for _, instrument := range instruments {
+		m[instrument.Symbol] = instrument
+		if instrument.ISIN != "" {
+			isins[instrument.ISIN] = instrument
+		}
+	}
+
+	s.mu.Lock()
+	s.instruments = m
+	s.isins = isins
+	s.mu.Unlock()
+}
+
+// Validate checks an instrument master before it replaces the one in use:
+// unique symbols and floor <= ref <= ceil when a ceil is published.
+func Validate(instruments []*Instrument) error {
+	if len(instruments) == 0 {
+		return errors.New("empty instrument master")
+	}
+
+	seen := make(map[string]struct{}, len(instruments))
+	for _, i := range instruments {
+		if i.Symbol == "" {
+			return errors.New("instrument without symbol")
+		}
+		if _, ok := seen[i.Symbol]; ok {
+			return fmt.Errorf("instrument %s: duplicate symbol", i.Symbol)
+		}
+		seen[i.Symbol] = struct{}{}
+
+		if i.BoardLot < 0 || i.Ceil < 0 || i.Ref < 0 || i.Floor < 0 || i.PriceDecimals < 0 {
+			return fmt.Errorf("instrument %s: negative value", i.Symbol)
+		}
+		if i.Ceil == 0 {
+			continue
+		}
+		if i.Floor > i.Ceil || (i.Ref > 0 && (i.Ref < i.Floor || i.Ref > i.Ceil)) {
+			return fmt.Errorf("instrument %s: invalid band floor=%v ref=%v ceil=%v", i.Symbol, i.Floor, i.Ref, i.Ceil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package oms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
+)
+
+// audit actions of kill switches, engage and release are replayed by
+// RestoreKillSwitches
+const (
+	actionKillSwitchEngage     = "kill_switch.engage"
+	actionKillSwitchRelease    = "kill_switch.release"
+	actionKillSwitchMassCancel = "kill_switch.mass_cancel"
+)
+
+func killSwitchKey(scope model.KillSwitchScope, value string) string {
+	return string(scope) + "|" + value
+}
+
+func validateKillSwitch(ks *model.KillSwitch) error {
+	switch ks.Scope {
+	case model.KillSwitchScopeFirm:
+		if ks.Value != "" {
+			return errInvalidKillSwitch
+		}
+	case model.KillSwitchScopeAccount, model.KillSwitchScopeSession, model.KillSwitchScopeSymbol:
+		if ks.Value == "" {
+			return errInvalidKillSwitch
+		}
+	default:
+		return errInvalidKillSwitch
+	}
+	return nil
+}
+
+// EngageKillSwitch blocks new orders, replaces and quotes in the scope of ks
+// until ReleaseKillSwitch, and mass-cancels the resting orders of the scope
+// when ks.CancelOrders is set. The switch is audited before it takes effect,
+// a crash never leaves it engaged without a trace.
+// It changes orders, callers run it in turn with the order requests.
+func (s *OMS) EngageKillSwitch(ctx context.Context, ks *model.KillSwitch) error {
+	if err := validateKillSwitch(ks); err != nil {
+		return err
+	}
+
+	engaged := *ks
+	engaged.EngagedAt = time.Now()
+	s.audit.Record(ks.Operator, actionKillSwitchEngage, map[string]interface{}{
+		"kill_switch": engaged,
+	})
+	s.killMu.Lock()
+	s.killSwitches[killSwitchKey(ks.Scope, ks.Value)] = &engaged
+	s.killMu.Unlock()
+
+	if engaged.CancelOrders {
+		canceled := s.massCancel(ctx, &engaged)
+		s.audit.Record(ks.Operator, actionKillSwitchMassCancel, map[string]interface{}{
+			"kill_switch": engaged,
+			"canceled":    canceled,
+		})
+	}
+
+	return nil
+}
+
+// ReleaseKillSwitch lets the scope trade again, orders canceled when the
+// switch was engaged stay canceled.
+func (s *OMS) ReleaseKillSwitch(ctx context.Context, ks *model.KillSwitch) error {
+	key := killSwitchKey(ks.Scope, ks.Value)
+	s.killMu.Lock()
+	defer s.killMu.Unlock()
+	engaged, ok := s.killSwitches[key]
+	if !ok {
+		return errKillSwitchNotFound
+	}
+
+	s.audit.Record(ks.Operator, actionKillSwitchRelease, map[string]interface{}{
+		"kill_switch": *engaged,
+		"reason":      ks.Reason,
+	})
+	delete(s.killSwitches, key)
+
+	return nil
+}
+
+// RestoreKillSwitches engages again the switches the audit log leaves
+// engaged, a switch stays in effect across restarts until it is released.
+// It must run before Start, Recover runs it.
+func (s *OMS) RestoreKillSwitches(ctx context.Context) error {
+	entries, err := s.audit.Entries()
+	if err != nil {
+		return err
+	}
+
+	s.killMu.Lock()
+	defer s.killMu.Unlock()
+
+	for _, entry := range entries {
+		if entry.Action != actionKillSwitchEngage && entry.Action != actionKillSwitchRelease {
+			continue
+		}
+		var detail struct {
+			KillSwitch model.KillSwitch `json:"kill_switch"`
+		}
+		if err := json.Unmarshal(entry.Detail, &detail); err != nil {
+			return err
+		}
+		ks := detail.KillSwitch
+		key := killSwitchKey(ks.Scope, ks.Value)
+		if entry.Action == actionKillSwitchRelease {
+			delete(s.killSwitches, key)
+			continue
+		}
+		s.killSwitches[key] = &ks
+	}
+	return nil
+}
+
+// KillSwitches returns the engaged switches, oldest first.
+func (s *OMS) KillSwitches(ctx context.Context) ([]model.KillSwitch, error) {
+	s.killMu.RLock()
+	switches := make([]model.KillSwitch, 0, len(s.killSwitches))
+	for _, ks := range s.killSwitches {
+		switches = append(switches, *ks)
+	}
+	s.killMu.RUnlock()
+
+	sort.Slice(switches, func(i, j int) bool { return switches[i].EngagedAt.Before(switches[j].EngagedAt) })
+	return switches, nil
+}
+
+// checkKillSwitch runs first on the entry path, before any risk rule.
+func (s *OMS) checkKillSwitch(account, sessionID, symbol string) error {
+	s.killMu.RLock()
+	defer s.killMu.RUnlock()
+
+	for _, ks := range s.killSwitches {
+		if ks.Matches(account, sessionID, symbol) {
+			return riskrule.Reject(model.RejectReasonBrokerOption,
+				fmt.Sprintf("kill switch engaged: %s %s", ks.Scope, ks.Value))
+		}
+	}
+	return nil
+}
+
+// massCancel cancels the resting orders and pulls the quotes in the scope of
+// ks, it returns the number of canceled orders.
+func (s *OMS) massCancel(ctx context.Context, ks *model.KillSwitch) int {
+	var orders []*model.Order
+	s.orderIDMapping.Range(func(k, v any) bool {
+		order := v.(*model.Order)
+		if order.QuoteID == "" && !order.IsEnd() && order.CanCancel() &&
+			ks.Matches(order.Account, order.SessionID, order.Symbol) {
+			orders = append(orders, order)
+		}
+		return true
+	})
+	for _, order := range orders {
+		s.cancelListOrder(ctx, order)
+	}
+
+	s.quoteMu.Lock()
+	defer s.quoteMu.Unlock()
+	for key, q := range s.quotes {
+		if !ks.Matches(q.account, q.sessionID, q.symbol) {
+			continue
+		}
+		s.orderbookManager.ReplaceQuote(q.symbol, q.liveIDs())
+		s.pullQuote(q)
+		delete(s.quotes, key)
+	}
+
+	return len(orders)
+}
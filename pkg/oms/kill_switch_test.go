@@ -0,0 +1,154 @@
+package oms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/audit"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func TestKillSwitchBlocksAndCancelsAccount(t *testing.T) {
+	var buf bytes.Buffer
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Audit: audit.NewLog(&buf)})
+	defer s.Stop()
+	ctx := context.Background()
+
+	resting := newAddOrder("K1", model.OrderSideBuy, model.OrderTypeLimit, 10, 100)
+	resting.Account = "ACC-K"
+	if err := s.AddOrder(ctx, resting); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	other := newAddOrder("O1", model.OrderSideBuy, model.OrderTypeLimit, 10, 100)
+	if err := s.AddOrder(ctx, other); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+
+	ks := &model.KillSwitch{Scope: model.KillSwitchScopeAccount, Value: "ACC-K", CancelOrders: true, Operator: "ops"}
+	if err := s.EngageKillSwitch(ctx, ks); err != nil {
+		t.Fatalf("engage err=%v", err)
+	}
+	if r := gw.lastReport("K1"); r == nil || r.Status != model.OrderStatusCanceled {
+		t.Fatalf("expected resting order canceled, got %+v", r)
+	}
+	if r := gw.lastReport("O1"); r == nil || r.Status != model.OrderStatusNew {
+		t.Fatalf("expected other account untouched, got %+v", r)
+	}
+
+	blocked := newAddOrder("K2", model.OrderSideSell, model.OrderTypeLimit, 10, 100)
+	blocked.Account = "ACC-K"
+	if err := s.AddOrder(ctx, blocked); err == nil {
+		t.Fatal("expected order to be blocked by the kill switch")
+	}
+	if r := gw.lastReport("K2"); r == nil || r.Status != model.OrderStatusRejected || !strings.Contains(r.Text, "kill switch") {
+		t.Fatalf("expected kill switch reject, got %+v", r)
+	}
+
+	if err := s.ReleaseKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeAccount, Value: "ACC-K", Operator: "ops"}); err != nil {
+		t.Fatalf("release err=%v", err)
+	}
+	if err := s.ReleaseKillSwitch(ctx, ks); err != errKillSwitchNotFound {
+		t.Fatalf("expected not found on second release, got %v", err)
+	}
+	again := newAddOrder("K3", model.OrderSideSell, model.OrderTypeLimit, 11, 100)
+	again.Account = "ACC-K"
+	if err := s.AddOrder(ctx, again); err != nil {
+		t.Fatalf("add after release err=%v", err)
+	}
+
+	var actions []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry audit.Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("audit line %q err=%v", line, err)
+		}
+		if entry.Actor != "ops" {
+			t.Fatalf("unexpected actor %q", entry.Actor)
+		}
+		actions = append(actions, entry.Action)
+	}
+	if strings.Join(actions, ",") != "kill_switch.engage,kill_switch.mass_cancel,kill_switch.release" {
+		t.Fatalf("unexpected audit actions %v", actions)
+	}
+}
+
+func TestKillSwitchFirmBlocksReplace(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("F1", model.OrderSideBuy, model.OrderTypeLimit, 10, 100)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if err := s.EngageKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeFirm}); err != nil {
+		t.Fatalf("engage err=%v", err)
+	}
+	if r := gw.lastReport("F1"); r == nil || r.Status != model.OrderStatusNew {
+		t.Fatalf("expected order to keep resting without cancel, got %+v", r)
+	}
+
+	err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID: "F1-R", OrigGatewayID: "F1",
+		NewPrice: decimal.NewFromInt(11), NewQuantity: decimal.NewFromInt(100),
+	})
+	if err == nil || len(gw.cancelRejects) != 1 {
+		t.Fatalf("expected replace reject, err=%v rejects=%d", err, len(gw.cancelRejects))
+	}
+
+	// cancels still go through
+	if err := s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "F1-C", OrigGatewayID: "F1"}); err != nil {
+		t.Fatalf("cancel err=%v", err)
+	}
+
+	if err := s.EngageKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeSymbol}); err != errInvalidKillSwitch {
+		t.Fatalf("expected invalid kill switch, got %v", err)
+	}
+}
+
+func TestKillSwitchSurvivesRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.NewFileLog(path)
+	if err != nil {
+		t.Fatalf("audit log err=%v", err)
+	}
+	s := NewOMS(&mockOrderGateway{}, &OMSConfig{Audit: auditLog})
+	ctx := context.Background()
+	s.EngageKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeAccount, Value: "ACC-B1", Operator: "ops"})
+	s.EngageKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeFirm, Operator: "ops"})
+	s.ReleaseKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeFirm, Operator: "ops"})
+	s.Stop()
+	auditLog.Close()
+
+	// the restarted OMS appends to the same log
+	auditLog, err = audit.NewFileLog(path)
+	if err != nil {
+		t.Fatalf("reopen audit log err=%v", err)
+	}
+	defer auditLog.Close()
+	gw := &mockOrderGateway{}
+	restarted := NewOMS(gw, &OMSConfig{Audit: auditLog})
+	defer restarted.Stop()
+	if err := restarted.Recover(ctx, eventLog{}); err != nil {
+		t.Fatalf("recover err=%v", err)
+	}
+
+	switches, _ := restarted.KillSwitches(ctx)
+	if len(switches) != 1 || switches[0].Scope != model.KillSwitchScopeAccount || switches[0].Value != "ACC-B1" {
+		t.Fatalf("expected the account switch engaged, got %+v", switches)
+	}
+	restarted.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10))
+	if r := gw.lastReport("B1"); r == nil || r.Status != model.OrderStatusRejected {
+		t.Fatalf("expected B1 rejected by the restored switch, got %+v", r)
+	}
+	restarted.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 100, 10))
+	if r := gw.lastReport("B2"); r == nil || r.Status == model.OrderStatusRejected {
+		t.Fatalf("expected B2 accepted once the firm switch was released, got %+v", r)
+	}
+}
@@ -0,0 +1,13 @@
+package ledger
+
+import "github.com/joripage/orderbook-dev/pkg/misc"
+
+// LoadConfig reads a ledger config from a YAML file (see misc.LoadYAML).
+func LoadConfig(path string) (*LedgerConfig, error) {
+	cfg := &LedgerConfig{}
+	if err := misc.LoadYAML(path, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,58 @@
+package ledger
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/misc"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+// FileStore keeps balances in memory and their cash in a JSON file, an array
+// of model.AccountBalance. Every cash change rewrites the file, so only one
+// process may use it: engine shards share a database store instead (see
+// repo.AccountBalanceSQLRepo).
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// NewFileStore loads the balances of path, a missing file has no balances.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		path:        path,
+	}
+
+	var balances []*model.AccountBalance
+	if err := misc.ReadJSONFile(path, &balances); err != nil {
+		return nil, err
+	}
+	for _, b := range balances {
+		s.add(b.Account, b.Cash, decimal.Zero)
+	}
+	return s, nil
+}
+
+func (s *FileStore) Add(ctx context.Context, account string, cash, reserved decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(account, cash, reserved)
+	if cash.IsZero() {
+		return nil
+	}
+
+	balances := make([]*model.AccountBalance, 0, len(s.balances))
+	for _, b := range s.balances {
+		balances = append(balances, &model.AccountBalance{
+			Account:   b.Account,
+			Cash:      b.Cash,
+			UpdatedAt: time.Now(),
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Account < balances[j].Account })
+	return misc.WriteJSONFile(s.path, balances)
+}
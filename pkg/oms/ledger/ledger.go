@@ -0,0 +1,281 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownAccount          = errors.New("unknown account")
+	ErrInsufficientBuyingPower = errors.New("insufficient buying power")
+)
+
+// Store holds the cash of every account. It is the one place cash lives:
+// the engine shards of a deployment share it, every change adds to one
+// account and a reservation is checked against what every shard reserved.
+type Store interface {
+	// Balance returns the cash and reserved total of account.
+	Balance(ctx context.Context, account string) (Balance, bool, error)
+	// Reserve adds amount to the reserved total of account, it fails with
+	// ErrUnknownAccount or ErrInsufficientBuyingPower.
+	Reserve(ctx context.Context, account string, amount decimal.Decimal) error
+	// Add adds cash and reserved to the totals of account, a missing
+	// account is created.
+	Add(ctx context.Context, account string, cash, reserved decimal.Decimal) error
+}
+
+// PriceScaler converts market data prices to order prices (see
+// riskrule.Chain).
+type PriceScaler interface {
+	PriceScale() float64
+}
+
+// Ledger tracks the cash of every account. A buy order reserves its cost
+// (price x qty plus estimated fees) at entry, the reservation follows the
+// leaves quantity of the order and is released when the order ends. Fills
+// debit buys and credit sells at the traded price. Account totals live in
+// the store, the ledger keeps the reservation of each of its orders. Amounts
+// are exact decimals, VND notionals never drift.
+type Ledger struct {
+	cfg         *LedgerConfig
+	store       Store
+	instruments *instrument.Store
+	scale       PriceScaler
+
+	mu           sync.Mutex
+	reservations map[string]*reservation // orderID -> reservation
+}
+
+type LedgerConfig struct {
+	// FeeRate is the estimated fee charged on the traded value, 0.0015 = 0.15%.
+	FeeRate decimal.Decimal `yaml:"fee_rate"`
+	// BalanceFile seeds balances from a JSON file (see NewFileStore).
+	BalanceFile string `yaml:"balance_file"`
+}
+
+type reservation struct {
+	account string
+	price   decimal.Decimal // reserved price per unit, fees included
+	amount  decimal.Decimal
+}
+
+// Balance is a snapshot of one account.
+type Balance struct {
+	Account   string
+	Cash      decimal.Decimal
+	Reserved  decimal.Decimal
+	Available decimal.Decimal // Cash - Reserved
+}
+
+// NewLedger keeps account totals in store, a nil store keeps them in memory.
+// instruments prices market orders at the ceiling and may be nil.
+func NewLedger(ctx context.Context, cfg *LedgerConfig, store Store, instruments *instrument.Store) (*Ledger, error) {
+	if cfg == nil {
+		cfg = &LedgerConfig{}
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	return &Ledger{
+		cfg:          cfg,
+		store:        store,
+		instruments:  instruments,
+		reservations: make(map[string]*reservation),
+	}, nil
+}
+
+// SetPriceScale converts the ceiling of market orders to order prices, by
+// default instrument prices are used as is.
+func (l *Ledger) SetPriceScale(scale PriceScaler) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.scale = scale
+}
+
+// Balance returns the cash, reserved and available amount of account.
+func (l *Ledger) Balance(account string) (Balance, bool) {
+	b, ok, err := l.store.Balance(context.Background(), account)
+	if err != nil {
+		log.Printf("load balance account=%s err=%v", account, err)
+		return Balance{}, false
+	}
+	return b, ok
+}
+
+// Available returns the cash account can still spend.
+func (l *Ledger) Available(account string) (decimal.Decimal, bool) {
+	b, ok := l.Balance(account)
+	return b.Available, ok
+}
+
+// Deposit adds amount to the cash of account, a negative amount withdraws.
+func (l *Ledger) Deposit(account string, amount decimal.Decimal) {
+	l.add(account, amount, decimal.Zero)
+}
+
+// Required returns the cash order needs beyond what it already reserves.
+func (l *Ledger) Required(order *model.Order) decimal.Decimal {
+	if l == nil || order.Side != model.OrderSideBuy {
+		return decimal.Zero
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	required := l.unitCost(order).Mul(decimal.NewFromInt(order.LeavesQuantity))
+	if r, ok := l.reservations[order.OrderID]; ok {
+		required = required.Sub(r.amount)
+	}
+	return required
+}
+
+// Reserve holds the cost of a new buy order, it fails when the account
+// cannot pay for it.
+func (l *Ledger) Reserve(order *model.Order) error {
+	if l == nil || order.Side != model.OrderSideBuy {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	price := l.unitCost(order)
+	amount := price.Mul(decimal.NewFromInt(order.LeavesQuantity))
+	if err := l.store.Reserve(context.Background(), order.Account, amount); err != nil {
+		return err
+	}
+
+	l.reservations[order.OrderID] = &reservation{
+		account: order.Account,
+		price:   price,
+		amount:  amount,
+	}
+	return nil
+}
+
+// Resize sets the reservation of a buy order to the cost of order, the
+// order as a replace would leave it. An increase fails when the account
+// cannot pay it, a decrease is released.
+func (l *Ledger) Resize(order *model.Order) error {
+	if l == nil || order.Side != model.OrderSideBuy {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r, ok := l.reservations[order.OrderID]
+	if !ok {
+		return nil
+	}
+	amount := l.unitCost(order).Mul(decimal.NewFromInt(order.LeavesQuantity))
+	change := amount.Sub(r.amount)
+	if change.IsPositive() {
+		if err := l.store.Reserve(context.Background(), r.account, change); err != nil {
+			return err
+		}
+	} else if change.IsNegative() {
+		l.add(r.account, decimal.Zero, change)
+	}
+	r.amount = amount
+	return nil
+}
+
+// Release frees what is left of the reservation of orderID.
+func (l *Ledger) Release(orderID string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r, ok := l.reservations[orderID]; ok {
+		delete(l.reservations, orderID)
+		l.add(r.account, decimal.Zero, r.amount.Neg())
+	}
+}
+
+// Apply updates the ledger with a report of order: a trade moves cash, the
+// reservation of a buy order is resized to its leaves quantity and released
+// once the order ends.
+func (l *Ledger) Apply(order model.Order) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var cash, reserved decimal.Decimal
+	if order.ExecType == model.ExecTypeTrade && order.LastQuantity > 0 {
+		value := order.LastPrice.Mul(decimal.NewFromInt(order.LastQuantity))
+		fee := value.Mul(l.cfg.FeeRate)
+		if order.Side == model.OrderSideBuy {
+			cash = value.Add(fee).Neg()
+		} else {
+			cash = value.Sub(fee)
+		}
+	}
+
+	if r, ok := l.reservations[order.OrderID]; ok {
+		switch {
+		case order.IsEnd() || order.LeavesQuantity <= 0:
+			delete(l.reservations, order.OrderID)
+			reserved = r.amount.Neg()
+		default:
+			// a replace reserves at the new price
+			if order.ExecType == model.ExecTypeReplaced || order.ExecType == model.ExecTypeRestated {
+				r.price = l.unitCost(&order)
+			}
+			amount := r.price.Mul(decimal.NewFromInt(order.LeavesQuantity))
+			reserved = amount.Sub(r.amount)
+			r.amount = amount
+		}
+	}
+
+	if !cash.IsZero() || !reserved.IsZero() {
+		l.add(order.Account, cash, reserved)
+	}
+}
+
+// unitCost returns the cash reserved per unit of order, fees included.
+// Orders without a limit price are priced at the stop price or the ceiling,
+// scaled to order prices.
+func (l *Ledger) unitCost(order *model.Order) decimal.Decimal {
+	price := order.Price
+	if order.Type == model.OrderTypeMarket || !price.IsPositive() {
+		price = order.StopPrice
+		if l.instruments != nil {
+			if instrument, ok := l.instruments.Get(order.Symbol); ok && instrument.Ceil > 0 {
+				price = decimal.NewFromFloat(instrument.Ceil).Mul(decimal.NewFromFloat(l.priceScale()))
+			}
+		}
+	}
+	return price.Mul(decimal.NewFromInt(1).Add(l.cfg.FeeRate))
+}
+
+func (l *Ledger) add(account string, cash, reserved decimal.Decimal) {
+	if err := l.store.Add(context.Background(), account, cash, reserved); err != nil {
+		log.Printf("update balance account=%s cash=%v reserved=%v err=%v", account, cash, reserved, err)
+	}
+}
+
+func (l *Ledger) priceScale() float64 {
+	if l.scale == nil {
+		return 1
+	}
+	return l.scale.PriceScale()
+}
@@ -0,0 +1,171 @@
+package ledger
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func newBuy(orderID string, price float64, qty int64) *model.Order {
+	return &model.Order{
+		OrderID:        orderID,
+		Account:        "A1",
+		Symbol:         "TEST",
+		Side:           model.OrderSideBuy,
+		Type:           model.OrderTypeLimit,
+		Price:          decimal.NewFromFloat(price),
+		Quantity:       qty,
+		LeavesQuantity: qty,
+	}
+}
+
+func TestLedgerReserveFillRelease(t *testing.T) {
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "balances.json"))
+	if err := store.Add(context.Background(), "A1", decimal.NewFromInt(10_000), decimal.Zero); err != nil {
+		t.Fatalf("seed err=%v", err)
+	}
+	l, err := NewLedger(context.Background(), &LedgerConfig{FeeRate: decimal.NewFromFloat(0.01)}, store, nil)
+	if err != nil {
+		t.Fatalf("new ledger err=%v", err)
+	}
+
+	order := newBuy("O1", 100, 50) // 5000 + 1% fee
+	if err := l.Reserve(order); err != nil {
+		t.Fatalf("reserve err=%v", err)
+	}
+	if b, _ := l.Balance("A1"); !b.Reserved.Equal(decimal.NewFromInt(5050)) || !b.Available.Equal(decimal.NewFromInt(4950)) {
+		t.Fatalf("unexpected balance after reserve %+v", b)
+	}
+	if err := l.Reserve(newBuy("O2", 100, 50)); err != ErrInsufficientBuyingPower {
+		t.Fatalf("expected ErrInsufficientBuyingPower, got %v", err)
+	}
+
+	// fill 20 below the limit price: debit the traded value, keep 30 reserved
+	order.ExecType = model.ExecTypeTrade
+	order.Status = model.OrderStatusPartiallyFilled
+	order.LastPrice, order.LastQuantity = decimal.NewFromInt(90), 20
+	order.LeavesQuantity = 30
+	l.Apply(*order)
+	if b, _ := l.Balance("A1"); !b.Cash.Equal(decimal.NewFromInt(10_000-1818)) || !b.Reserved.Equal(decimal.NewFromInt(3030)) {
+		t.Fatalf("unexpected balance after fill %+v", b)
+	}
+
+	order.ExecType = model.ExecTypeCanceled
+	order.Status = model.OrderStatusCanceled
+	l.Apply(*order)
+	if b, _ := l.Balance("A1"); !b.Reserved.Equal(decimal.NewFromInt(0)) || !b.Available.Equal(decimal.NewFromInt(10_000-1818)) {
+		t.Fatalf("unexpected balance after cancel %+v", b)
+	}
+
+	// the debit survives a restart
+	reloaded, err := NewFileStore(store.path)
+	if err != nil {
+		t.Fatalf("reload err=%v", err)
+	}
+	restarted, err := NewLedger(context.Background(), &LedgerConfig{}, reloaded, nil)
+	if err != nil {
+		t.Fatalf("reload err=%v", err)
+	}
+	if b, _ := restarted.Balance("A1"); !b.Cash.Equal(decimal.NewFromInt(10_000 - 1818)) {
+		t.Fatalf("unexpected balance after restart %+v", b)
+	}
+}
+
+func TestLedgerUnknownAccount(t *testing.T) {
+	l, _ := NewLedger(context.Background(), nil, nil, nil)
+
+	if err := l.Reserve(newBuy("O1", 10, 1)); err != ErrUnknownAccount {
+		t.Fatalf("expected ErrUnknownAccount, got %v", err)
+	}
+	// sells never reserve
+	sell := newBuy("O2", 10, 1)
+	sell.Side = model.OrderSideSell
+	if err := l.Reserve(sell); err != nil {
+		t.Fatalf("sell reserve err=%v", err)
+	}
+}
+
+func TestLedgersShareOneStore(t *testing.T) {
+	// two shards of one deployment
+	store := NewMemoryStore()
+	store.Add(context.Background(), "A1", decimal.NewFromInt(10_000), decimal.Zero)
+	shard1, _ := NewLedger(context.Background(), nil, store, nil)
+	shard2, _ := NewLedger(context.Background(), nil, store, nil)
+
+	if err := shard1.Reserve(newBuy("O1", 100, 60)); err != nil {
+		t.Fatalf("reserve err=%v", err)
+	}
+	if err := shard2.Reserve(newBuy("O2", 100, 60)); err != ErrInsufficientBuyingPower {
+		t.Fatalf("expected ErrInsufficientBuyingPower on the other shard, got %v", err)
+	}
+
+	// a fill on one shard is seen by the other
+	order := newBuy("O3", 100, 40)
+	if err := shard2.Reserve(order); err != nil {
+		t.Fatalf("reserve err=%v", err)
+	}
+	order.ExecType = model.ExecTypeTrade
+	order.Status = model.OrderStatusFilled
+	order.LastPrice, order.LastQuantity = decimal.NewFromInt(100), 40
+	order.LeavesQuantity = 0
+	shard2.Apply(*order)
+	if b, _ := shard1.Balance("A1"); !b.Cash.Equal(decimal.NewFromInt(6_000)) || !b.Reserved.Equal(decimal.NewFromInt(6_000)) || !b.Available.Equal(decimal.NewFromInt(0)) {
+		t.Fatalf("unexpected shared balance %+v", b)
+	}
+}
+
+type priceScale float64
+
+func (s priceScale) PriceScale() float64 { return float64(s) }
+
+func TestLedgerScalesMarketOrderCeiling(t *testing.T) {
+	// market data prices are in thousand VND, DVT ceil 11.2
+	instruments, err := instrument.NewStoreFromFile("../../../config/market_data.json")
+	if err != nil {
+		t.Fatalf("load instruments err=%v", err)
+	}
+	l, _ := NewLedger(context.Background(), nil, nil, instruments)
+	l.SetPriceScale(priceScale(1000))
+	l.Deposit("A1", decimal.NewFromInt(1_000_000))
+
+	order := newBuy("O1", 0, 100)
+	order.Symbol, order.Type = "DVT", model.OrderTypeMarket
+	if got := l.Required(order); !got.Equal(decimal.NewFromInt(1_120_000)) {
+		t.Fatalf("expected 100 x 11200 VND required, got %v", got)
+	}
+	if err := l.Reserve(order); err != ErrInsufficientBuyingPower {
+		t.Fatalf("expected ErrInsufficientBuyingPower, got %v", err)
+	}
+}
+
+func TestLedgerConfigFeeRateIsExact(t *testing.T) {
+	cfg, err := LoadConfig("../../../config/ledger.yaml")
+	if err != nil {
+		t.Fatalf("load config err=%v", err)
+	}
+	if !cfg.FeeRate.Equal(decimal.RequireFromString("0.0015")) {
+		t.Fatalf("unexpected fee rate %v", cfg.FeeRate)
+	}
+
+	// three fills of a price float64 cannot hold, the debit stays exact
+	l, _ := NewLedger(context.Background(), cfg, nil, nil)
+	l.Deposit("A1", decimal.NewFromInt(1_000_000_000))
+	order := newBuy("O1", 33_300.1, 3_000)
+	if err := l.Reserve(order); err != nil {
+		t.Fatalf("reserve err=%v", err)
+	}
+	order.ExecType = model.ExecTypeTrade
+	order.LastPrice = decimal.RequireFromString("33300.1")
+	for _, leaves := range []int64{2_000, 1_000, 0} {
+		order.LastQuantity, order.LeavesQuantity = 1_000, leaves
+		l.Apply(*order)
+	}
+	// 99_900_300 traded + 149_850.45 fee
+	if b, _ := l.Balance("A1"); !b.Cash.Equal(decimal.RequireFromString("899949849.55")) || !b.Reserved.IsZero() {
+		t.Fatalf("unexpected balance %+v", b)
+	}
+}
@@ -0,0 +1,67 @@
+package ledger
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// MemoryStore keeps account totals in memory, for a single process.
+type MemoryStore struct {
+	mu       sync.Mutex
+	balances map[string]*Balance
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		balances: make(map[string]*Balance),
+	}
+}
+
+func (s *MemoryStore) Balance(ctx context.Context, account string) (Balance, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.balances[account]
+	if !ok {
+		return Balance{}, false, nil
+	}
+	return *b, true, nil
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, account string, amount decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.balances[account]
+	if !ok {
+		return ErrUnknownAccount
+	}
+	if amount.GreaterThan(b.Available) {
+		return ErrInsufficientBuyingPower
+	}
+	b.Reserved = b.Reserved.Add(amount)
+	b.Available = b.Available.Sub(amount)
+	return nil
+}
+
+func (s *MemoryStore) Add(ctx context.Context, account string, cash, reserved decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(account, cash, reserved)
+	return nil
+}
+
+func (s *MemoryStore) add(account string, cash, reserved decimal.Decimal) *Balance {
+	b, ok := s.balances[account]
+	if !ok {
+		b = &Balance{Account: account}
+		s.balances[account] = b
+	}
+	b.Cash = b.Cash.Add(cash)
+	b.Reserved = b.Reserved.Add(reserved)
+	b.Available = b.Cash.Sub(b.Reserved)
+	return b
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AccountBalance is the persisted cash of an account. Reservations of open
+// orders are rebuilt from the live orders at startup.
+type AccountBalance struct {
+	Account   string          `json:"account" gorm:"primaryKey"`
+	Cash      decimal.Decimal `json:"cash"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
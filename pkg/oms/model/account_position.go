@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// AccountPosition is the persisted holding of an account in one symbol.
+// Locks of open sell orders are rebuilt from the live orders at startup.
+type AccountPosition struct {
+	Account   string    `json:"account" gorm:"primaryKey"`
+	Symbol    string    `json:"symbol" gorm:"primaryKey"`
+	Quantity  int64     `json:"quantity"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
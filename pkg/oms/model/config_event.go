@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ConfigEvent records a risk and market configuration taking effect. Files
+// holds the exact content of every file read, so a replay builds the same
+// rule set, and order events carry the Version in force.
+type ConfigEvent struct {
+	Node      int64 // ID node of the instance, versions count per node
+	Version   int64
+	Operator  string
+	Digest    string            // sha256 of Files
+	Files     map[string][]byte // content by path
+	Timestamp time.Time
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/idgen"
+)
+
+// ids generates OrderIDs and ExecIDs, node 0 until SetIDGenerator.
+var ids atomic.Pointer[idgen.Generator]
+
+func init() {
+	g, _ := idgen.NewGenerator(nil)
+	ids.Store(g)
+}
+
+// SetIDGenerator sets the generator of the OMS instance, before the first
+// order.
+func SetIDGenerator(g *idgen.Generator) {
+	ids.Store(g)
+}
+
+// Node returns the ID node of this instance.
+func Node() int64 {
+	return ids.Load().Node()
+}
+
+func newOrderID() string {
+	return ids.Load().NextString()
+}
+
+// newExecID prefixes the ID with the kind of execution, one ExecID per
+// report.
+func newExecID(kind string) string {
+	return kind + "-" + ids.Load().NextString()
+}
+
+// ParseID returns the snowflake of an OrderID or ExecID, false for an ID
+// not made by idgen.
+func ParseID(id string) (int64, bool) {
+	if i := strings.LastIndexByte(id, '-'); i >= 0 {
+		id = id[i+1:]
+	}
+	n, err := idgen.Parse(id)
+	return n, err == nil
+}
+
+// OwnID reports whether id was generated by the node of this instance, an
+// ID not made by idgen is taken as owned.
+func OwnID(id string) bool {
+	n, ok := ParseID(id)
+	return !ok || idgen.Node(n) == ids.Load().Node()
+}
+
+// ObserveID moves the generator past id when this node generated it, so a
+// restart never reissues an ID of the event log.
+func ObserveID(id string) {
+	if n, ok := ParseID(id); ok && idgen.Node(n) == ids.Load().Node() {
+		ids.Load().Observe(n)
+	}
+}
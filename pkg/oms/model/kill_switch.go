@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+type KillSwitchScope string
+
+const (
+	KillSwitchScopeFirm    KillSwitchScope = "FIRM"
+	KillSwitchScopeAccount KillSwitchScope = "ACCOUNT"
+	KillSwitchScopeSession KillSwitchScope = "SESSION"
+	KillSwitchScopeSymbol  KillSwitchScope = "SYMBOL"
+)
+
+// KillSwitch blocks new orders of a scope until it is released. Value is the
+// account, session or symbol of the scope, empty for the firm.
+type KillSwitch struct {
+	Scope        KillSwitchScope `json:"scope"`
+	Value        string          `json:"value"`
+	CancelOrders bool            `json:"cancel_orders"` // mass-cancel the resting orders of the scope
+	Reason       string          `json:"reason"`
+	Operator     string          `json:"operator"`
+	EngagedAt    time.Time       `json:"engaged_at"`
+}
+
+// Matches reports whether an order of account, session and symbol falls in
+// the scope of the switch.
+func (k *KillSwitch) Matches(account, sessionID, symbol string) bool {
+	switch k.Scope {
+	case KillSwitchScopeFirm:
+		return true
+	case KillSwitchScopeAccount:
+		return account == k.Value
+	case KillSwitchScopeSession:
+		return sessionID == k.Value
+	case KillSwitchScopeSymbol:
+		return symbol == k.Value
+	}
+	return false
+}
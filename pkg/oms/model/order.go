@@ -1,12 +1,10 @@
 package model
 
 import (
-	"fmt"
 	"time"
 
-	"github.com/joripage/orderbook-dev/pkg/misc"
-	"github.com/joripage/orderbook-dev/pkg/oms/constant"
 	"github.com/joripage/orderbook-dev/pkg/orderbook"
+	"github.com/shopspring/decimal"
 )
 
 type OrderStatus string
@@ -64,6 +62,23 @@ const (
 	OrderTypeLimit   OrderType = "LIMIT"
 	OrderTypeMarket  OrderType = "MARKET"
 	OrderTypeIceberg OrderType = "ICEBERG"
+	OrderTypeStop    OrderType = "STOP"
+)
+
+type OrderPegType string
+
+const (
+	OrderPegTypePrimary  OrderPegType = "PEG_PRIMARY"  // same-side best price
+	OrderPegTypeMarket   OrderPegType = "PEG_MARKET"   // opposite-side best price
+	OrderPegTypeMidpoint OrderPegType = "PEG_MIDPOINT" // middle of best bid and ask
+)
+
+type OrderBoard string
+
+const (
+	OrderBoardMain       OrderBoard = "MAIN"
+	OrderBoardOddLot     OrderBoard = "ODD_LOT"
+	OrderBoardPutThrough OrderBoard = "PUT_THROUGH"
 )
 
 type OrderTimeInForce string
@@ -75,6 +90,9 @@ const (
 	OrderTimeInForceGTC OrderTimeInForce = "GTC"
 )
 
+// AvgPriceDecimals is the precision of Order.AvgPrice.
+const AvgPriceDecimals = 8
+
 type Order struct {
 	ID            string
 	GatewayID     string
@@ -87,10 +105,23 @@ type Order struct {
 	Side         OrderSide
 	Type         OrderType
 	TimeInForce  OrderTimeInForce
-	Price        float64
+	Price        decimal.Decimal
+	StopPrice    decimal.Decimal
+	PegType      OrderPegType
+	PegOffset    decimal.Decimal
+	Hidden       bool
+	ShortSell    bool // sell beyond holdings, approved accounts only
 	Quantity     int64
 	Account      string
 	TransactTime time.Time
+	Board        OrderBoard
+	SessionID    string
+
+	// contingent order list (OCO, bracket)
+	ListID string
+
+	// side of a market maker quote
+	QuoteID string
 
 	// counterparty
 	CounterpartyAccount string
@@ -105,15 +136,21 @@ type Order struct {
 	CumQuantity    int64
 	LeavesQuantity int64
 	LastQuantity   int64
-	LastPrice      float64
-	AvgPrice       float64
+	LastPrice      decimal.Decimal
+	AvgPrice       decimal.Decimal
+	CumValue       decimal.Decimal // traded value of the fills, AvgPrice is derived from it
 	LastUpdate     time.Time
+	Seq            int64 // events stored for the order, the Seq of the last one
+
+	// rejected order
+	RejectReason OrderRejectReason
+	Text         string
 }
 
 func (s *Order) UpdateAddOrder(addOrder *AddOrder) {
 	qty := addOrder.Quantity.IntPart()
 
-	s.ID = misc.RandSeq(constant.ID_LENGTH)
+	s.ID = newOrderID()
 	s.GatewayID = addOrder.GatewayID
 	s.Symbol = addOrder.Symbol
 	s.SecurityID = addOrder.SecurityID
@@ -121,42 +158,122 @@ func (s *Order) UpdateAddOrder(addOrder *AddOrder) {
 	s.Side = addOrder.Side
 	s.Type = addOrder.Type
 	s.TimeInForce = addOrder.TimeInForce
-	s.Price = addOrder.Price.InexactFloat64()
+	s.Price = addOrder.Price
+	s.StopPrice = addOrder.StopPrice
+	s.PegType = addOrder.PegType
+	s.PegOffset = addOrder.PegOffset
+	s.Hidden = addOrder.Hidden
+	s.ShortSell = addOrder.ShortSell
 	s.Quantity = qty
 	s.LeavesQuantity = qty
 	s.Account = addOrder.Account
 	s.TransactTime = addOrder.TransactTime
+	s.ListID = addOrder.ListID
+	s.SessionID = addOrder.SessionID
+	s.Board = OrderBoardMain
 
-	// calculated info
-	s.ExecID = "notempty"
+	// calculated info, received but not yet on the book
+	s.ExecID = genPendingExecID()
 	s.OrderID = s.ID
-	s.Status = OrderStatusNew
-	s.ExecType = ExecTypeNew
+	s.Status = OrderStatusPendingNew
+	s.ExecType = ExecTypePendingNew
 	s.CumQuantity = 0
 	s.LeavesQuantity = qty
 	s.LastQuantity = 0
-	s.LastPrice = 0
-	s.AvgPrice = 0
+	s.LastPrice = decimal.Zero
+	s.AvgPrice = decimal.Zero
+	s.CumValue = decimal.Zero
+}
+
+// UpdateNew marks a received order as accepted by the book.
+func (s *Order) UpdateNew() error {
+	if err := s.checkTransition(OrderActionAccept); err != nil {
+		return err
+	}
+	s.Status = s.nextStatus(OrderActionAccept)
+	s.ExecType = ExecTypeNew
+
+	s.LastExecID = s.ExecID
+	s.ExecID = genNewExecID()
+	s.LastUpdate = time.Now()
+	return nil
+}
+
+// UpdatePendingReplace acknowledges a replace request, price and quantity
+// stay as they are until the book applies it.
+func (s *Order) UpdatePendingReplace(modifyOrder *ModifyOrder) error {
+	if err := s.checkTransition(OrderActionPendingReplace); err != nil {
+		return err
+	}
+	s.Status = s.nextStatus(OrderActionPendingReplace)
+	s.ExecType = ExecTypePendingReplace
+	s.GatewayID = modifyOrder.GatewayID
+	s.OrigGatewayID = modifyOrder.OrigGatewayID
+
+	s.LastExecID = s.ExecID
+	s.ExecID = genPendingExecID()
+	s.LastUpdate = time.Now()
+	return nil
+}
+
+// UpdatePendingCancel acknowledges a cancel request, the order keeps its
+// leaves quantity until the book removes it.
+func (s *Order) UpdatePendingCancel(cancelOrder *CancelOrder) error {
+	if err := s.checkTransition(OrderActionPendingCancel); err != nil {
+		return err
+	}
+	s.Status = s.nextStatus(OrderActionPendingCancel)
+	s.ExecType = ExecTypePendingCancel
+	s.GatewayID = cancelOrder.GatewayID
+	s.OrigGatewayID = cancelOrder.OrigGatewayID
+
+	s.LastExecID = s.ExecID
+	s.ExecID = genPendingExecID()
+	s.LastUpdate = time.Now()
+	return nil
+}
+
+// UpdatePendingRejected undoes the acknowledgement of a cancel or replace
+// request the book refused: the order is back in the status and ClOrdID of
+// prev, its state before the request. Nothing is reported, the request is
+// answered by an OrderCancelReject.
+func (s *Order) UpdatePendingRejected(prev *Order) {
+	s.Status = prev.Status
+	s.ExecType = prev.ExecType
+	s.GatewayID = prev.GatewayID
+	s.OrigGatewayID = prev.OrigGatewayID
 }
 
-func (s *Order) UpdateModifyOrder(modifyOrder *ModifyOrder) {
-	s.Status = OrderStatusReplaced
+// UpdateModifyOrder applies a replace. The status follows the fills of the
+// order, a replace down to the filled quantity fills it.
+func (s *Order) UpdateModifyOrder(modifyOrder *ModifyOrder) error {
+	if err := s.checkTransition(OrderActionReplace); err != nil {
+		return err
+	}
 	s.ExecType = ExecTypeReplaced
 	s.GatewayID = modifyOrder.GatewayID
 	s.OrigGatewayID = modifyOrder.OrigGatewayID
 
-	newPrice, newQty := modifyOrder.NewPrice.InexactFloat64(), modifyOrder.NewQuantity.IntPart()
+	newPrice, newQty := modifyOrder.NewPrice, modifyOrder.NewQuantity.IntPart()
 	s.LeavesQuantity = s.LeavesQuantity + (newQty - s.Quantity)
+	if s.LeavesQuantity < 0 {
+		s.LeavesQuantity = 0
+	}
 	s.Price = newPrice
 	s.Quantity = newQty
+	s.Status = s.nextStatus(OrderActionReplace)
 
 	s.LastExecID = s.ExecID
 	s.ExecID = genCancelReplaceExecID()
 	s.LastUpdate = time.Now()
+	return nil
 }
 
-func (s *Order) UpdateCancelOrder(cancelOrder *CancelOrder) {
-	s.Status = OrderStatusCanceled
+func (s *Order) UpdateCancelOrder(cancelOrder *CancelOrder) error {
+	if err := s.checkTransition(OrderActionCancel); err != nil {
+		return err
+	}
+	s.Status = s.nextStatus(OrderActionCancel)
 	s.ExecType = ExecTypeCanceled
 	s.GatewayID = cancelOrder.GatewayID
 	s.OrigGatewayID = cancelOrder.OrigGatewayID
@@ -166,44 +283,112 @@ func (s *Order) UpdateCancelOrder(cancelOrder *CancelOrder) {
 	s.LastExecID = s.ExecID
 	s.ExecID = genCancelExecID()
 	s.LastUpdate = time.Now()
+	return nil
 }
 
-func (s *Order) UpdateMatchResult(match *orderbook.MatchResult) {
-	oldValue := s.AvgPrice * float64(s.CumQuantity)
-	addedValue := match.Price * float64(match.Qty)
-	s.LastPrice = match.Price
+func (s *Order) UpdateMatchResult(match *orderbook.MatchResult) error {
+	if err := s.checkTransition(OrderActionTrade); err != nil {
+		return err
+	}
+	price := match.ExactPrice
+	s.LastPrice = price
 	s.CumQuantity += match.Qty
 	s.LeavesQuantity -= match.Qty
 	s.LastQuantity = match.Qty
-	s.AvgPrice = (oldValue + addedValue) / float64(s.CumQuantity)
+	s.CumValue = s.CumValue.Add(price.Mul(decimal.NewFromInt(match.Qty)))
+	s.AvgPrice = s.CumValue.DivRound(decimal.NewFromInt(s.CumQuantity), AvgPriceDecimals)
 	s.ExecType = ExecTypeTrade
-	if s.CumQuantity > 0 {
-		s.Status = OrderStatusPartiallyFilled
+	s.Status = s.nextStatus(OrderActionTrade)
+	s.LastExecID = s.ExecID
+	s.ExecID = genTradeExecID()
+	s.LastUpdate = time.Now()
+	return nil
+}
+
+// UpdateRestateQuantity reduces the order quantity without a client request,
+// e.g. when a linked order of the same list is partially filled.
+func (s *Order) UpdateRestateQuantity(qty int64) error {
+	if err := s.checkTransition(OrderActionRestate); err != nil {
+		return err
 	}
-	if s.LeavesQuantity == 0 {
-		s.Status = OrderStatusFilled
+	s.LeavesQuantity = s.LeavesQuantity - (s.Quantity - qty)
+	if s.LeavesQuantity < 0 {
+		s.LeavesQuantity = 0
 	}
+	s.Quantity = qty
+	s.ExecType = ExecTypeRestated
+
 	s.LastExecID = s.ExecID
-	s.ExecID = genTradeExecID()
+	s.ExecID = genRestateExecID()
 	s.LastUpdate = time.Now()
+	return nil
 }
 
-func (s *Order) CanCancel() bool {
-	switch s.Status {
-	case OrderStatusNew, OrderStatusPartiallyFilled, OrderStatusPendingNew, OrderStatusPendingReplace, OrderStatusPendingCancel, OrderStatusReplaced:
-		return true
+// UpdateRestatePrice moves a pegged order to the price set by the book.
+func (s *Order) UpdateRestatePrice(price decimal.Decimal) error {
+	if err := s.checkTransition(OrderActionRestate); err != nil {
+		return err
+	}
+	s.Price = price
+	s.ExecType = ExecTypeRestated
+
+	s.LastExecID = s.ExecID
+	s.ExecID = genRestateExecID()
+	s.LastUpdate = time.Now()
+	return nil
+}
+
+// UpdateReject ends a new order refused before reaching the book.
+func (s *Order) UpdateReject(reason OrderRejectReason, text string) error {
+	if err := s.checkTransition(OrderActionReject); err != nil {
+		return err
 	}
+	s.Status = s.nextStatus(OrderActionReject)
+	s.ExecType = ExecTypeRejected
+	s.LeavesQuantity = 0
+	s.RejectReason = reason
+	s.Text = text
 
-	return false
+	s.LastExecID = s.ExecID
+	s.ExecID = genRejectExecID()
+	s.LastUpdate = time.Now()
+	return nil
 }
 
-func (s *Order) CanModify() bool {
-	switch s.Status {
-	case OrderStatusNew, OrderStatusPartiallyFilled, OrderStatusPendingNew, OrderStatusPendingReplace, OrderStatusPendingCancel, OrderStatusReplaced:
-		return true
+// UpdateHold keeps a contingent order (bracket child) out of the market until
+// its parent order is filled.
+func (s *Order) UpdateHold() error {
+	if err := s.checkTransition(OrderActionHold); err != nil {
+		return err
 	}
+	s.Status = s.nextStatus(OrderActionHold)
+	s.ExecType = ExecTypePendingNew
+	return nil
+}
+
+// UpdateActivate releases a held contingent order (bracket child) into the
+// market with the given quantity.
+func (s *Order) UpdateActivate(qty int64) error {
+	if err := s.checkTransition(OrderActionAccept); err != nil {
+		return err
+	}
+	s.Quantity = qty
+	s.LeavesQuantity = qty
+	s.Status = s.nextStatus(OrderActionAccept)
+	s.ExecType = ExecTypeNew
+
+	s.LastExecID = s.ExecID
+	s.ExecID = genNewExecID()
+	s.LastUpdate = time.Now()
+	return nil
+}
+
+func (s *Order) CanCancel() bool {
+	return CanTransition(s.Status, OrderActionCancel)
+}
 
-	return false
+func (s *Order) CanModify() bool {
+	return CanTransition(s.Status, OrderActionReplace)
 }
 
 // Kiểm tra status terminal
@@ -211,6 +396,7 @@ func (s *Order) IsEnd() bool {
 	switch s.Status {
 	case OrderStatusFilled,
 		OrderStatusCanceled,
+		OrderStatusRejected,
 		OrderStatusExpired:
 		return true
 	default:
@@ -219,19 +405,29 @@ func (s *Order) IsEnd() bool {
 }
 
 func genTradeExecID() string {
-	return fmt.Sprintf("T-%s", misc.RandSeq(constant.EXECID_LENGTH-2))
-	// return fmt.Sprintf("T-%s", uuid.New())
-	// return "TradeExecID"
+	return newExecID("T")
 }
 
 func genCancelExecID() string {
-	return fmt.Sprintf("C-%s", misc.RandSeq(constant.EXECID_LENGTH-2))
-	// return fmt.Sprintf("C-%s", uuid.New())
-	// return "CancelExecID"
+	return newExecID("C")
+}
+
+func genNewExecID() string {
+	return newExecID("N")
+}
+
+func genPendingExecID() string {
+	return newExecID("P")
+}
+
+func genRestateExecID() string {
+	return newExecID("D")
+}
+
+func genRejectExecID() string {
+	return newExecID("J")
 }
 
 func genCancelReplaceExecID() string {
-	return fmt.Sprintf("R-%s", misc.RandSeq(constant.EXECID_LENGTH-2))
-	// return fmt.Sprintf("R-%s", uuid.New())
-	// return "ReplaceExecID"
+	return newExecID("R")
 }
@@ -5,23 +5,50 @@ import (
 	"sync"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type OrderEvent struct {
 	EventID       string
 	OrderID       string
+	Seq           int64 // position in the history of the order, from 1
 	GatewayID     string
 	OrigGatewayID string
+
+	// order fields, recovery rebuilds the order from its last event
+	Symbol       string
+	SecurityID   string
+	Exchange     string
+	Side         OrderSide
+	Type         OrderType
+	TimeInForce  OrderTimeInForce
+	StopPrice    decimal.Decimal
+	PegType      OrderPegType
+	PegOffset    decimal.Decimal
+	Hidden       bool
+	ShortSell    bool
+	Account      string
+	TransactTime time.Time
+	Board        OrderBoard
+	SessionID    string
+	ListID       string
+	QuoteID      string
+
 	OrderStatus   OrderStatus
 	ExecType      OrderExecType
 	Qty           int64
 	LeavesQty     int64
 	CumQty        int64
-	Price         float64
+	Price         decimal.Decimal
+	LastQty       int64
+	LastPrice     decimal.Decimal
+	AvgPrice      decimal.Decimal
+	CumValue      decimal.Decimal
 	ExecID        string
 	LastExecID    string
 	Timestamp     time.Time
+	ConfigVersion int64 // ConfigEvent in force
 
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -76,16 +103,38 @@ var orderEventPool = sync.Pool{
 
 func NewOrderEvent(order Order, ts time.Time) *OrderEvent {
 	return &OrderEvent{
-		EventID:       NewEventID(order.OrderID, order.Status),
+		EventID:       NewEventID(order.OrderID, order.Seq),
 		OrderID:       order.OrderID,
+		Seq:           order.Seq,
 		GatewayID:     order.GatewayID,
 		OrigGatewayID: order.OrigGatewayID,
+		Symbol:        order.Symbol,
+		SecurityID:    order.SecurityID,
+		Exchange:      order.Exchange,
+		Side:          order.Side,
+		Type:          order.Type,
+		TimeInForce:   order.TimeInForce,
+		StopPrice:     order.StopPrice,
+		PegType:       order.PegType,
+		PegOffset:     order.PegOffset,
+		Hidden:        order.Hidden,
+		ShortSell:     order.ShortSell,
+		Account:       order.Account,
+		TransactTime:  order.TransactTime,
+		Board:         order.Board,
+		SessionID:     order.SessionID,
+		ListID:        order.ListID,
+		QuoteID:       order.QuoteID,
 		OrderStatus:   order.Status,
 		ExecType:      order.ExecType,
 		Qty:           order.Quantity,
 		CumQty:        order.CumQuantity,
 		LeavesQty:     order.LeavesQuantity,
 		Price:         order.Price,
+		LastQty:       order.LastQuantity,
+		LastPrice:     order.LastPrice,
+		AvgPrice:      order.AvgPrice,
+		CumValue:      order.CumValue,
 		ExecID:        order.ExecID,
 		LastExecID:    order.LastExecID,
 		Timestamp:     ts,
@@ -94,16 +143,38 @@ func NewOrderEvent(order Order, ts time.Time) *OrderEvent {
 
 func NewOrderEventUsingPool(order Order, ts time.Time) (*OrderEvent, func()) {
 	s := orderEventPool.Get().(*OrderEvent)
-	s.EventID = NewEventID(order.OrderID, order.Status)
+	s.EventID = NewEventID(order.OrderID, order.Seq)
 	s.OrderID = order.OrderID
+	s.Seq = order.Seq
 	s.GatewayID = order.GatewayID
 	s.OrigGatewayID = order.OrigGatewayID
+	s.Symbol = order.Symbol
+	s.SecurityID = order.SecurityID
+	s.Exchange = order.Exchange
+	s.Side = order.Side
+	s.Type = order.Type
+	s.TimeInForce = order.TimeInForce
+	s.StopPrice = order.StopPrice
+	s.PegType = order.PegType
+	s.PegOffset = order.PegOffset
+	s.Hidden = order.Hidden
+	s.ShortSell = order.ShortSell
+	s.Account = order.Account
+	s.TransactTime = order.TransactTime
+	s.Board = order.Board
+	s.SessionID = order.SessionID
+	s.ListID = order.ListID
+	s.QuoteID = order.QuoteID
 	s.OrderStatus = order.Status
 	s.ExecType = order.ExecType
 	s.Qty = order.Quantity
 	s.CumQty = order.CumQuantity
 	s.LeavesQty = order.LeavesQuantity
 	s.Price = order.Price
+	s.LastQty = order.LastQuantity
+	s.LastPrice = order.LastPrice
+	s.AvgPrice = order.AvgPrice
+	s.CumValue = order.CumValue
 	s.ExecID = order.ExecID
 	s.LastExecID = order.LastExecID
 	s.Timestamp = ts
@@ -111,23 +182,89 @@ func NewOrderEventUsingPool(order Order, ts time.Time) (*OrderEvent, func()) {
 	resetFn := func() {
 		s.EventID = ""
 		s.OrderID = ""
+		s.Seq = 0
 		s.GatewayID = ""
 		s.OrigGatewayID = ""
+		s.Symbol = ""
+		s.SecurityID = ""
+		s.Exchange = ""
+		s.Side = ""
+		s.Type = ""
+		s.TimeInForce = ""
+		s.StopPrice = decimal.Zero
+		s.PegType = ""
+		s.PegOffset = decimal.Zero
+		s.Hidden = false
+		s.ShortSell = false
+		s.Account = ""
+		s.TransactTime = time.Time{}
+		s.Board = ""
+		s.SessionID = ""
+		s.ListID = ""
+		s.QuoteID = ""
 		s.OrderStatus = ""
 		s.ExecType = ""
 		s.Qty = 0
 		s.CumQty = 0
 		s.LeavesQty = 0
-		s.Price = 0
+		s.Price = decimal.Zero
+		s.LastQty = 0
+		s.LastPrice = decimal.Zero
+		s.AvgPrice = decimal.Zero
+		s.CumValue = decimal.Zero
 		s.ExecID = ""
 		s.LastExecID = ""
 		s.Timestamp = time.Time{}
+		s.ConfigVersion = 0
 		orderEventPool.Put(s)
 	}
 
 	return s, resetFn
 }
 
-func NewEventID(orderID string, status OrderStatus) string {
-	return fmt.Sprintf("%s-%s", orderID, status)
+// NewOrderFromEvent rebuilds the order as it was when ev was stored.
+func NewOrderFromEvent(ev *OrderEvent) *Order {
+	return &Order{
+		ID:             ev.OrderID,
+		GatewayID:      ev.GatewayID,
+		OrigGatewayID:  ev.OrigGatewayID,
+		Symbol:         ev.Symbol,
+		SecurityID:     ev.SecurityID,
+		Exchange:       ev.Exchange,
+		Side:           ev.Side,
+		Type:           ev.Type,
+		TimeInForce:    ev.TimeInForce,
+		Price:          ev.Price,
+		StopPrice:      ev.StopPrice,
+		PegType:        ev.PegType,
+		PegOffset:      ev.PegOffset,
+		Hidden:         ev.Hidden,
+		ShortSell:      ev.ShortSell,
+		Quantity:       ev.Qty,
+		Account:        ev.Account,
+		TransactTime:   ev.TransactTime,
+		Board:          ev.Board,
+		SessionID:      ev.SessionID,
+		ListID:         ev.ListID,
+		QuoteID:        ev.QuoteID,
+		ExecID:         ev.ExecID,
+		LastExecID:     ev.LastExecID,
+		OrderID:        ev.OrderID,
+		Status:         ev.OrderStatus,
+		ExecType:       ev.ExecType,
+		CumQuantity:    ev.CumQty,
+		LeavesQuantity: ev.LeavesQty,
+		LastQuantity:   ev.LastQty,
+		LastPrice:      ev.LastPrice,
+		AvgPrice:       ev.AvgPrice,
+		CumValue:       ev.CumValue,
+		LastUpdate:     ev.Timestamp,
+		Seq:            ev.Seq,
+	}
+}
+
+// NewEventID names the seq-th event of an order. OrderIDs are unique across
+// nodes, so is the event ID, and a redelivered event keeps its ID.
+func NewEventID(orderID string, seq int64) string {
+	return fmt.Sprintf("%s-%d", orderID, seq)
 }
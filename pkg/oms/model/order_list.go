@@ -0,0 +1,23 @@
+package model
+
+type ContingencyType string
+
+const (
+	// ContingencyTypeOCO links two orders, a fill on one cancels the other.
+	ContingencyTypeOCO ContingencyType = "OCO"
+	// ContingencyTypeBracket links an entry order with a take-profit and a
+	// stop-loss order, both activated once the entry is filled.
+	ContingencyTypeBracket ContingencyType = "BRACKET"
+	// ContingencyTypeOTO links two orders, the second one is activated once
+	// the first one is filled.
+	ContingencyTypeOTO ContingencyType = "OTO"
+)
+
+type AddOrderList struct {
+	ListID          string
+	ContingencyType ContingencyType
+	// OCO: [first, second]
+	// Bracket: [entry, take-profit, stop-loss]
+	// OTO: [triggering, triggered]
+	Orders []*AddOrder
+}
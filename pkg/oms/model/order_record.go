@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// OrderRecord is a row of the orders projection: the current state of an
+// order, as of the event with the highest Seq applied so far.
+type OrderRecord struct {
+	ID             uint `gorm:"primaryKey"`
+	OrderID        string
+	GatewayID      string
+	OrigGatewayID  string
+	Symbol         string
+	SecurityID     string
+	Exchange       string
+	Side           OrderSide
+	Type           OrderType
+	TimeInForce    OrderTimeInForce
+	Price          decimal.Decimal
+	StopPrice      decimal.Decimal
+	Quantity       int64
+	Account        string
+	TransactTime   time.Time
+	Board          OrderBoard
+	SessionID      string
+	ListID         string
+	QuoteID        string
+	ExecID         string
+	LastExecID     string
+	Status         OrderStatus
+	ExecType       OrderExecType
+	CumQuantity    int64
+	LeavesQuantity int64
+	LastQuantity   int64
+	LastPrice      decimal.Decimal
+	AvgPrice       decimal.Decimal
+	LastUpdate     time.Time
+	Seq            int64
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+}
+
+func (OrderRecord) TableName() string {
+	return "orders"
+}
+
+// NewOrderRecord is the order state carried by ev.
+func NewOrderRecord(ev *OrderEvent) *OrderRecord {
+	return &OrderRecord{
+		OrderID:        ev.OrderID,
+		GatewayID:      ev.GatewayID,
+		OrigGatewayID:  ev.OrigGatewayID,
+		Symbol:         ev.Symbol,
+		SecurityID:     ev.SecurityID,
+		Exchange:       ev.Exchange,
+		Side:           ev.Side,
+		Type:           ev.Type,
+		TimeInForce:    ev.TimeInForce,
+		Price:          ev.Price,
+		StopPrice:      ev.StopPrice,
+		Quantity:       ev.Qty,
+		Account:        ev.Account,
+		TransactTime:   ev.TransactTime,
+		Board:          ev.Board,
+		SessionID:      ev.SessionID,
+		ListID:         ev.ListID,
+		QuoteID:        ev.QuoteID,
+		ExecID:         ev.ExecID,
+		LastExecID:     ev.LastExecID,
+		Status:         ev.OrderStatus,
+		ExecType:       ev.ExecType,
+		CumQuantity:    ev.CumQty,
+		LeavesQuantity: ev.LeavesQty,
+		LastQuantity:   ev.LastQty,
+		LastPrice:      ev.LastPrice,
+		AvgPrice:       ev.AvgPrice,
+		LastUpdate:     ev.Timestamp,
+		Seq:            ev.Seq,
+	}
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OrderAction is an event that moves an order between statuses: the
+// acknowledgement of a request, a book result or a restatement.
+type OrderAction string
+
+const (
+	OrderActionAccept         OrderAction = "Accept" // the book takes the order
+	OrderActionReject         OrderAction = "Reject"
+	OrderActionHold           OrderAction = "Hold" // contingent order kept off the book
+	OrderActionPendingCancel  OrderAction = "PendingCancel"
+	OrderActionCancel         OrderAction = "Cancel"
+	OrderActionPendingReplace OrderAction = "PendingReplace"
+	OrderActionReplace        OrderAction = "Replace"
+	OrderActionTrade          OrderAction = "Trade"
+	OrderActionRestate        OrderAction = "Restate"
+)
+
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// orderTransitions lists the actions allowed in each status, after the
+// order state change matrices of FIX 4.4. A status missing from the table
+// is terminal. Replaced is kept for the FIX mapping only: a replaced order
+// reports the status of its fills.
+var orderTransitions = map[OrderStatus]map[OrderAction]bool{
+	OrderStatusPendingNew: {
+		OrderActionAccept:        true,
+		OrderActionReject:        true,
+		OrderActionHold:          true,
+		OrderActionPendingCancel: true,
+		OrderActionCancel:        true,
+		OrderActionRestate:       true,
+	},
+	OrderStatusNew: {
+		OrderActionPendingCancel:  true,
+		OrderActionCancel:         true,
+		OrderActionPendingReplace: true,
+		OrderActionReplace:        true,
+		OrderActionTrade:          true,
+		OrderActionRestate:        true,
+	},
+	OrderStatusPartiallyFilled: {
+		OrderActionPendingCancel:  true,
+		OrderActionCancel:         true,
+		OrderActionPendingReplace: true,
+		OrderActionReplace:        true,
+		OrderActionTrade:          true,
+		OrderActionRestate:        true,
+	},
+	// a fill may cross a cancel or replace on its way to the book
+	OrderStatusPendingCancel: {
+		OrderActionCancel:  true,
+		OrderActionTrade:   true,
+		OrderActionRestate: true,
+	},
+	OrderStatusPendingReplace: {
+		OrderActionPendingCancel: true,
+		OrderActionCancel:        true,
+		OrderActionReplace:       true,
+		OrderActionTrade:         true,
+		OrderActionRestate:       true,
+	},
+}
+
+// CanTransition reports whether action is allowed on an order in status.
+func CanTransition(status OrderStatus, action OrderAction) bool {
+	return orderTransitions[status][action]
+}
+
+func (s *Order) checkTransition(action OrderAction) error {
+	if !CanTransition(s.Status, action) {
+		return fmt.Errorf("%w: %s on %s order %s", ErrInvalidTransition, action, s.Status, s.OrderID)
+	}
+	return nil
+}
+
+// nextStatus is the status after action, trades and replaces take the
+// status from the quantities already updated on the order.
+func (s *Order) nextStatus(action OrderAction) OrderStatus {
+	switch action {
+	case OrderActionAccept:
+		return OrderStatusNew
+	case OrderActionReject:
+		return OrderStatusRejected
+	case OrderActionHold:
+		return OrderStatusPendingNew
+	case OrderActionPendingCancel:
+		return OrderStatusPendingCancel
+	case OrderActionCancel:
+		return OrderStatusCanceled
+	case OrderActionPendingReplace:
+		return OrderStatusPendingReplace
+	case OrderActionReplace, OrderActionTrade:
+		return s.fillStatus()
+	}
+	return s.Status
+}
+
+func (s *Order) fillStatus() OrderStatus {
+	switch {
+	case s.CumQuantity > 0 && s.LeavesQuantity <= 0:
+		return OrderStatusFilled
+	case s.CumQuantity > 0:
+		return OrderStatusPartiallyFilled
+	}
+	return OrderStatusNew
+}
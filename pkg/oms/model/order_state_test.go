@@ -0,0 +1,269 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	allStatuses = []OrderStatus{
+		OrderStatusNew, OrderStatusPartiallyFilled, OrderStatusFilled, OrderStatusDoneForDay,
+		OrderStatusCanceled, OrderStatusReplaced, OrderStatusPendingCancel, OrderStatusStopped,
+		OrderStatusRejected, OrderStatusSuspended, OrderStatusPendingNew, OrderStatusCalculated,
+		OrderStatusExpired, OrderStatusAcceptedForBidding, OrderStatusPendingReplace,
+	}
+	allActions = []OrderAction{
+		OrderActionAccept, OrderActionReject, OrderActionHold, OrderActionPendingCancel,
+		OrderActionCancel, OrderActionPendingReplace, OrderActionReplace, OrderActionTrade,
+		OrderActionRestate,
+	}
+)
+
+// TestOrderTransitionMatrix checks every status against every action, what
+// is not listed must be refused.
+func TestOrderTransitionMatrix(t *testing.T) {
+	live := []OrderAction{OrderActionPendingCancel, OrderActionCancel, OrderActionPendingReplace,
+		OrderActionReplace, OrderActionTrade, OrderActionRestate}
+	allowed := map[OrderStatus][]OrderAction{
+		// received: the book takes or refuses it, a cancel may come first
+		OrderStatusPendingNew: {OrderActionAccept, OrderActionReject, OrderActionHold,
+			OrderActionPendingCancel, OrderActionCancel, OrderActionRestate},
+		OrderStatusNew:             live,
+		OrderStatusPartiallyFilled: live,
+		// no replace while a cancel is pending
+		OrderStatusPendingCancel: {OrderActionCancel, OrderActionTrade, OrderActionRestate},
+		OrderStatusPendingReplace: {OrderActionPendingCancel, OrderActionCancel, OrderActionReplace,
+			OrderActionTrade, OrderActionRestate},
+	}
+
+	for _, status := range allStatuses {
+		want := make(map[OrderAction]bool)
+		for _, action := range allowed[status] {
+			want[action] = true
+		}
+		for _, action := range allActions {
+			if got := CanTransition(status, action); got != want[action] {
+				t.Errorf("%s on %s: expected %v, got %v", action, status, want[action], got)
+			}
+		}
+	}
+}
+
+func TestOrderTerminalStatuses(t *testing.T) {
+	for _, status := range []OrderStatus{OrderStatusFilled, OrderStatusCanceled, OrderStatusRejected, OrderStatusExpired, OrderStatusReplaced} {
+		order := &Order{Status: status}
+		if order.CanCancel() || order.CanModify() {
+			t.Errorf("%s: expected no cancel or replace", status)
+		}
+	}
+}
+
+func newTestOrder(qty int64) *Order {
+	order := &Order{}
+	order.UpdateAddOrder(&AddOrder{
+		GatewayID: "A",
+		Symbol:    "TEST",
+		Side:      OrderSideBuy,
+		Type:      OrderTypeLimit,
+		Price:     decimal.NewFromInt(100),
+		Quantity:  decimal.NewFromInt(qty),
+	})
+	return order
+}
+
+func fill(t *testing.T, order *Order, qty int64) {
+	t.Helper()
+	if err := order.UpdateMatchResult(&orderbook.MatchResult{OrderID: order.OrderID, ExactPrice: decimal.NewFromInt(100), Qty: qty}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectStatus(t *testing.T, order *Order, status OrderStatus, execType OrderExecType) {
+	t.Helper()
+	if order.Status != status || order.ExecType != execType {
+		t.Fatalf("expected %s/%s, got %s/%s", status, execType, order.Status, order.ExecType)
+	}
+}
+
+func modify(qty int64) *ModifyOrder {
+	return &ModifyOrder{GatewayID: "B", OrigGatewayID: "A", NewPrice: decimal.NewFromInt(100), NewQuantity: decimal.NewFromInt(qty)}
+}
+
+func TestFilledOrder(t *testing.T) {
+	order := newTestOrder(10000)
+	expectStatus(t, order, OrderStatusPendingNew, ExecTypePendingNew)
+	if err := order.UpdateNew(); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusNew, ExecTypeNew)
+	fill(t, order, 2000)
+	expectStatus(t, order, OrderStatusPartiallyFilled, ExecTypeTrade)
+	fill(t, order, 8000)
+	expectStatus(t, order, OrderStatusFilled, ExecTypeTrade)
+
+	if err := order.UpdateCancelOrder(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected cancel of a filled order refused, got %v", err)
+	}
+	if err := order.UpdateModifyOrder(modify(12000)); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected replace of a filled order refused, got %v", err)
+	}
+	expectStatus(t, order, OrderStatusFilled, ExecTypeTrade)
+}
+
+func TestRejectedOrder(t *testing.T) {
+	order := newTestOrder(10000)
+	if err := order.UpdateReject(RejectReasonOther, "no"); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusRejected, ExecTypeRejected)
+	if err := order.UpdateNew(); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected a rejected order not to be accepted, got %v", err)
+	}
+	if err := order.UpdateReject(RejectReasonOther, "again"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected a rejected order not to be rejected again, got %v", err)
+	}
+}
+
+func TestAcceptedOrderCannotBeRejected(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	if err := order.UpdateReject(RejectReasonOther, "late"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected reject of a New order refused, got %v", err)
+	}
+	if err := order.UpdateNew(); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected a New order not to be accepted twice, got %v", err)
+	}
+}
+
+// Cancel request issued for a partially filled order.
+func TestCancelPartiallyFilledOrder(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	fill(t, order, 2000)
+
+	if err := order.UpdatePendingCancel(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusPendingCancel, ExecTypePendingCancel)
+	if order.LeavesQuantity != 8000 {
+		t.Fatalf("expected leaves 8000 while pending, got %d", order.LeavesQuantity)
+	}
+	if err := order.UpdateCancelOrder(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusCanceled, ExecTypeCanceled)
+	if order.CumQuantity != 2000 || order.LeavesQuantity != 0 {
+		t.Fatalf("expected cum 2000 leaves 0, got %d/%d", order.CumQuantity, order.LeavesQuantity)
+	}
+	if err := order.UpdateCancelOrder(&CancelOrder{GatewayID: "D", OrigGatewayID: "C"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected a canceled order not to be canceled again, got %v", err)
+	}
+}
+
+// Cancel request issued for an order that fills while the cancel is pending.
+func TestFillWhilePendingCancel(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	_ = order.UpdatePendingCancel(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"})
+
+	if err := order.UpdateModifyOrder(modify(12000)); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected replace refused while a cancel is pending, got %v", err)
+	}
+	fill(t, order, 3000)
+	expectStatus(t, order, OrderStatusPartiallyFilled, ExecTypeTrade)
+	if err := order.UpdateCancelOrder(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusCanceled, ExecTypeCanceled)
+}
+
+// Replace of a partially filled order keeps its fill status.
+func TestReplacePartiallyFilledOrder(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	fill(t, order, 1000)
+
+	if err := order.UpdatePendingReplace(modify(12000)); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusPendingReplace, ExecTypePendingReplace)
+	if order.Quantity != 10000 {
+		t.Fatalf("expected quantity 10000 while pending, got %d", order.Quantity)
+	}
+	if err := order.UpdateModifyOrder(modify(12000)); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusPartiallyFilled, ExecTypeReplaced)
+	if order.Quantity != 12000 || order.LeavesQuantity != 11000 {
+		t.Fatalf("expected qty 12000 leaves 11000, got %d/%d", order.Quantity, order.LeavesQuantity)
+	}
+}
+
+// Replace of an unfilled order reports New.
+func TestReplaceNewOrder(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	if err := order.UpdateModifyOrder(modify(5000)); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusNew, ExecTypeReplaced)
+}
+
+// Replace down to the filled quantity fills the order.
+func TestReplaceToFilledQuantity(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	fill(t, order, 3000)
+
+	if err := order.UpdateModifyOrder(modify(2000)); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusFilled, ExecTypeReplaced)
+	if order.LeavesQuantity != 0 {
+		t.Fatalf("expected leaves 0, got %d", order.LeavesQuantity)
+	}
+}
+
+// Cancel of a replace still pending.
+func TestCancelWhilePendingReplace(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	_ = order.UpdatePendingReplace(modify(12000))
+
+	if err := order.UpdatePendingCancel(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := order.UpdateCancelOrder(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusCanceled, ExecTypeCanceled)
+}
+
+// Cancel of an order not yet accepted by the book.
+func TestCancelPendingNewOrder(t *testing.T) {
+	order := newTestOrder(10000)
+	if order.CanModify() {
+		t.Fatal("expected no replace before the order is accepted")
+	}
+	if err := order.UpdateCancelOrder(&CancelOrder{GatewayID: "C", OrigGatewayID: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, order, OrderStatusCanceled, ExecTypeCanceled)
+}
+
+func TestRefusedTransitionLeavesOrderUnchanged(t *testing.T) {
+	order := newTestOrder(10000)
+	_ = order.UpdateNew()
+	fill(t, order, 10000)
+	before := *order
+
+	_ = order.UpdateRestateQuantity(5000)
+	_ = order.UpdateRestatePrice(decimal.NewFromInt(90))
+	_ = order.UpdateMatchResult(&orderbook.MatchResult{ExactPrice: decimal.NewFromInt(100), Qty: 1})
+	if *order != before {
+		t.Fatalf("expected order unchanged, got %+v", order)
+	}
+}
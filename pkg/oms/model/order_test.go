@@ -0,0 +1,107 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func TestAvgPriceIsExact(t *testing.T) {
+	order := newTestOrder(3_000_000)
+	_ = order.UpdateNew()
+
+	// a float running average drifts on large VND notionals
+	for i := 0; i < 1000; i++ {
+		price := decimal.NewFromInt(28000)
+		if i%3 == 1 {
+			price = decimal.NewFromInt(28050)
+		}
+		if i%3 == 2 {
+			price = decimal.NewFromInt(28100)
+		}
+		if err := order.UpdateMatchResult(&orderbook.MatchResult{ExactPrice: price, Qty: 1000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 334 fills at 28000, 333 at 28050 and 333 at 28100
+	value := decimal.NewFromInt(334*28000 + 333*28050 + 333*28100).Mul(decimal.NewFromInt(1000))
+	if !order.CumValue.Equal(value) {
+		t.Fatalf("expected traded value %s, got %s", value, order.CumValue)
+	}
+	want := value.DivRound(decimal.NewFromInt(1_000_000), AvgPriceDecimals)
+	if !order.AvgPrice.Equal(want) {
+		t.Fatalf("expected avg price %s, got %s", want, order.AvgPrice)
+	}
+	if !order.LastPrice.Equal(decimal.NewFromInt(28000)) {
+		t.Fatalf("expected last price 28000, got %s", order.LastPrice)
+	}
+}
+
+func TestOrderEventCarriesFillPrices(t *testing.T) {
+	order := newTestOrder(200)
+	_ = order.UpdateNew()
+	_ = order.UpdateMatchResult(&orderbook.MatchResult{ExactPrice: decimal.NewFromInt(28050), Qty: 100})
+
+	event := NewOrderEvent(*order, order.LastUpdate)
+	if !event.Price.Equal(decimal.NewFromInt(100)) || event.LastQty != 100 ||
+		!event.LastPrice.Equal(decimal.NewFromInt(28050)) || !event.AvgPrice.Equal(decimal.NewFromInt(28050)) {
+		t.Fatalf("unexpected event prices %+v", event)
+	}
+}
+
+func TestOrderFromEventRoundTrip(t *testing.T) {
+	order := newTestOrder(200)
+	order.Account = "ACC"
+	order.ListID = "L1"
+	_ = order.UpdateNew()
+	_ = order.UpdateMatchResult(&orderbook.MatchResult{ExactPrice: decimal.NewFromInt(100), Qty: 30})
+
+	got := NewOrderFromEvent(NewOrderEvent(*order, order.LastUpdate))
+	if *got != *order {
+		t.Fatalf("expected %+v, got %+v", order, got)
+	}
+}
+
+func TestEventIDPerEvent(t *testing.T) {
+	order := newTestOrder(300)
+	_ = order.UpdateNew()
+	var eventIDs []string
+	for i := 0; i < 2; i++ {
+		_ = order.UpdateMatchResult(&orderbook.MatchResult{ExactPrice: decimal.NewFromInt(100), Qty: 100})
+		order.Seq++
+		eventIDs = append(eventIDs, NewOrderEvent(*order, order.LastUpdate).EventID)
+	}
+	if order.Status != OrderStatusPartiallyFilled || eventIDs[0] == eventIDs[1] {
+		t.Fatalf("expected two PartiallyFilled events apart, got %v", eventIDs)
+	}
+	// a redelivered event keeps its ID
+	if id := NewOrderEvent(*order, order.LastUpdate).EventID; id != eventIDs[1] {
+		t.Fatalf("expected %s, got %s", eventIDs[1], id)
+	}
+}
+
+func TestExecIDPerReport(t *testing.T) {
+	order := newTestOrder(300)
+	execIDs := []string{order.ExecID}
+	_ = order.UpdateNew()
+	execIDs = append(execIDs, order.ExecID)
+	_ = order.UpdateMatchResult(&orderbook.MatchResult{ExactPrice: decimal.NewFromInt(100), Qty: 100})
+	execIDs = append(execIDs, order.ExecID)
+	_ = order.UpdateModifyOrder(modify(400))
+	execIDs = append(execIDs, order.ExecID)
+	_ = order.UpdateCancelOrder(&CancelOrder{GatewayID: "C", OrigGatewayID: "B"})
+	execIDs = append(execIDs, order.ExecID)
+
+	seen := make(map[string]struct{})
+	for _, execID := range execIDs {
+		if _, ok := seen[execID]; ok {
+			t.Fatalf("ExecID %s reused in %v", execID, execIDs)
+		}
+		seen[execID] = struct{}{}
+	}
+	if order.LastExecID != execIDs[3] {
+		t.Fatalf("expected LastExecID %s, got %s", execIDs[3], order.LastExecID)
+	}
+}
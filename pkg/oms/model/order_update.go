@@ -14,15 +14,23 @@ type AddOrder struct {
 	Exchange     string
 	Type         OrderType
 	Price        decimal.Decimal
+	StopPrice    decimal.Decimal
+	PegType      OrderPegType
+	PegOffset    decimal.Decimal
+	Hidden       bool // display quantity zero
+	ShortSell    bool // FIX Side=5
 	TimeInForce  OrderTimeInForce
 	Side         OrderSide
 	TransactTime time.Time
 	Quantity     decimal.Decimal
+	ListID       string
+	SessionID    string // gateway session, for session kill switches
 }
 
 type CancelOrder struct {
 	GatewayID     string
 	OrigGatewayID string
+	Symbol        string // routes the request when OrigGatewayID is not known
 }
 
 type ModifyOrder struct {
@@ -30,4 +38,5 @@ type ModifyOrder struct {
 	NewQuantity   decimal.Decimal
 	GatewayID     string
 	OrigGatewayID string
+	Symbol        string // routes the request when OrigGatewayID is not known
 }
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PutThrough is a negotiated trade agreed between a buy and a sell account
+// outside the continuous order book.
+type PutThrough struct {
+	CrossID       string
+	Symbol        string
+	SecurityID    string
+	Price         decimal.Decimal
+	Quantity      decimal.Decimal
+	TransactTime  time.Time
+	BuyGatewayID  string
+	BuyAccount    string
+	SellGatewayID string
+	SellAccount   string
+}
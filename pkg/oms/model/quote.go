@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Quote is the two-sided quote of a market maker. An account has at most one
+// quote per symbol: a new quote replaces both sides of the previous one and
+// a side with zero size is not quoted. Zero size on both sides pulls the
+// quote.
+type Quote struct {
+	QuoteID      string
+	QuoteEntryID string // entry of a mass quote, empty for a single quote
+	Account      string
+	Symbol       string
+	SecurityID   string
+	BidPx        decimal.Decimal
+	BidSize      decimal.Decimal
+	OfferPx      decimal.Decimal
+	OfferSize    decimal.Decimal
+	TransactTime time.Time
+	SessionID    string
+}
+
+// SideGatewayID is the ClOrdID the bid or the offer of q is reported with:
+// QuoteID, the QuoteEntryID of a mass quote entry and -B or -S. Every side
+// has its own so each keeps its own ClOrdID chain.
+func (q *Quote) SideGatewayID(side OrderSide) string {
+	id := q.QuoteID
+	if q.QuoteEntryID != "" {
+		id += "-" + q.QuoteEntryID
+	}
+	if side == OrderSideBuy {
+		return id + "-B"
+	}
+	return id + "-S"
+}
+
+// MassQuote carries quotes for several symbols in one request.
+type MassQuote struct {
+	QuoteID   string
+	Account   string
+	SessionID string
+	Quotes    []*Quote
+}
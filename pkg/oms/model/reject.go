@@ -0,0 +1,39 @@
+package model
+
+type OrderRejectReason string
+
+const (
+	RejectReasonBrokerOption          OrderRejectReason = "BrokerOption"
+	RejectReasonUnknownSymbol         OrderRejectReason = "UnknownSymbol"
+	RejectReasonExchangeClosed        OrderRejectReason = "ExchangeClosed"
+	RejectReasonOrderExceedsLimit     OrderRejectReason = "OrderExceedsLimit"
+	RejectReasonTooLateToEnter        OrderRejectReason = "TooLateToEnter"
+	RejectReasonUnknownOrder          OrderRejectReason = "UnknownOrder"
+	RejectReasonDuplicateOrder        OrderRejectReason = "DuplicateOrder"
+	RejectReasonUnsupportedOrder      OrderRejectReason = "UnsupportedOrderCharacteristic"
+	RejectReasonIncorrectQuantity     OrderRejectReason = "IncorrectQuantity"
+	RejectReasonUnknownAccount        OrderRejectReason = "UnknownAccount"
+	RejectReasonPriceExceedsBand      OrderRejectReason = "PriceExceedsCurrentPriceBand"
+	RejectReasonInvalidPriceIncrement OrderRejectReason = "InvalidPriceIncrement"
+	RejectReasonOther                 OrderRejectReason = "Other"
+)
+
+type CancelRejectResponseTo string
+
+const (
+	CancelRejectResponseToCancel  CancelRejectResponseTo = "CancelRequest"
+	CancelRejectResponseToReplace CancelRejectResponseTo = "CancelReplaceRequest"
+)
+
+// CancelReject answers a cancel or replace request that was not applied, the
+// order keeps its current state.
+type CancelReject struct {
+	OrderID       string
+	GatewayID     string // ClOrdID of the rejected request
+	OrigGatewayID string
+	Account       string
+	Status        OrderStatus // current status of the order
+	ResponseTo    CancelRejectResponseTo
+	Reason        OrderRejectReason
+	Text          string
+}
@@ -7,8 +7,12 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/joripage/orderbook-dev/pkg/oms/audit"
 	eventstore "github.com/joripage/orderbook-dev/pkg/oms/event_store"
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/ledger"
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
 	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
 	"github.com/joripage/orderbook-dev/pkg/orderbook"
 )
@@ -16,35 +20,120 @@ import (
 type OMS struct {
 	orderGateway     OrderGateway
 	orderbookManager *orderbook.OrderBookManager
+	oddLotManager    *orderbook.OrderBookManager
 	eventstore       eventstore.EventStore
+	instruments      *instrument.Store
 
 	orderIDMapping sync.Map
 	stopCh         chan struct{}
+	started        atomic.Bool // Recover is refused once started
+	pendingAcks    bool
 	// gatewayIDMapping sync.Map
 
-	rules []riskrule.RiskRule
+	// engaged kill switches, checked before the risk chain
+	killMu       sync.RWMutex
+	killSwitches map[string]*model.KillSwitch
+	audit        *audit.Log
+
+	// pre-trade risk chain, run before an order reaches the book
+	risk *riskrule.Chain
+
+	// config in force, swapped by ReloadConfig
+	reload   *ReloadConfig
+	reloadMu sync.Mutex
+	// the risk chain and its version are swapped together, an order is never
+	// stamped with the version of another chain
+	configMu      sync.RWMutex
+	configVersion int64
+	configDigest  string
+	configPaths   map[string]time.Time // mod time of the loaded files
+	// account cash, buy orders reserve their cost at entry
+	ledger *ledger.Ledger
+	// account holdings, sell orders lock their quantity at entry
+	positions *position.Positions
+
+	// contingent order lists (OCO, bracket)
+	groupMu     sync.Mutex
+	orderGroups map[string]*orderGroup // orderID -> group
+
+	tradeStats *tradeStatsStore
+
+	// stop orders waiting for their trigger price, by symbol
+	stopMu     sync.Mutex
+	stopOrders map[string][]*model.Order
+
+	// market maker quotes by account and symbol
+	quoteMu sync.Mutex
+	quotes  map[string]*quote
+}
+
+type OMSConfig struct {
+	// Instruments is the instrument master used for board lot validation,
+	// nil disables it.
+	Instruments *instrument.Store
+	// Risk is the pre-trade risk chain, nil disables pre-trade checks.
+	Risk *riskrule.Chain
+	// Ledger tracks account cash, nil disables cash reservations.
+	Ledger *ledger.Ledger
+	// Positions tracks account holdings, nil disables sell locks.
+	Positions *position.Positions
+	// Audit records operator actions, nil disables it.
+	Audit *audit.Log
+	// Reload lets ReloadConfig swap the risk chain and the instrument
+	// master, nil disables it.
+	Reload *ReloadConfig
+	// PendingAcks reports PendingNew, PendingCancel and PendingReplace
+	// when a request is received, before the result of the book.
+	PendingAcks bool
 }
 
 var totalMatchQty int64 = 0
 var totalMatchCount int64 = 0
 
-func NewOMS(orderGateway OrderGateway) *OMS {
+func NewOMS(orderGateway OrderGateway, cfg *OMSConfig) *OMS {
+	if cfg == nil {
+		cfg = &OMSConfig{}
+	}
+	risk := cfg.Risk
+	if risk == nil && cfg.Reload != nil {
+		risk, _ = riskrule.NewChain(nil, nil)
+	}
 	orderbookManager := orderbook.NewOrderBookManager(&orderbook.OrderBookManagerConfig{
 		EnableIceberg: true,
 	})
+	// odd-lot board: plain limit orders only
+	oddLotManager := orderbook.NewOrderBookManager(&orderbook.OrderBookManagerConfig{})
 
 	oms := &OMS{
 		orderGateway:     orderGateway,
 		orderbookManager: orderbookManager,
+		oddLotManager:    oddLotManager,
 		eventstore:       eventstore.NewInMemoryEventStore(),
+		instruments:      cfg.Instruments,
+		risk:             risk,
+		reload:           cfg.Reload,
+		ledger:           cfg.Ledger,
+		positions:        cfg.Positions,
+		killSwitches:     make(map[string]*model.KillSwitch),
+		audit:            cfg.Audit,
 		stopCh:           make(chan struct{}),
+		pendingAcks:      cfg.PendingAcks,
+		tradeStats:       newTradeStatsStore(),
+		orderGroups:      make(map[string]*orderGroup),
+		stopOrders:       make(map[string][]*model.Order),
+		quotes:           make(map[string]*quote),
 	}
+	orderbookManager.RegisterRestateCallback(oms.onRestated)
+	orderbookManager.SetPriceRule(oms.bookPrice)
+	oms.risk.SetPriceSource(oms)
+	oms.ledger.SetPriceScale(oms.risk)
 	go oms.startCleaner(10 * time.Second)
 
 	return oms
 }
 
 func (s *OMS) Start(ctx context.Context) {
+	s.started.Store(true)
 	s.orderGateway.Start(ctx)
 }
 
@@ -52,99 +141,355 @@ func (s *OMS) Stop() {
 	close(s.stopCh)
 }
 
+// AddOrder answers every new order with a report, a refused order is
+// reported Rejected with its reason and the error is returned as well.
 func (s *OMS) AddOrder(ctx context.Context, addOrder *model.AddOrder) error {
-	// todo: check riskrule
-	orderID := s.eventstore.GetLatestGatewayID(addOrder.GatewayID)
-	if orderID != "" {
-		return errDuplicateOrder
-	}
-
 	order := &model.Order{}
 	order.UpdateAddOrder(addOrder)
+	if err := s.acceptOrder(addOrder, order); err != nil {
+		s.rejectOrder(ctx, order, err)
+		return err
+	}
 	s.AddOrderToMap(order)
 
-	results := s.orderbookManager.AddOrder(&orderbook.Order{
+	s.submitOrder(ctx, order)
+
+	return nil
+}
+
+// acceptOrder runs the entry checks of a new order: duplicate, validation,
+// kill switch, board and then the pre-trade risk chain.
+func (s *OMS) acceptOrder(addOrder *model.AddOrder, order *model.Order) error {
+	if s.isDuplicate(addOrder.GatewayID) {
+		return errDuplicateOrder
+	}
+	if err := s.validateAddOrder(addOrder); err != nil {
+		return err
+	}
+	if err := s.checkKillSwitch(addOrder.Account, addOrder.SessionID, addOrder.Symbol); err != nil {
+		return err
+	}
+
+	board, err := s.resolveBoard(addOrder.Symbol, addOrder.Quantity.IntPart(), addOrder.Type, addOrder.TimeInForce)
+	if err != nil {
+		return err
+	}
+
+	if addOrder.Hidden && addOrder.Type != model.OrderTypeLimit {
+		return errInvalidHiddenOrder
+	}
+
+	order.Board = board
+	if addOrder.PegType != "" {
+		if order.Price, err = s.pegPrice(addOrder, board); err != nil {
+			return err
+		}
+	}
+
+	return s.preTrade(order)
+}
+
+// isDuplicate reports whether gatewayID belongs to an order, the ClOrdID of
+// a rejected order may be sent again.
+func (s *OMS) isDuplicate(gatewayID string) bool {
+	orderID := s.eventstore.GetOrderID(gatewayID)
+	if orderID == "" {
+		return false
+	}
+	order, err := s.GetOrderByOrderID(orderID)
+	return err != nil || order.Status != model.OrderStatusRejected
+}
+
+// validateAddOrder rejects what no book can take: an unsupported type or
+// side, a non positive quantity or stop price or a symbol missing from the
+// instrument master.
+func (s *OMS) validateAddOrder(addOrder *model.AddOrder) error {
+	switch addOrder.Type {
+	case model.OrderTypeLimit, model.OrderTypeMarket, model.OrderTypeIceberg, model.OrderTypeStop:
+	default:
+		return errUnsupportedOrderType
+	}
+	if addOrder.Side != model.OrderSideBuy && addOrder.Side != model.OrderSideSell {
+		return errUnsupportedSide
+	}
+	if !addOrder.Quantity.IsPositive() {
+		return errInvalidQuantity
+	}
+	// a stop at zero would trigger on the first trade
+	if addOrder.Type == model.OrderTypeStop && !addOrder.StopPrice.IsPositive() {
+		return errInvalidStopPrice
+	}
+	if s.instruments != nil {
+		if _, ok := s.instruments.Get(addOrder.Symbol); !ok {
+			return errUnknownSymbol
+		}
+	}
+	return nil
+}
+
+// submitOrder sends a new order to its book, or parks it until triggered
+// when it is a stop order, and reports it to the gateway.
+func (s *OMS) submitOrder(ctx context.Context, order *model.Order) {
+	// an activated bracket leg was acknowledged when it was held
+	pending := order.Status == model.OrderStatusPendingNew
+	if pending && s.pendingAcks {
+		s.reportOrder(ctx, order)
+	}
+
+	if order.Type == model.OrderTypeStop {
+		s.addStopOrder(order)
+		if pending {
+			order.UpdateNew()
+		}
+		s.reportOrder(ctx, order)
+		return
+	}
+
+	results := s.bookManager(order).AddOrder(toBookOrder(order))
+
+	// book success -> change pending new to new
+	if pending {
+		order.UpdateNew()
+	}
+	s.reportOrder(ctx, order)
+
+	s.processMatchResult(results)
+
+	// IOC, FOK and market orders never rest, what is left is canceled
+	if !resting(order) {
+		s.cancelRemainder(ctx, order)
+	}
+}
+
+// resting reports whether the book keeps what order does not fill.
+func resting(order *model.Order) bool {
+	return order.TimeInForce != model.OrderTimeInForceIOC && order.TimeInForce != model.OrderTimeInForceFOK &&
+		order.Type != model.OrderTypeMarket
+}
+
+// cancelRemainder ends an order the book does not keep once it is matched,
+// what is left is reported Canceled and releases its cash and holdings.
+func (s *OMS) cancelRemainder(ctx context.Context, order *model.Order) {
+	if order.IsEnd() || order.LeavesQuantity <= 0 {
+		return
+	}
+	if err := order.UpdateCancelOrder(&model.CancelOrder{
+		GatewayID:     order.GatewayID,
+		OrigGatewayID: order.OrigGatewayID,
+	}); err != nil {
+		log.Printf("cancel remainder orderID=%s err=%v", order.OrderID, err)
+		return
+	}
+	s.reportOrder(ctx, order)
+}
+
+func toBookOrder(order *model.Order) *orderbook.Order {
+	return &orderbook.Order{
 		ID:          order.OrderID,
 		Symbol:      order.Symbol,
 		Side:        orderbook.Side(order.Side),
-		Price:       order.Price,
-		Qty:         order.Quantity,
+		Price:       order.Price.InexactFloat64(),
+		ExactPrice:  order.Price,
+		Qty:         order.LeavesQuantity,
 		Type:        orderbook.OrderType(order.Type),
 		TimeInForce: orderbook.TimeInForce(order.TimeInForce),
-	})
+		Hidden:      order.Hidden,
+		PegType:     orderbook.PegType(order.PegType),
+		PegOffset:   order.PegOffset,
+	}
+}
 
-	// book success -> change pending new to new
+// reportOrder stores an event of the current order state and sends it to
+// the gateway.
+func (s *OMS) reportOrder(ctx context.Context, order *model.Order) {
+	order.Seq++
 	bkOrder := *order
-	now := time.Now()
-	// ov, fnReset := model.NewOrderEventUsingPool(bkOrder, now)
-	// s.eventstore.AddEvent(ov)
-	// fnReset()
-	s.eventstore.AddEvent(model.NewOrderEvent(bkOrder, now))
+	s.ledger.Apply(bkOrder)
+	s.positions.Apply(bkOrder)
+	event := model.NewOrderEvent(bkOrder, time.Now())
+	event.ConfigVersion = s.ConfigVersion()
+	s.eventstore.AddEvent(event)
 	s.orderGateway.OnOrderReport(ctx, bkOrder)
-
-	s.processMatchResult(results)
-
-	return nil
 }
 
+// CancelOrder never goes through the pre-trade risk chain. A refused cancel
+// is answered by an OrderCancelReject.
 func (s *OMS) CancelOrder(ctx context.Context, cancelOrder *model.CancelOrder) error {
-	// todo: check riskrule
 	orderID := s.eventstore.GetOrderID(cancelOrder.OrigGatewayID)
 	order, err := s.GetOrderByOrderID(orderID)
 	if err != nil {
+		s.rejectCancel(ctx, unknownOrder, cancelOrder.GatewayID, cancelOrder.OrigGatewayID, model.CancelRejectResponseToCancel, errGatewayIDNotFound)
 		return errGatewayIDNotFound
 	}
 
-	if !order.CanCancel() {
+	if err := checkCancel(order); err != nil {
+		s.rejectCancel(ctx, order, cancelOrder.GatewayID, cancelOrder.OrigGatewayID, model.CancelRejectResponseToCancel, err)
+		return err
+	}
+
+	booked := onBook(order)
+	prev := *order
+	if s.pendingAcks {
+		if err := order.UpdatePendingCancel(cancelOrder); err != nil {
+			s.rejectCancel(ctx, order, cancelOrder.GatewayID, cancelOrder.OrigGatewayID, model.CancelRejectResponseToCancel, errInvalidOrderStatus)
+			return errInvalidOrderStatus
+		}
+		s.reportOrder(ctx, order)
+	}
+
+	if booked {
+		if err := s.bookManager(order).CancelOrder(order.Symbol, order.OrderID); err != nil {
+			order.UpdatePendingRejected(&prev)
+			s.rejectCancel(ctx, order, cancelOrder.GatewayID, cancelOrder.OrigGatewayID, model.CancelRejectResponseToCancel, err)
+			return err
+		}
+	}
+	s.removeStopOrder(order)
+	if err := order.UpdateCancelOrder(cancelOrder); err != nil {
+		log.Printf("cancel orderID=%s err=%v", order.OrderID, err)
+		s.rejectCancel(ctx, order, cancelOrder.GatewayID, cancelOrder.OrigGatewayID, model.CancelRejectResponseToCancel, errInvalidOrderStatus)
 		return errInvalidOrderStatus
 	}
+	s.reportOrder(ctx, order)
 
-	err = s.orderbookManager.CancelOrder(order.Symbol, order.OrderID)
-	_ = err
-	order.UpdateCancelOrder(cancelOrder)
+	s.onListOrderCanceled(ctx, order)
 
-	bkOrder := *order
-	now := time.Now()
-	// ov, fnReset := model.NewOrderEventUsingPool(bkOrder, now)
-	// s.eventstore.AddEvent(ov)
-	// fnReset()
-	s.eventstore.AddEvent(model.NewOrderEvent(bkOrder, now))
-	s.orderGateway.OnOrderReport(ctx, bkOrder)
+	return nil
+}
+
+// onBook reports whether order rests on a book, parked stop orders and held
+// bracket legs wait in the OMS.
+func onBook(order *model.Order) bool {
+	return order.Type != model.OrderTypeStop && order.Status != model.OrderStatusPendingNew
+}
+
+// unknownOrder stands for the order of a cancel or replace request whose
+// OrigClOrdID matches no order.
+var unknownOrder = &model.Order{OrderID: "NONE", Status: model.OrderStatusRejected}
 
+func checkCancel(order *model.Order) error {
+	if order.QuoteID != "" {
+		return errQuoteSide
+	}
+	if !order.CanCancel() {
+		return errInvalidOrderStatus
+	}
 	return nil
 }
 
+// ModifyOrder replaces the price and quantity of an order. A refused replace
+// is answered by an OrderCancelReject.
 func (s *OMS) ModifyOrder(ctx context.Context, modifyOrder *model.ModifyOrder) error {
-	// todo: check riskrule
+	// the request is completed below, the caller keeps its own
+	req := *modifyOrder
+	modifyOrder = &req
+
 	orderID := s.eventstore.GetOrderID(modifyOrder.OrigGatewayID)
 	order, err := s.GetOrderByOrderID(orderID)
 	if err != nil {
+		s.rejectCancel(ctx, unknownOrder, modifyOrder.GatewayID, modifyOrder.OrigGatewayID, model.CancelRejectResponseToReplace, errGatewayIDNotFound)
 		return errGatewayIDNotFound
 	}
 
-	if !order.CanModify() {
-		return errInvalidOrderStatus
+	replaced, err := s.checkModify(order, modifyOrder)
+	if err != nil {
+		s.rejectCancel(ctx, order, modifyOrder.GatewayID, modifyOrder.OrigGatewayID, model.CancelRejectResponseToReplace, err)
+		return err
 	}
 
-	newPrice, newQty := modifyOrder.NewPrice.InexactFloat64(), modifyOrder.NewQuantity.IntPart()
-	results, err := s.orderbookManager.ModifyOrder(order.Symbol, order.OrderID, newPrice, newQty)
-	_ = err
-	order.UpdateModifyOrder(modifyOrder)
+	prev := *order
+	if s.pendingAcks {
+		if err := order.UpdatePendingReplace(modifyOrder); err != nil {
+			s.rejectCancel(ctx, order, modifyOrder.GatewayID, modifyOrder.OrigGatewayID, model.CancelRejectResponseToReplace, errInvalidOrderStatus)
+			return errInvalidOrderStatus
+		}
+		s.reportOrder(ctx, order)
+	}
 
-	bkOrder := *order
-	now := time.Now()
-	// ov, fnReset := model.NewOrderEventUsingPool(bkOrder, now)
-	// s.eventstore.AddEvent(ov)
-	// fnReset()
-	s.eventstore.AddEvent(model.NewOrderEvent(bkOrder, now))
-	s.orderGateway.OnOrderReport(ctx, bkOrder)
+	// a replace costing more holds the increase before the book applies it,
+	// a refused replace gives it back
+	if s.ledger.Required(replaced).IsPositive() {
+		if err := cashReject(s.ledger.Resize(replaced)); err != nil {
+			order.UpdatePendingRejected(&prev)
+			s.rejectCancel(ctx, order, modifyOrder.GatewayID, modifyOrder.OrigGatewayID, model.CancelRejectResponseToReplace, err)
+			return err
+		}
+	}
+
+	// a parked stop order is replaced in the OMS only, the book keeps the
+	// leaves
+	var results []*orderbook.MatchResult
+	if onBook(&prev) {
+		results, err = s.bookManager(order).ModifyOrder(order.Symbol, order.OrderID, modifyOrder.NewPrice, replaced.LeavesQuantity)
+		if err != nil {
+			_ = s.ledger.Resize(&prev)
+			order.UpdatePendingRejected(&prev)
+			s.rejectCancel(ctx, order, modifyOrder.GatewayID, modifyOrder.OrigGatewayID, model.CancelRejectResponseToReplace, err)
+			return err
+		}
+	}
+	if err := order.UpdateModifyOrder(modifyOrder); err != nil {
+		log.Printf("replace orderID=%s err=%v", order.OrderID, err)
+		_ = s.ledger.Resize(&prev)
+		s.rejectCancel(ctx, order, modifyOrder.GatewayID, modifyOrder.OrigGatewayID, model.CancelRejectResponseToReplace, errInvalidOrderStatus)
+		return errInvalidOrderStatus
+	}
+	s.reportOrder(ctx, order)
 
 	s.processMatchResult(results)
 
 	return nil
 }
 
+// checkModify runs the checks of a replace: status, quantity above the filled
+// one, board, kill switch and the pre-trade risk chain on the order as it
+// would be after the replace, which it returns. The price of modifyOrder is
+// set to the one the replace applies.
+func (s *OMS) checkModify(order *model.Order, modifyOrder *model.ModifyOrder) (*model.Order, error) {
+	if order.QuoteID != "" {
+		return nil, errQuoteSide
+	}
+	if !order.CanModify() {
+		return nil, errInvalidOrderStatus
+	}
+
+	newQty := modifyOrder.NewQuantity.IntPart()
+	// a replace cannot take back what is already filled
+	if newQty <= 0 || newQty <= order.CumQuantity {
+		return nil, errInvalidQuantity
+	}
+	// the price of a pegged order is owned by the book
+	if order.PegType != "" {
+		modifyOrder.NewPrice = order.Price
+	}
+	// a replace cannot move the order to another board
+	board, err := s.resolveBoard(order.Symbol, newQty, order.Type, order.TimeInForce)
+	if err != nil {
+		return nil, err
+	}
+	if board != order.Board {
+		return nil, errInvalidBoardLot
+	}
+
+	if err := s.checkKillSwitch(order.Account, order.SessionID, order.Symbol); err != nil {
+		return nil, err
+	}
+	replaced := *order
+	replaced.Price = modifyOrder.NewPrice
+	// the filled part is spent, the checks see the leaves after the replace
+	replaced.LeavesQuantity = newQty - order.CumQuantity
+	replaced.Quantity = newQty
+	if err := s.risk.Check(&replaced); err != nil {
+		return nil, err
+	}
+	modifyOrder.NewPrice = replaced.Price
+
+	return &replaced, nil
+}
+
 func (s *OMS) processMatchResult(results []*orderbook.MatchResult) {
+	var symbol string
+	var board model.OrderBoard
 	for _, r := range results {
 		// log.Printf("Match: BUY[%s] <=> SELL[%s] @ %.2f Qty %d\n",
 		// 	r.OrderID, r.CounterOrderID, r.Price, r.Qty)
@@ -161,14 +506,16 @@ func (s *OMS) processMatchResult(results []*orderbook.MatchResult) {
 			continue
 		}
 
-		order.UpdateMatchResult(r)
-		bkOrder := *order
-		now := time.Now()
-		// ov, fnReset := model.NewOrderEventUsingPool(bkOrder, now)
-		// s.eventstore.AddEvent(ov)
-		// fnReset()
-		s.eventstore.AddEvent(model.NewOrderEvent(bkOrder, now))
-		s.orderGateway.OnOrderReport(context.Background(), bkOrder)
+		if err := order.UpdateMatchResult(r); err != nil {
+			log.Printf("match orderID=%s err=%v", r.OrderID, err)
+			continue
+		}
+		symbol, board = order.Symbol, order.Board
+		if board == model.OrderBoardMain {
+			s.tradeStats.addTrade(symbol, r.Price, r.Qty)
+		}
+		s.reportOrder(context.Background(), order)
+		s.onListOrderFilled(context.Background(), order, r.Qty)
 
 		counterOrder, err := s.GetOrderByOrderID(r.CounterOrderID)
 		if err != nil {
@@ -176,12 +523,18 @@ func (s *OMS) processMatchResult(results []*orderbook.MatchResult) {
 			continue
 		}
 
-		counterOrder.UpdateMatchResult(r)
-		bkCounterOrder := *counterOrder
-		// ovCounter, fnReset := model.NewOrderEventUsingPool(bkCounterOrder, now)
-		// s.eventstore.AddEvent(ovCounter)
-		// fnReset()
-		s.eventstore.AddEvent(model.NewOrderEvent(bkCounterOrder, now))
-		s.orderGateway.OnOrderReport(context.Background(), bkCounterOrder)
+		if err := counterOrder.UpdateMatchResult(r); err != nil {
+			log.Printf("match counterOrderID=%s err=%v", r.CounterOrderID, err)
+			continue
+		}
+		s.reportOrder(context.Background(), counterOrder)
+
+		s.onListOrderFilled(context.Background(), counterOrder, r.Qty)
+	}
+
+	// the last match is the furthest price reached by this sweep,
+	// odd-lot trades do not trigger stop orders
+	if len(results) > 0 && symbol != "" && board == model.OrderBoardMain {
+		s.triggerStopOrders(symbol, results[len(results)-1].Price)
 	}
 }
@@ -10,4 +10,15 @@ type IOMS interface {
 	AddOrder(ctx context.Context, addOrder *model.AddOrder) error
 	ModifyOrder(ctx context.Context, modifyOrder *model.ModifyOrder) error
 	CancelOrder(ctx context.Context, cancelOrder *model.CancelOrder) error
+	AddOrderList(ctx context.Context, addOrderList *model.AddOrderList) error
+	PutThrough(ctx context.Context, putThrough *model.PutThrough) error
+	Quote(ctx context.Context, quote *model.Quote) error
+	MassQuote(ctx context.Context, massQuote *model.MassQuote) []error
+	CancelQuotes(ctx context.Context, account, symbol string) error
+
+	// operator controls
+	EngageKillSwitch(ctx context.Context, killSwitch *model.KillSwitch) error
+	ReleaseKillSwitch(ctx context.Context, killSwitch *model.KillSwitch) error
+	KillSwitches(ctx context.Context) ([]model.KillSwitch, error)
+	ReloadConfig(ctx context.Context, operator string) (int64, error)
 }
@@ -0,0 +1,414 @@
+package oms
+
+import (
+	"context"
+	"log"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/orderbook"
+)
+
+// orderGroup links the orders of one contingent order list.
+//   - OCO: legIDs hold both orders, active from the start.
+//   - Bracket: entryID is the entry order, legIDs hold take-profit and
+//     stop-loss which are held until the entry is filled.
+//   - OTO: entryID is the triggering order, legIDs hold the triggered order
+//     which is held until the triggering one is filled.
+type orderGroup struct {
+	listID          string
+	contingencyType model.ContingencyType
+	entryID         string
+	legIDs          []string
+	active          bool
+}
+
+func (g *orderGroup) sibling(orderID string) string {
+	for _, id := range g.legIDs {
+		if id != orderID {
+			return id
+		}
+	}
+	return ""
+}
+
+// AddOrderList accepts or rejects the list as a whole, every order of a
+// refused list is reported Rejected with the reason of the first failure.
+func (s *OMS) AddOrderList(ctx context.Context, addOrderList *model.AddOrderList) error {
+	orders := make([]*model.Order, len(addOrderList.Orders))
+	for i, addOrder := range addOrderList.Orders {
+		order := &model.Order{}
+		order.UpdateAddOrder(addOrder)
+		order.ListID = addOrderList.ListID
+		orders[i] = order
+	}
+	if err := s.acceptOrderList(addOrderList, orders); err != nil {
+		for _, order := range orders {
+			s.rejectOrder(ctx, order, err)
+		}
+		return err
+	}
+	for _, order := range orders {
+		s.AddOrderToMap(order)
+	}
+
+	group := &orderGroup{
+		listID:          addOrderList.ListID,
+		contingencyType: addOrderList.ContingencyType,
+	}
+	live := orders
+	switch addOrderList.ContingencyType {
+	case model.ContingencyTypeOCO:
+		group.legIDs = []string{orders[0].OrderID, orders[1].OrderID}
+		group.active = true
+	case model.ContingencyTypeBracket:
+		group.entryID = orders[0].OrderID
+		group.legIDs = []string{orders[1].OrderID, orders[2].OrderID}
+		live = orders[:1]
+	case model.ContingencyTypeOTO:
+		group.entryID = orders[0].OrderID
+		group.legIDs = []string{orders[1].OrderID}
+		live = orders[:1]
+	}
+
+	s.groupMu.Lock()
+	for _, order := range orders {
+		s.orderGroups[order.OrderID] = group
+	}
+	s.groupMu.Unlock()
+
+	// bracket legs and the triggered order wait for the entry fill
+	for _, order := range orders[len(live):] {
+		if err := order.UpdateHold(); err != nil {
+			s.rejectOrder(ctx, order, err)
+			continue
+		}
+		s.reportOrder(ctx, order)
+	}
+	for _, order := range live {
+		s.submitOrder(ctx, order)
+	}
+
+	return nil
+}
+
+func (s *OMS) acceptOrderList(addOrderList *model.AddOrderList, orders []*model.Order) error {
+	if err := validateOrderList(addOrderList); err != nil {
+		return err
+	}
+	for _, addOrder := range addOrderList.Orders {
+		if s.isDuplicate(addOrder.GatewayID) {
+			return errDuplicateOrder
+		}
+		if err := s.validateAddOrder(addOrder); err != nil {
+			return err
+		}
+		board, err := s.resolveBoard(addOrder.Symbol, addOrder.Quantity.IntPart(), addOrder.Type, addOrder.TimeInForce)
+		if err != nil {
+			return err
+		}
+		// linked orders are main board only
+		if board != model.OrderBoardMain {
+			return errInvalidOrderList
+		}
+	}
+
+	// bracket legs close the entry position and are checked when it fills,
+	// so is the triggered order of an OTO
+	switch addOrderList.ContingencyType {
+	case model.ContingencyTypeBracket, model.ContingencyTypeOTO:
+		return s.checkLinkedOrders(orders[:1])
+	}
+	return s.checkLinkedOrders(orders)
+}
+
+// checkLinkedOrders runs the kill switches and pre-trade checks of the legs
+// of an OCO pair, or of a single order. Only one leg can execute, so legs on
+// one side hold the cash or stock of the largest leg once and the others
+// pass the risk chain only.
+func (s *OMS) checkLinkedOrders(orders []*model.Order) error {
+	held := s.largestLeg(orders)
+	for _, order := range orders {
+		if err := s.checkKillSwitch(order.Account, order.SessionID, order.Symbol); err != nil {
+			return err
+		}
+		if held == nil || order == held {
+			continue
+		}
+		if err := s.risk.Check(order); err != nil {
+			return err
+		}
+	}
+
+	if held != nil {
+		return s.preTrade(held)
+	}
+	for _, order := range orders {
+		if err := s.preTrade(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// largestLeg returns the leg with the largest cost, or quantity for sells,
+// nil when the legs are on both sides and each holds its own.
+func (s *OMS) largestLeg(orders []*model.Order) *model.Order {
+	var largest *model.Order
+	for _, order := range orders {
+		if largest == nil {
+			largest = order
+			continue
+		}
+		if order.Side != largest.Side {
+			return nil
+		}
+		larger := order.LeavesQuantity > largest.LeavesQuantity
+		if order.Side == model.OrderSideBuy {
+			larger = s.ledger.Required(order).GreaterThan(s.ledger.Required(largest))
+		}
+		if larger {
+			largest = order
+		}
+	}
+	return largest
+}
+
+func validateOrderList(addOrderList *model.AddOrderList) error {
+	orders := addOrderList.Orders
+	switch addOrderList.ContingencyType {
+	case model.ContingencyTypeOCO:
+		if len(orders) != 2 {
+			return errInvalidOrderList
+		}
+	case model.ContingencyTypeBracket:
+		if len(orders) != 3 {
+			return errInvalidOrderList
+		}
+		entry, takeProfit, stopLoss := orders[0], orders[1], orders[2]
+		if takeProfit.Side == entry.Side || stopLoss.Side == entry.Side ||
+			stopLoss.Type != model.OrderTypeStop {
+			return errInvalidOrderList
+		}
+	case model.ContingencyTypeOTO:
+		if len(orders) != 2 {
+			return errInvalidOrderList
+		}
+	default:
+		return errInvalidOrderList
+	}
+
+	for _, order := range orders[1:] {
+		if order.Symbol != orders[0].Symbol {
+			return errInvalidOrderList
+		}
+	}
+
+	return nil
+}
+
+func (s *OMS) getOrderGroup(orderID string) *orderGroup {
+	s.groupMu.Lock()
+	defer s.groupMu.Unlock()
+
+	return s.orderGroups[orderID]
+}
+
+func (s *OMS) deleteOrderGroup(orderID string) {
+	s.groupMu.Lock()
+	defer s.groupMu.Unlock()
+
+	delete(s.orderGroups, orderID)
+}
+
+// onListOrderFilled applies a fill of qty on a list order to the rest of its
+// list: a filled bracket entry or OTO triggering order activates its legs, a
+// fill on an OCO leg shrinks the sibling and a fully filled leg cancels it.
+func (s *OMS) onListOrderFilled(ctx context.Context, order *model.Order, qty int64) {
+	if order.ListID == "" {
+		return
+	}
+	group := s.getOrderGroup(order.OrderID)
+	if group == nil {
+		return
+	}
+
+	if order.OrderID == group.entryID {
+		if order.Status == model.OrderStatusFilled {
+			s.activateBracket(ctx, group, order.CumQuantity)
+		}
+		return
+	}
+
+	sibling, err := s.GetOrderByOrderID(group.sibling(order.OrderID))
+	if err != nil || sibling.IsEnd() {
+		return
+	}
+
+	if order.IsEnd() || sibling.LeavesQuantity <= qty {
+		s.cancelListOrder(ctx, sibling)
+		return
+	}
+	s.shrinkListOrder(ctx, sibling, sibling.Quantity-qty)
+}
+
+// onListOrderCanceled is called after a client cancel of a list order.
+func (s *OMS) onListOrderCanceled(ctx context.Context, order *model.Order) {
+	if order.ListID == "" {
+		return
+	}
+	group := s.getOrderGroup(order.OrderID)
+	if group == nil {
+		return
+	}
+
+	if order.OrderID == group.entryID {
+		// a partially filled entry still protects the filled quantity
+		if order.CumQuantity > 0 {
+			s.activateBracket(ctx, group, order.CumQuantity)
+			return
+		}
+		for _, id := range group.legIDs {
+			if leg, err := s.GetOrderByOrderID(id); err == nil && !leg.IsEnd() {
+				s.cancelListOrder(ctx, leg)
+			}
+		}
+		return
+	}
+
+	sibling, err := s.GetOrderByOrderID(group.sibling(order.OrderID))
+	if err == nil && !sibling.IsEnd() {
+		s.cancelListOrder(ctx, sibling)
+	}
+}
+
+func (s *OMS) activateBracket(ctx context.Context, group *orderGroup, qty int64) {
+	s.groupMu.Lock()
+	if group.active {
+		s.groupMu.Unlock()
+		return
+	}
+	group.active = true
+	s.groupMu.Unlock()
+
+	var legs, checked []*model.Order
+	for _, id := range group.legIDs {
+		leg, err := s.GetOrderByOrderID(id)
+		// only a leg still held can be activated
+		if err != nil || !model.CanTransition(leg.Status, model.OrderActionAccept) {
+			continue
+		}
+		legs = append(legs, leg)
+		// checked at the quantity it protects
+		c := *leg
+		c.Quantity, c.LeavesQuantity = qty, qty
+		checked = append(checked, &c)
+	}
+	if err := s.checkLinkedOrders(checked); err != nil {
+		for _, leg := range legs {
+			s.rejectOrder(ctx, leg, err)
+		}
+		return
+	}
+
+	for _, leg := range legs {
+		if leg.UpdateActivate(qty) != nil {
+			continue
+		}
+		s.submitOrder(ctx, leg)
+	}
+}
+
+func (s *OMS) shrinkListOrder(ctx context.Context, order *model.Order, qty int64) {
+	restated := *order
+	if err := restated.UpdateRestateQuantity(qty); err != nil {
+		log.Printf("restate orderID=%s err=%v", order.OrderID, err)
+		return
+	}
+	if onBook(order) {
+		if _, err := s.bookManager(order).ModifyOrder(order.Symbol, order.OrderID, order.Price, restated.LeavesQuantity); err != nil {
+			log.Printf("restate orderID=%s err=%v", order.OrderID, err)
+			return
+		}
+	}
+	*order = restated
+	s.reportOrder(ctx, order)
+}
+
+// cancelListOrder cancels an order without a client request, the order keeps
+// its own ClOrdID.
+func (s *OMS) cancelListOrder(ctx context.Context, order *model.Order) {
+	if !order.CanCancel() {
+		return
+	}
+	if onBook(order) {
+		if err := s.bookManager(order).CancelOrder(order.Symbol, order.OrderID); err != nil {
+			log.Printf("cancel orderID=%s err=%v", order.OrderID, err)
+			return
+		}
+	}
+	s.removeStopOrder(order)
+	if err := order.UpdateCancelOrder(&model.CancelOrder{
+		GatewayID:     order.GatewayID,
+		OrigGatewayID: order.OrigGatewayID,
+	}); err != nil {
+		log.Printf("cancel orderID=%s err=%v", order.OrderID, err)
+		return
+	}
+	s.reportOrder(ctx, order)
+}
+
+func (s *OMS) addStopOrder(order *model.Order) {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	s.stopOrders[order.Symbol] = append(s.stopOrders[order.Symbol], order)
+}
+
+func (s *OMS) removeStopOrder(order *model.Order) {
+	if order.Type != model.OrderTypeStop {
+		return
+	}
+
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	orders := s.stopOrders[order.Symbol]
+	for i := range orders {
+		if orders[i].OrderID == order.OrderID {
+			s.stopOrders[order.Symbol] = append(orders[:i], orders[i+1:]...)
+			return
+		}
+	}
+}
+
+// triggerStopOrders sends every stop order of symbol whose stop price was
+// reached by lastPrice to the book as an immediate-or-cancel market order,
+// what it does not fill is canceled.
+func (s *OMS) triggerStopOrders(symbol string, lastPrice float64) {
+	s.stopMu.Lock()
+	var triggered, waiting []*model.Order
+	for _, order := range s.stopOrders[symbol] {
+		stopPrice := order.StopPrice.InexactFloat64()
+		if (order.Side == model.OrderSideBuy && lastPrice >= stopPrice) ||
+			(order.Side == model.OrderSideSell && lastPrice <= stopPrice) {
+			triggered = append(triggered, order)
+			continue
+		}
+		waiting = append(waiting, order)
+	}
+	s.stopOrders[symbol] = waiting
+	s.stopMu.Unlock()
+
+	for _, order := range triggered {
+		results := s.orderbookManager.AddOrder(&orderbook.Order{
+			ID:          order.OrderID,
+			Symbol:      order.Symbol,
+			Side:        orderbook.Side(order.Side),
+			Qty:         order.LeavesQuantity,
+			Type:        orderbook.MARKET,
+			TimeInForce: orderbook.IOC,
+		})
+		s.processMatchResult(results)
+		s.cancelRemainder(context.Background(), order)
+	}
+}
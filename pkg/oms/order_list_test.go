@@ -0,0 +1,310 @@
+package oms
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
+	"github.com/shopspring/decimal"
+)
+
+type mockOrderGateway struct {
+	mu            sync.Mutex
+	reports       []model.Order
+	cancelRejects []model.CancelReject
+}
+
+func (g *mockOrderGateway) Start(ctx context.Context) error {
+	return nil
+}
+
+func (g *mockOrderGateway) OnOrderReport(ctx context.Context, args ...interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch report := args[0].(type) {
+	case model.Order:
+		g.reports = append(g.reports, report)
+	case model.CancelReject:
+		g.cancelRejects = append(g.cancelRejects, report)
+	}
+}
+
+func (g *mockOrderGateway) lastReport(gatewayID string) *model.Order {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i := len(g.reports) - 1; i >= 0; i-- {
+		if g.reports[i].GatewayID == gatewayID {
+			return &g.reports[i]
+		}
+	}
+	return nil
+}
+
+func newAddOrder(gatewayID string, side model.OrderSide, orderType model.OrderType, price, qty int64) *model.AddOrder {
+	addOrder := &model.AddOrder{
+		GatewayID: gatewayID,
+		Account:   "ACC-" + gatewayID,
+		Symbol:    "TEST",
+		Type:      orderType,
+		Side:      side,
+		Price:     decimal.NewFromInt(price),
+		Quantity:  decimal.NewFromInt(qty),
+	}
+	if orderType == model.OrderTypeStop {
+		addOrder.Price = decimal.Zero
+		addOrder.StopPrice = decimal.NewFromInt(price)
+	}
+	return addOrder
+}
+
+func TestOCOPartialFillShrinksSibling(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	err := s.AddOrderList(ctx, &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeOCO,
+		Orders: []*model.AddOrder{
+			newAddOrder("TP", model.OrderSideSell, model.OrderTypeLimit, 110, 10),
+			newAddOrder("SL", model.OrderSideSell, model.OrderTypeStop, 90, 10),
+		},
+	})
+	if err != nil {
+		t.Fatalf("add order list err=%v", err)
+	}
+
+	s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 110, 4))
+	if r := gw.lastReport("SL"); r.ExecType != model.ExecTypeRestated || r.Quantity != 6 || r.LeavesQuantity != 6 {
+		t.Fatalf("expected SL restated to 6, got %+v", r)
+	}
+
+	s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 110, 6))
+	if r := gw.lastReport("TP"); r.Status != model.OrderStatusFilled {
+		t.Fatalf("expected TP filled, got %s", r.Status)
+	}
+	if r := gw.lastReport("SL"); r.Status != model.OrderStatusCanceled {
+		t.Fatalf("expected SL canceled, got %s", r.Status)
+	}
+}
+
+func TestBracketActivatesLegsOnEntryFill(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	err := s.AddOrderList(ctx, &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeBracket,
+		Orders: []*model.AddOrder{
+			newAddOrder("ENTRY", model.OrderSideBuy, model.OrderTypeLimit, 100, 10),
+			newAddOrder("TP", model.OrderSideSell, model.OrderTypeLimit, 110, 10),
+			newAddOrder("SL", model.OrderSideSell, model.OrderTypeStop, 90, 10),
+		},
+	})
+	if err != nil {
+		t.Fatalf("add order list err=%v", err)
+	}
+	if r := gw.lastReport("TP"); r.Status != model.OrderStatusPendingNew {
+		t.Fatalf("expected TP held, got %s", r.Status)
+	}
+
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 10))
+	if r := gw.lastReport("TP"); r.Status != model.OrderStatusNew || r.Quantity != 10 {
+		t.Fatalf("expected TP active, got %+v", r)
+	}
+
+	// a trade at 90 triggers the stop-loss which sells into the 89 bid
+	s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 89, 10))
+	s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 90, 1))
+	s.AddOrder(ctx, newAddOrder("S2", model.OrderSideSell, model.OrderTypeLimit, 90, 1))
+
+	if r := gw.lastReport("SL"); r.Status != model.OrderStatusFilled {
+		t.Fatalf("expected SL filled, got %s", r.Status)
+	}
+	if r := gw.lastReport("TP"); r.Status != model.OrderStatusCanceled {
+		t.Fatalf("expected TP canceled, got %s", r.Status)
+	}
+}
+
+func TestBracketEntryCancelCancelsLegs(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	s.AddOrderList(ctx, &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeBracket,
+		Orders: []*model.AddOrder{
+			newAddOrder("ENTRY", model.OrderSideBuy, model.OrderTypeLimit, 100, 10),
+			newAddOrder("TP", model.OrderSideSell, model.OrderTypeLimit, 110, 10),
+			newAddOrder("SL", model.OrderSideSell, model.OrderTypeStop, 90, 10),
+		},
+	})
+
+	s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "ENTRY-C", OrigGatewayID: "ENTRY"})
+	for _, id := range []string{"TP", "SL"} {
+		if r := gw.lastReport(id); r.Status != model.OrderStatusCanceled {
+			t.Fatalf("expected %s canceled, got %s", id, r.Status)
+		}
+	}
+}
+
+// newListOrder is newAddOrder on the account of the whole list.
+func newListOrder(gatewayID string, side model.OrderSide, orderType model.OrderType, price, qty int64) *model.AddOrder {
+	addOrder := newAddOrder(gatewayID, side, orderType, price, qty)
+	addOrder.Account = "ACC-L"
+	return addOrder
+}
+
+func TestOCOLocksLargestLegOnce(t *testing.T) {
+	ctx := context.Background()
+	positions, _ := position.NewPositions(ctx, nil, nil)
+	positions.Adjust("ACC-L", "TEST", 100)
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Positions: positions})
+	defer s.Stop()
+
+	// an exit of the whole holding on both legs
+	err := s.AddOrderList(ctx, &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeOCO,
+		Orders: []*model.AddOrder{
+			newListOrder("TP", model.OrderSideSell, model.OrderTypeLimit, 110, 60),
+			newListOrder("SL", model.OrderSideSell, model.OrderTypeStop, 90, 100),
+		},
+	})
+	if err != nil {
+		t.Fatalf("add order list err=%v", err)
+	}
+	if pos := positions.Position("ACC-L", "TEST"); pos.Locked != 100 {
+		t.Fatalf("expected the stop-loss quantity locked once, got %+v", pos)
+	}
+
+	s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "TP-C", OrigGatewayID: "TP"})
+	if r := gw.lastReport("SL"); r.Status != model.OrderStatusCanceled {
+		t.Fatalf("expected SL canceled, got %s", r.Status)
+	}
+	if pos := positions.Position("ACC-L", "TEST"); pos.Locked != 0 {
+		t.Fatalf("expected the lock released, got %+v", pos)
+	}
+}
+
+func TestBracketLegsCheckedOnActivation(t *testing.T) {
+	ctx := context.Background()
+	positions, _ := position.NewPositions(ctx, nil, nil)
+	positions.Adjust("ACC-S1", "TEST", 10)
+	positions.Adjust("ACC-S2", "TEST", 10)
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Positions: positions})
+	defer s.Stop()
+	bracket := func(listID, prefix string) *model.AddOrderList {
+		return &model.AddOrderList{
+			ListID:          listID,
+			ContingencyType: model.ContingencyTypeBracket,
+			Orders: []*model.AddOrder{
+				newListOrder(prefix+"ENTRY", model.OrderSideBuy, model.OrderTypeLimit, 100, 10),
+				newListOrder(prefix+"TP", model.OrderSideSell, model.OrderTypeLimit, 110, 10),
+				newListOrder(prefix+"SL", model.OrderSideSell, model.OrderTypeStop, 90, 10),
+			},
+		}
+	}
+
+	// the legs lock the bought quantity once
+	if err := s.AddOrderList(ctx, bracket("L1", "A-")); err != nil {
+		t.Fatalf("add order list err=%v", err)
+	}
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 10))
+	if r := gw.lastReport("A-TP"); r.Status != model.OrderStatusNew {
+		t.Fatalf("expected TP active, got %+v", r)
+	}
+	if pos := positions.Position("ACC-L", "TEST"); pos.Quantity != 10 || pos.Locked != 10 {
+		t.Fatalf("expected the legs to lock 10 once, got %+v", pos)
+	}
+
+	// a kill switch engaged while the entry rests stops its legs
+	if err := s.AddOrderList(ctx, bracket("L2", "B-")); err != nil {
+		t.Fatalf("add order list err=%v", err)
+	}
+	s.EngageKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeAccount, Value: "ACC-L"})
+	s.AddOrder(ctx, newAddOrder("S2", model.OrderSideSell, model.OrderTypeLimit, 100, 10))
+	if r := gw.lastReport("B-ENTRY"); r.Status != model.OrderStatusFilled {
+		t.Fatalf("expected entry filled, got %s", r.Status)
+	}
+	for _, id := range []string{"B-TP", "B-SL"} {
+		if r := gw.lastReport(id); r.Status != model.OrderStatusRejected || r.RejectReason != model.RejectReasonBrokerOption {
+			t.Fatalf("expected %s rejected by the kill switch, got %+v", id, r)
+		}
+	}
+	if pos := positions.Position("ACC-L", "TEST"); pos.Quantity != 20 || pos.Locked != 10 {
+		t.Fatalf("expected rejected legs to lock nothing, got %+v", pos)
+	}
+}
+
+func TestTriggeredStopCancelsRemainder(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	s.AddOrder(ctx, newAddOrder("STOP", model.OrderSideBuy, model.OrderTypeStop, 100, 50))
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 10))
+	// a trade at 100 triggers the stop which buys the 5 left of S1
+	s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 5))
+
+	r := gw.lastReport("STOP")
+	if r.Status != model.OrderStatusCanceled || r.ExecType != model.ExecTypeCanceled ||
+		r.CumQuantity != 5 || r.LeavesQuantity != 0 {
+		t.Fatalf("expected STOP remainder canceled after 5 filled, got %+v", r)
+	}
+	if len(s.stopOrders["TEST"]) != 0 {
+		t.Fatal("expected no stop order parked")
+	}
+}
+
+func TestOTOActivatesSecondOrderOnFill(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	list := &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeOTO,
+		Orders: []*model.AddOrder{
+			newAddOrder("FIRST", model.OrderSideBuy, model.OrderTypeLimit, 100, 10),
+			newAddOrder("SECOND", model.OrderSideSell, model.OrderTypeLimit, 110, 10),
+		},
+	}
+	if err := s.AddOrderList(ctx, list); err != nil {
+		t.Fatalf("add order list err=%v", err)
+	}
+	for _, addOrder := range list.Orders {
+		if addOrder.ListID != "" {
+			t.Fatalf("expected the request left as sent, got ListID %q", addOrder.ListID)
+		}
+	}
+	if r := gw.lastReport("SECOND"); r.Status != model.OrderStatusPendingNew || r.ListID != "L1" {
+		t.Fatalf("expected SECOND held in L1, got %+v", r)
+	}
+
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 10))
+	if r := gw.lastReport("SECOND"); r.Status != model.OrderStatusNew || r.Quantity != 10 {
+		t.Fatalf("expected SECOND active, got %+v", r)
+	}
+
+	// an OTO links two orders only
+	list.ListID = "L2"
+	list.Orders = append(list.Orders, newAddOrder("THIRD", model.OrderSideSell, model.OrderTypeLimit, 120, 10))
+	list.Orders[0].GatewayID, list.Orders[1].GatewayID = "FIRST-2", "SECOND-2"
+	if err := s.AddOrderList(ctx, list); err != errInvalidOrderList {
+		t.Fatalf("expected invalid order list, got %v", err)
+	}
+}
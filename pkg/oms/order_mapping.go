@@ -53,6 +53,7 @@ func (s *OMS) cleanup() {
 		if order.IsEnd() {
 			s.DeleteOrderByOrderID(order.OrderID)
 			s.eventstore.DeleteChainByOrderID(order.OrderID)
+			s.deleteOrderGroup(order.OrderID)
 		}
 		return true
 	})
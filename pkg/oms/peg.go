@@ -0,0 +1,53 @@
+package oms
+
+import (
+	"context"
+	"log"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+// bookPrice moves the peg prices of the book onto the tick and into the
+// ceil/floor band of the instrument, so the book and the OMS agree on them.
+func (s *OMS) bookPrice(symbol string, side orderbook.Side, price decimal.Decimal) (decimal.Decimal, bool) {
+	return s.risk.BookPrice(symbol, model.OrderSide(side), price)
+}
+
+// pegPrice returns the entry price of a pegged order from the current book,
+// the book keeps it as the price of the order.
+func (s *OMS) pegPrice(addOrder *model.AddOrder, board model.OrderBoard) (decimal.Decimal, error) {
+	if addOrder.Type != model.OrderTypeLimit || board != model.OrderBoardMain {
+		return decimal.Zero, errInvalidPegOrder
+	}
+
+	price, ok := s.orderbookManager.PegPrice(
+		addOrder.Symbol,
+		orderbook.Side(addOrder.Side),
+		orderbook.PegType(addOrder.PegType),
+		addOrder.PegOffset,
+	)
+	if !ok {
+		return decimal.Zero, errNoPegReference
+	}
+
+	return price, nil
+}
+
+// onRestated reports pegged orders repriced by the book.
+func (s *OMS) onRestated(restatements []*orderbook.Restatement) {
+	for _, r := range restatements {
+		order, err := s.GetOrderByOrderID(r.OrderID)
+		if err != nil {
+			log.Printf("restate orderID=%s not found", r.OrderID)
+			continue
+		}
+
+		if err := order.UpdateRestatePrice(r.Price); err != nil {
+			log.Printf("restate orderID=%s err=%v", r.OrderID, err)
+			continue
+		}
+		s.reportOrder(context.Background(), order)
+	}
+}
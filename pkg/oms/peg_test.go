@@ -0,0 +1,102 @@
+package oms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func TestPeggedOrderRestated(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	peg := newAddOrder("P1", model.OrderSideBuy, model.OrderTypeLimit, 0, 10)
+	peg.PegType = model.OrderPegTypePrimary
+	if err := s.AddOrder(ctx, peg); err != errNoPegReference {
+		t.Fatalf("expected no reference error, got %v", err)
+	}
+
+	s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10))
+	if err := s.AddOrder(ctx, peg); err != nil {
+		t.Fatalf("add pegged order err=%v", err)
+	}
+	if r := gw.lastReport("P1"); r.Status != model.OrderStatusNew || !r.Price.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("expected peg new at 100, got %+v", r)
+	}
+
+	s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 102, 10))
+	if r := gw.lastReport("P1"); r.ExecType != model.ExecTypeRestated || !r.Price.Equal(decimal.NewFromInt(102)) {
+		t.Fatalf("expected peg restated to 102, got %+v", r)
+	}
+
+	// the book owns the peg price, the request of the caller is untouched
+	req := &model.ModifyOrder{
+		GatewayID:     "P1-R",
+		OrigGatewayID: "P1",
+		NewPrice:      decimal.NewFromInt(50),
+		NewQuantity:   decimal.NewFromInt(5),
+	}
+	if err := s.ModifyOrder(ctx, req); err != nil {
+		t.Fatalf("modify err=%v", err)
+	}
+	if r := gw.lastReport("P1-R"); r.ExecType != model.ExecTypeReplaced || !r.Price.Equal(decimal.NewFromInt(102)) {
+		t.Fatalf("expected peg replaced at 102, got %+v", r)
+	}
+	if !req.NewPrice.Equal(decimal.NewFromInt(50)) {
+		t.Fatalf("expected the request left as sent, got %s", req.NewPrice)
+	}
+}
+
+func TestPegPriceOnTickInsideBand(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "risk.yaml", testRiskConfig)
+	writeConfig(t, dir, "tick_size.json", `{"exchanges": {"HOSE": {"default": [{"maxPrice": 0, "step": 5}]}}}`)
+	writeConfig(t, dir, "market_data.json", `[{"symbol": "TEST", "exchange": "HOSE", "ceil": 120, "ref": 100, "floor": 80}]`)
+
+	s, gw := newReloadOMS(t, dir)
+	defer s.Stop()
+	ctx := context.Background()
+	if _, err := s.ReloadConfig(ctx, "ops"); err != nil {
+		t.Fatalf("reload err=%v", err)
+	}
+
+	s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10))
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 115, 10))
+
+	// the midpoint 107.5 is rounded down to the tick for a buy
+	mid := newAddOrder("P1", model.OrderSideBuy, model.OrderTypeLimit, 0, 10)
+	mid.PegType = model.OrderPegTypeMidpoint
+	if err := s.AddOrder(ctx, mid); err != nil {
+		t.Fatalf("add midpoint peg err=%v", err)
+	}
+	if r := gw.lastReport("P1"); !r.Price.Equal(decimal.NewFromInt(105)) {
+		t.Fatalf("expected midpoint peg at 105, got %s", r.Price)
+	}
+
+	// ask + 10 is above the ceil
+	primary := newAddOrder("P2", model.OrderSideSell, model.OrderTypeLimit, 0, 10)
+	primary.PegType = model.OrderPegTypePrimary
+	primary.PegOffset = decimal.NewFromInt(10)
+	if err := s.AddOrder(ctx, primary); err != nil {
+		t.Fatalf("add primary peg err=%v", err)
+	}
+	if r := gw.lastReport("P2"); !r.Price.Equal(decimal.NewFromInt(120)) {
+		t.Fatalf("expected primary peg clamped to 120, got %s", r.Price)
+	}
+
+	// the midpoint 112.5 is restated on the tick as well
+	s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 110, 10))
+	if r := gw.lastReport("P1"); r.ExecType != model.ExecTypeRestated || !r.Price.Equal(decimal.NewFromInt(110)) {
+		t.Fatalf("expected midpoint peg restated to 110, got %+v", r)
+	}
+
+	// the book holds the prices the OMS reported
+	_, asks := s.orderbookManager.Depth("TEST", 5)
+	if len(asks) != 2 || asks[1].Price != 120 {
+		t.Fatalf("expected the primary peg at 120 in the book, got %+v", asks)
+	}
+}
@@ -0,0 +1,117 @@
+package oms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+// execTypes returns the exec types reported for the order, in order.
+func (g *mockOrderGateway) execTypes(orderID string) []model.OrderExecType {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var execTypes []model.OrderExecType
+	for _, r := range g.reports {
+		if r.OrderID == orderID {
+			execTypes = append(execTypes, r.ExecType)
+		}
+	}
+	return execTypes
+}
+
+func expectExecTypes(t *testing.T, got []model.OrderExecType, want ...model.OrderExecType) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestPendingAcksBeforeBookResult(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{PendingAcks: true})
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 100)); err != nil {
+		t.Fatal(err)
+	}
+	orderID := gw.lastReport("B1").OrderID
+	expectExecTypes(t, gw.execTypes(orderID), model.ExecTypePendingNew, model.ExecTypeNew)
+
+	if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B1-R",
+		OrigGatewayID: "B1",
+		NewPrice:      decimal.NewFromInt(101),
+		NewQuantity:   decimal.NewFromInt(200),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pending := gw.reports[len(gw.reports)-2]
+	if pending.Status != model.OrderStatusPendingReplace || pending.GatewayID != "B1-R" || pending.OrigGatewayID != "B1" ||
+		!pending.Price.Equal(decimal.NewFromInt(100)) || pending.Quantity != 100 {
+		t.Fatalf("expected PendingReplace of the old order, got %+v", pending)
+	}
+	if r := gw.lastReport("B1-R"); r.ExecType != model.ExecTypeReplaced || r.Status != model.OrderStatusNew || !r.Price.Equal(decimal.NewFromInt(101)) || r.Quantity != 200 {
+		t.Fatalf("expected Replaced, got %+v", r)
+	}
+
+	if err := s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "B1-C", OrigGatewayID: "B1-R"}); err != nil {
+		t.Fatal(err)
+	}
+	pending = gw.reports[len(gw.reports)-2]
+	if pending.Status != model.OrderStatusPendingCancel || pending.GatewayID != "B1-C" || pending.LeavesQuantity != 200 {
+		t.Fatalf("expected PendingCancel with leaves 200, got %+v", pending)
+	}
+	if r := gw.lastReport("B1-C"); r.Status != model.OrderStatusCanceled || r.LeavesQuantity != 0 {
+		t.Fatalf("expected Canceled, got %+v", r)
+	}
+
+	expectExecTypes(t, gw.execTypes(orderID),
+		model.ExecTypePendingNew, model.ExecTypeNew,
+		model.ExecTypePendingReplace, model.ExecTypeReplaced,
+		model.ExecTypePendingCancel, model.ExecTypeCanceled)
+
+	// one event per report, numbered in the order of the reports
+	seq := int64(0)
+	for _, r := range gw.reports {
+		if r.OrderID == orderID {
+			if seq++; r.Seq != seq {
+				t.Fatalf("expected seq %d, got %d", seq, r.Seq)
+			}
+		}
+	}
+}
+
+func TestPendingNewBeforeFill(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{PendingAcks: true})
+	defer s.Stop()
+	ctx := context.Background()
+
+	_ = s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 100))
+	_ = s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 100))
+
+	orderID := gw.lastReport("B1").OrderID
+	expectExecTypes(t, gw.execTypes(orderID), model.ExecTypePendingNew, model.ExecTypeNew, model.ExecTypeTrade)
+}
+
+func TestNoPendingAcksByDefault(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	_ = s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 100))
+	_ = s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "B1-C", OrigGatewayID: "B1"})
+
+	orderID := gw.lastReport("B1-C").OrderID
+	expectExecTypes(t, gw.execTypes(orderID), model.ExecTypeNew, model.ExecTypeCanceled)
+}
@@ -0,0 +1,13 @@
+package position
+
+import "github.com/joripage/orderbook-dev/pkg/misc"
+
+// LoadConfig reads a positions config from a YAML file (see misc.LoadYAML).
+func LoadConfig(path string) (*PositionsConfig, error) {
+	cfg := &PositionsConfig{}
+	if err := misc.LoadYAML(path, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
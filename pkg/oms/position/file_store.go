@@ -0,0 +1,63 @@
+package position
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/misc"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+// FileStore keeps holdings in memory and their quantity in a JSON file, an
+// array of model.AccountPosition. Every quantity change rewrites the file, so
+// only one process may use it: engine shards share a database store instead
+// (see repo.AccountPositionSQLRepo).
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// NewFileStore loads the holdings of path, a missing file has no holdings.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		path:        path,
+	}
+
+	var positions []*model.AccountPosition
+	if err := misc.ReadJSONFile(path, &positions); err != nil {
+		return nil, err
+	}
+	for _, p := range positions {
+		s.add(p.Account, p.Symbol, p.Quantity, 0)
+	}
+	return s, nil
+}
+
+func (s *FileStore) Add(ctx context.Context, account, symbol string, quantity, locked int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(account, symbol, quantity, locked)
+	if quantity == 0 {
+		return nil
+	}
+
+	positions := make([]*model.AccountPosition, 0, len(s.holdings))
+	for _, h := range s.holdings {
+		positions = append(positions, &model.AccountPosition{
+			Account:   h.Account,
+			Symbol:    h.Symbol,
+			Quantity:  h.Quantity,
+			UpdatedAt: time.Now(),
+		})
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Account != positions[j].Account {
+			return positions[i].Account < positions[j].Account
+		}
+		return positions[i].Symbol < positions[j].Symbol
+	})
+	return misc.WriteJSONFile(s.path, positions)
+}
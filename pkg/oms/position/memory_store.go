@@ -0,0 +1,64 @@
+package position
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps holdings in memory, for a single process.
+type MemoryStore struct {
+	mu       sync.Mutex
+	holdings map[string]*Position // account|symbol -> holding
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		holdings: make(map[string]*Position),
+	}
+}
+
+func (s *MemoryStore) Position(ctx context.Context, account, symbol string) (Position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h, ok := s.holdings[key(account, symbol)]; ok {
+		return *h, nil
+	}
+	return Position{Account: account, Symbol: symbol}, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, account, symbol string, qty int64, short bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var available int64
+	if h, ok := s.holdings[key(account, symbol)]; ok {
+		available = h.Available
+	}
+	if !short && qty > available {
+		return ErrInsufficientHoldings
+	}
+	s.add(account, symbol, 0, qty)
+	return nil
+}
+
+func (s *MemoryStore) Add(ctx context.Context, account, symbol string, quantity, locked int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(account, symbol, quantity, locked)
+	return nil
+}
+
+func (s *MemoryStore) add(account, symbol string, quantity, locked int64) *Position {
+	k := key(account, symbol)
+	h, ok := s.holdings[k]
+	if !ok {
+		h = &Position{Account: account, Symbol: symbol}
+		s.holdings[k] = h
+	}
+	h.Quantity += quantity
+	h.Locked += locked
+	h.Available = h.Quantity - h.Locked
+	return h
+}
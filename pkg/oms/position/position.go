@@ -0,0 +1,214 @@
+package position
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+var (
+	ErrInsufficientHoldings = errors.New("insufficient holdings")
+	ErrShortSellNotAllowed  = errors.New("short sell not allowed")
+)
+
+// Store holds the holdings of every account. It is the one place holdings
+// live: the engine shards of a deployment share it, every change adds to one
+// holding and a lock is checked against what every shard locked.
+type Store interface {
+	// Position returns the quantity and locked total of account in symbol.
+	Position(ctx context.Context, account, symbol string) (Position, error)
+	// Lock adds qty to the locked total of account in symbol, it fails with
+	// ErrInsufficientHoldings when fewer are available unless short is set.
+	Lock(ctx context.Context, account, symbol string, qty int64, short bool) error
+	// Add adds quantity and locked to the totals of account in symbol.
+	Add(ctx context.Context, account, symbol string, quantity, locked int64) error
+}
+
+// Positions tracks the holdings of every account and symbol. A sell order
+// locks its quantity at entry, the lock follows the leaves quantity of the
+// order and is released when the order ends. Fills add bought and remove
+// sold quantity. Holdings live in the store, Positions keeps the lock of each
+// of its orders.
+type Positions struct {
+	store Store
+
+	// accounts approved to sell beyond their holdings
+	shortSellAccounts map[string]struct{}
+
+	mu    sync.Mutex
+	locks map[string]*lock // orderID -> lock
+}
+
+type PositionsConfig struct {
+	// PositionFile seeds holdings from a JSON file (see NewFileStore).
+	PositionFile string `yaml:"position_file"`
+	// ShortSellAccounts may send short sell orders (FIX Side=5).
+	ShortSellAccounts []string `yaml:"short_sell_accounts"`
+}
+
+type lock struct {
+	account  string
+	symbol   string
+	quantity int64
+}
+
+// Position is a snapshot of one holding.
+type Position struct {
+	Account   string
+	Symbol    string
+	Quantity  int64
+	Locked    int64
+	Available int64 // Quantity - Locked
+}
+
+// NewPositions keeps holdings in store, a nil store keeps them in memory.
+func NewPositions(ctx context.Context, cfg *PositionsConfig, store Store) (*Positions, error) {
+	if cfg == nil {
+		cfg = &PositionsConfig{}
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	p := &Positions{
+		store:             store,
+		shortSellAccounts: make(map[string]struct{}, len(cfg.ShortSellAccounts)),
+		locks:             make(map[string]*lock),
+	}
+	for _, account := range cfg.ShortSellAccounts {
+		p.shortSellAccounts[account] = struct{}{}
+	}
+
+	return p, nil
+}
+
+func key(account, symbol string) string {
+	return account + "|" + symbol
+}
+
+// Position returns the holding of account in symbol.
+func (p *Positions) Position(account, symbol string) Position {
+	pos, err := p.store.Position(context.Background(), account, symbol)
+	if err != nil {
+		log.Printf("load position account=%s symbol=%s err=%v", account, symbol, err)
+		return Position{Account: account, Symbol: symbol}
+	}
+	return pos
+}
+
+// Available returns the quantity account can still sell in symbol.
+func (p *Positions) Available(account, symbol string) int64 {
+	return p.Position(account, symbol).Available
+}
+
+// CanShortSell reports whether account is approved for short selling.
+func (p *Positions) CanShortSell(account string) bool {
+	_, ok := p.shortSellAccounts[account]
+	return ok
+}
+
+// Adjust adds qty to the holding of account in symbol, e.g. a settlement or
+// a transfer in, a negative qty removes it.
+func (p *Positions) Adjust(account, symbol string, qty int64) {
+	p.add(account, symbol, qty, 0)
+}
+
+// Required returns the quantity order must lock beyond what it already does.
+func (p *Positions) Required(order *model.Order) int64 {
+	if p == nil || order.Side != model.OrderSideSell {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	required := order.LeavesQuantity
+	if l, ok := p.locks[order.OrderID]; ok {
+		required -= l.quantity
+	}
+	return required
+}
+
+// Lock holds the quantity of a new sell order. It fails when the holding is
+// too small, unless order is a short sell of an approved account.
+func (p *Positions) Lock(order *model.Order) error {
+	if p == nil || order.Side != model.OrderSideSell {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	short := order.ShortSell && p.CanShortSell(order.Account)
+	err := p.store.Lock(context.Background(), order.Account, order.Symbol, order.LeavesQuantity, short)
+	if errors.Is(err, ErrInsufficientHoldings) && order.ShortSell {
+		return ErrShortSellNotAllowed
+	}
+	if err != nil {
+		return err
+	}
+
+	p.locks[order.OrderID] = &lock{
+		account:  order.Account,
+		symbol:   order.Symbol,
+		quantity: order.LeavesQuantity,
+	}
+	return nil
+}
+
+// Release frees what is left of the lock of orderID.
+func (p *Positions) Release(orderID string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if l, ok := p.locks[orderID]; ok {
+		delete(p.locks, orderID)
+		p.add(l.account, l.symbol, 0, -l.quantity)
+	}
+}
+
+// Apply updates the holdings with a report of order: a trade moves the
+// quantity, the lock of a sell order is resized to its leaves quantity and
+// released once the order ends.
+func (p *Positions) Apply(order model.Order) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var quantity, locked int64
+	if order.ExecType == model.ExecTypeTrade && order.LastQuantity > 0 {
+		quantity = order.LastQuantity
+		if order.Side == model.OrderSideSell {
+			quantity = -quantity
+		}
+	}
+
+	if l, ok := p.locks[order.OrderID]; ok {
+		if order.IsEnd() || order.LeavesQuantity <= 0 {
+			delete(p.locks, order.OrderID)
+			locked = -l.quantity
+		} else {
+			locked = order.LeavesQuantity - l.quantity
+			l.quantity = order.LeavesQuantity
+		}
+	}
+
+	if quantity != 0 || locked != 0 {
+		p.add(order.Account, order.Symbol, quantity, locked)
+	}
+}
+
+func (p *Positions) add(account, symbol string, quantity, locked int64) {
+	if err := p.store.Add(context.Background(), account, symbol, quantity, locked); err != nil {
+		log.Printf("update position account=%s symbol=%s quantity=%d locked=%d err=%v", account, symbol, quantity, locked, err)
+	}
+}
@@ -0,0 +1,103 @@
+package position
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func newSell(orderID, account string, qty int64) *model.Order {
+	return &model.Order{
+		OrderID:        orderID,
+		Account:        account,
+		Symbol:         "TEST",
+		Side:           model.OrderSideSell,
+		Type:           model.OrderTypeLimit,
+		Price:          decimal.NewFromInt(10),
+		Quantity:       qty,
+		LeavesQuantity: qty,
+	}
+}
+
+func TestPositionsLockFollowsFillsAndCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "positions.json")
+	store, _ := NewFileStore(path)
+	p, _ := NewPositions(context.Background(), nil, store)
+	p.Adjust("A1", "TEST", 100)
+
+	order := newSell("O1", "A1", 80)
+	if err := p.Lock(order); err != nil {
+		t.Fatalf("lock err=%v", err)
+	}
+	if err := p.Lock(newSell("O2", "A1", 30)); err != ErrInsufficientHoldings {
+		t.Fatalf("expected ErrInsufficientHoldings, got %v", err)
+	}
+
+	order.ExecType = model.ExecTypeTrade
+	order.Status = model.OrderStatusPartiallyFilled
+	order.LastQuantity, order.LeavesQuantity = 50, 30
+	p.Apply(*order)
+	if pos := p.Position("A1", "TEST"); pos.Quantity != 50 || pos.Locked != 30 || pos.Available != 20 {
+		t.Fatalf("unexpected position after fill %+v", pos)
+	}
+
+	order.ExecType = model.ExecTypeCanceled
+	order.Status = model.OrderStatusCanceled
+	p.Apply(*order)
+	if pos := p.Position("A1", "TEST"); pos.Locked != 0 || pos.Available != 50 {
+		t.Fatalf("unexpected position after cancel %+v", pos)
+	}
+
+	reloaded, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reload err=%v", err)
+	}
+	restarted, err := NewPositions(context.Background(), nil, reloaded)
+	if err != nil {
+		t.Fatalf("reload err=%v", err)
+	}
+	if pos := restarted.Position("A1", "TEST"); pos.Quantity != 50 {
+		t.Fatalf("unexpected position after restart %+v", pos)
+	}
+}
+
+func TestPositionsShortSell(t *testing.T) {
+	p, _ := NewPositions(context.Background(), &PositionsConfig{ShortSellAccounts: []string{"MM"}}, nil)
+
+	short := newSell("O1", "A1", 10)
+	short.ShortSell = true
+	if err := p.Lock(short); err != ErrShortSellNotAllowed {
+		t.Fatalf("expected ErrShortSellNotAllowed, got %v", err)
+	}
+
+	short = newSell("O2", "MM", 10)
+	short.ShortSell = true
+	if err := p.Lock(short); err != nil {
+		t.Fatalf("approved short sell err=%v", err)
+	}
+	if pos := p.Position("MM", "TEST"); pos.Available != -10 {
+		t.Fatalf("expected short position of 10, got %+v", pos)
+	}
+}
+
+func TestPositionsShareOneStore(t *testing.T) {
+	// two shards of one deployment
+	store := NewMemoryStore()
+	store.Add(context.Background(), "A1", "TEST", 100, 0)
+	shard1, _ := NewPositions(context.Background(), nil, store)
+	shard2, _ := NewPositions(context.Background(), nil, store)
+
+	if err := shard1.Lock(newSell("O1", "A1", 80)); err != nil {
+		t.Fatalf("lock err=%v", err)
+	}
+	if err := shard2.Lock(newSell("O2", "A1", 30)); err != ErrInsufficientHoldings {
+		t.Fatalf("expected ErrInsufficientHoldings on the other shard, got %v", err)
+	}
+	shard1.Release("O1")
+	if err := shard2.Lock(newSell("O2", "A1", 30)); err != nil {
+		t.Fatalf("lock after release err=%v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package oms
+
+import (
+	"context"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+// PutThrough books a negotiated trade between two accounts. The price must
+// be inside the ceil/floor band of the instrument and both sides pass the
+// pre-trade checks of an order, the trade never touches the continuous book
+// and both sides are reported as filled. A refused put-through reports both
+// sides Rejected.
+func (s *OMS) PutThrough(ctx context.Context, putThrough *model.PutThrough) error {
+	buyOrder := s.newPutThroughOrder(putThrough, model.OrderSideBuy, putThrough.BuyGatewayID, putThrough.BuyAccount)
+	sellOrder := s.newPutThroughOrder(putThrough, model.OrderSideSell, putThrough.SellGatewayID, putThrough.SellAccount)
+	if err := s.acceptPutThrough(putThrough, buyOrder, sellOrder); err != nil {
+		for _, order := range []*model.Order{buyOrder, sellOrder} {
+			// a missing side has no ClOrdID to answer
+			if order.GatewayID != "" {
+				s.rejectOrder(ctx, order, err)
+			}
+		}
+		return err
+	}
+	buyOrder.CounterpartyAccount = sellOrder.Account
+	sellOrder.CounterpartyAccount = buyOrder.Account
+
+	for _, order := range []*model.Order{buyOrder, sellOrder} {
+		s.AddOrderToMap(order)
+		order.UpdateNew()
+		s.reportOrder(ctx, order)
+	}
+
+	price, qty := putThrough.Price.InexactFloat64(), putThrough.Quantity.IntPart()
+	match := &orderbook.MatchResult{
+		OrderID:        buyOrder.OrderID,
+		CounterOrderID: sellOrder.OrderID,
+		Price:          price,
+		ExactPrice:     putThrough.Price,
+		Qty:            qty,
+		Side:           orderbook.BUY,
+	}
+	buyOrder.UpdateMatchResult(match)
+	sellOrder.UpdateMatchResult(match)
+	buyOrder.CounterpartyExecID = sellOrder.ExecID
+	sellOrder.CounterpartyExecID = buyOrder.ExecID
+
+	s.tradeStats.addPutThrough(putThrough.Symbol, price, qty)
+
+	s.reportOrder(ctx, buyOrder)
+	s.reportOrder(ctx, sellOrder)
+
+	return nil
+}
+
+// acceptPutThrough runs the entry checks of both sides: duplicate, kill
+// switch, price band and then the pre-trade risk chain, which reserves the
+// cash of the buyer and locks the holdings of the seller.
+func (s *OMS) acceptPutThrough(putThrough *model.PutThrough, buyOrder, sellOrder *model.Order) error {
+	if putThrough.BuyGatewayID == "" || putThrough.SellGatewayID == "" ||
+		putThrough.BuyGatewayID == putThrough.SellGatewayID {
+		return errInvalidPutThrough
+	}
+	if s.isDuplicate(putThrough.BuyGatewayID) || s.isDuplicate(putThrough.SellGatewayID) {
+		return errDuplicateOrder
+	}
+
+	if err := s.checkKillSwitch(putThrough.BuyAccount, "", putThrough.Symbol); err != nil {
+		return err
+	}
+	if err := s.checkKillSwitch(putThrough.SellAccount, "", putThrough.Symbol); err != nil {
+		return err
+	}
+
+	if s.instruments == nil {
+		return errUnknownSymbol
+	}
+	instrument, ok := s.instruments.Get(putThrough.Symbol)
+	if !ok {
+		return errUnknownSymbol
+	}
+	// the band is quoted like market data, scaled as the limit price rule
+	// does
+	scale := decimal.NewFromFloat(s.risk.PriceScale())
+	ceil := decimal.NewFromFloat(instrument.Ceil).Mul(scale)
+	floor := decimal.NewFromFloat(instrument.Floor).Mul(scale)
+	if putThrough.Price.GreaterThan(ceil) || putThrough.Price.LessThan(floor) {
+		return errPriceOutOfBand
+	}
+	if putThrough.Quantity.IntPart() <= 0 {
+		return errInvalidQuantity
+	}
+
+	if err := s.preTrade(buyOrder); err != nil {
+		return err
+	}
+	return s.preTrade(sellOrder)
+}
+
+func (s *OMS) newPutThroughOrder(putThrough *model.PutThrough, side model.OrderSide, gatewayID, account string) *model.Order {
+	order := &model.Order{}
+	order.UpdateAddOrder(&model.AddOrder{
+		GatewayID:    gatewayID,
+		Account:      account,
+		Symbol:       putThrough.Symbol,
+		SecurityID:   putThrough.SecurityID,
+		Type:         model.OrderTypeLimit,
+		Price:        putThrough.Price,
+		Side:         side,
+		TransactTime: putThrough.TransactTime,
+		Quantity:     putThrough.Quantity,
+	})
+	order.Board = model.OrderBoardPutThrough
+
+	return order
+}
@@ -0,0 +1,102 @@
+package oms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/ledger"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
+	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
+	"github.com/shopspring/decimal"
+)
+
+func TestPutThrough(t *testing.T) {
+	ctx := context.Background()
+	cash, _ := ledger.NewLedger(ctx, nil, nil, nil)
+	cash.Deposit("ACC-B", decimal.NewFromInt(100_000_000))
+	positions, _ := position.NewPositions(ctx, nil, nil)
+	positions.Adjust("ACC-S", "DVT", 1000)
+	// the band of config/market_data.json is quoted in thousand VND
+	risk, _ := riskrule.NewChain(&riskrule.Config{
+		Rules:      []string{riskrule.RuleBuyingPower, riskrule.RuleHoldings},
+		LimitPrice: &riskrule.LimitPriceConfig{PriceScale: 1000},
+	}, &riskrule.Deps{Cash: cash, Positions: positions})
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{
+		Risk:      risk,
+		Ledger:    cash,
+		Positions: positions,
+		Instruments: instrument.NewStore([]*instrument.Instrument{
+			{Symbol: "DVT", Exchange: "UPCOM", BoardLot: 1, Ceil: 11.2, Ref: 9.8, Floor: 8.4},
+		}),
+	})
+	defer s.Stop()
+
+	putThrough := &model.PutThrough{
+		CrossID:       "X1",
+		Symbol:        "DVT",
+		Price:         decimal.NewFromInt(11_300),
+		Quantity:      decimal.NewFromInt(1000),
+		BuyGatewayID:  "B1",
+		BuyAccount:    "ACC-B",
+		SellGatewayID: "S1",
+		SellAccount:   "ACC-S",
+	}
+	if err := s.PutThrough(ctx, putThrough); err != errPriceOutOfBand {
+		t.Fatalf("expected price band violation, got %v", err)
+	}
+	for _, id := range []string{"B1", "S1"} {
+		r := gw.lastReport(id)
+		if r == nil || r.ExecType != model.ExecTypeRejected || r.RejectReason != model.RejectReasonPriceExceedsBand {
+			t.Fatalf("expected %s rejected out of band, got %+v", id, r)
+		}
+	}
+
+	// the seller holds 1000 shares only
+	putThrough.BuyGatewayID, putThrough.SellGatewayID = "B2", "S2"
+	putThrough.Price = decimal.NewFromInt(11_200)
+	putThrough.Quantity = decimal.NewFromInt(2000)
+	if err := s.PutThrough(ctx, putThrough); err == nil {
+		t.Fatal("expected put-through above holdings to be rejected")
+	}
+	if r := gw.lastReport("S2"); r == nil || r.Status != model.OrderStatusRejected || r.Text != "insufficient holdings" {
+		t.Fatalf("expected insufficient holdings reject, got %+v", r)
+	}
+	if b, _ := cash.Balance("ACC-B"); !b.Reserved.Equal(decimal.NewFromInt(0)) {
+		t.Fatalf("expected the buyer reservation released, got %+v", b)
+	}
+
+	putThrough.BuyGatewayID, putThrough.SellGatewayID = "B3", "S3"
+	putThrough.Price = decimal.NewFromInt(10_500)
+	putThrough.Quantity = decimal.NewFromInt(1000)
+	if err := s.PutThrough(ctx, putThrough); err != nil {
+		t.Fatalf("put-through err=%v", err)
+	}
+	for _, id := range []string{"B3", "S3"} {
+		r := gw.lastReport(id)
+		if r.ExecType != model.ExecTypeTrade || r.Status != model.OrderStatusFilled || !r.LastPrice.Equal(decimal.NewFromInt(10_500)) {
+			t.Fatalf("expected %s filled at 10500, got %+v", id, r)
+		}
+	}
+	if b, _ := cash.Balance("ACC-B"); !b.Cash.Equal(decimal.NewFromInt(100_000_000-10_500_000)) || !b.Reserved.Equal(decimal.NewFromInt(0)) {
+		t.Fatalf("unexpected buyer balance %+v", b)
+	}
+	if pos := positions.Position("ACC-S", "DVT"); pos.Quantity != 0 || pos.Locked != 0 {
+		t.Fatalf("unexpected seller position %+v", pos)
+	}
+
+	stats, ok := s.GetTradeStats("DVT")
+	if !ok || stats.PutThroughVolume != 1000 || stats.Volume != 1000 || stats.LastPrice != 0 {
+		t.Fatalf("unexpected trade stats %+v", stats)
+	}
+
+	// the book is untouched
+	buy := newAddOrder("B4", model.OrderSideBuy, model.OrderTypeLimit, 10_500, 10)
+	buy.Symbol, buy.Account = "DVT", "ACC-B"
+	s.AddOrder(ctx, buy)
+	if r := gw.lastReport("B4"); r.Status != model.OrderStatusNew {
+		t.Fatalf("expected B4 resting, got %+v", r)
+	}
+}
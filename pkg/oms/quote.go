@@ -0,0 +1,217 @@
+package oms
+
+import (
+	"context"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+// quote holds the resting sides of the quote of one account on one symbol.
+// Quote sides are plain main board limit orders in the book, but only their
+// fills are reported to the gateway: new, replaced and pulled sides are
+// acknowledged through the quote itself.
+type quote struct {
+	quoteID   string
+	account   string
+	sessionID string
+	symbol    string
+	bid       *model.Order
+	offer     *model.Order
+}
+
+func (q *quote) liveIDs() []string {
+	var ids []string
+	for _, order := range []*model.Order{q.bid, q.offer} {
+		if order != nil && !order.IsEnd() {
+			ids = append(ids, order.OrderID)
+		}
+	}
+	return ids
+}
+
+func quoteKey(account, symbol string) string {
+	return account + "|" + symbol
+}
+
+// Quote replaces both sides of the quote of the account on the symbol in one
+// step.
+func (s *OMS) Quote(ctx context.Context, q *model.Quote) error {
+	if err := s.checkKillSwitch(q.Account, q.SessionID, q.Symbol); err != nil {
+		return err
+	}
+	if err := s.validateQuote(q); err != nil {
+		return err
+	}
+
+	next := &quote{
+		quoteID:   q.QuoteID,
+		account:   q.Account,
+		sessionID: q.SessionID,
+		symbol:    q.Symbol,
+	}
+	if q.BidSize.IsPositive() {
+		next.bid = newQuoteOrder(q, model.OrderSideBuy, q.BidPx, q.BidSize)
+	}
+	if q.OfferSize.IsPositive() {
+		next.offer = newQuoteOrder(q, model.OrderSideSell, q.OfferPx, q.OfferSize)
+	}
+	orders := make([]*model.Order, 0, 2)
+	for _, order := range []*model.Order{next.bid, next.offer} {
+		if order != nil {
+			orders = append(orders, order)
+		}
+	}
+	for _, order := range orders {
+		if err := s.risk.Check(order); err != nil {
+			return err
+		}
+	}
+
+	key := quoteKey(q.Account, q.Symbol)
+	s.quoteMu.Lock()
+	prev := s.quotes[key]
+	var cancelIDs []string
+	if prev != nil {
+		cancelIDs = prev.liveIDs()
+	}
+	for _, order := range orders {
+		s.AddOrderToMap(order)
+		s.recordOrder(order)
+	}
+
+	bookOrders := make([]*orderbook.Order, 0, len(orders))
+	for _, order := range orders {
+		bookOrders = append(bookOrders, toBookOrder(order))
+	}
+	results := s.orderbookManager.ReplaceQuote(q.Symbol, cancelIDs, bookOrders...)
+
+	if prev != nil {
+		s.pullQuote(prev)
+	}
+	if len(orders) == 0 {
+		delete(s.quotes, key)
+	} else {
+		s.quotes[key] = next
+	}
+	s.quoteMu.Unlock()
+
+	s.processMatchResult(results)
+
+	return nil
+}
+
+// MassQuote applies every entry as its own quote and returns one error per
+// entry, nil when the entry was accepted.
+func (s *OMS) MassQuote(ctx context.Context, massQuote *model.MassQuote) []error {
+	errs := make([]error, len(massQuote.Quotes))
+	for i, q := range massQuote.Quotes {
+		q.QuoteID = massQuote.QuoteID
+		q.Account = massQuote.Account
+		q.SessionID = massQuote.SessionID
+		errs[i] = s.Quote(ctx, q)
+	}
+
+	return errs
+}
+
+// CancelQuotes pulls every quote of account, or only the one on symbol when
+// symbol is not empty. Gateways call it when a market maker disconnects.
+func (s *OMS) CancelQuotes(ctx context.Context, account, symbol string) error {
+	s.quoteMu.Lock()
+	defer s.quoteMu.Unlock()
+
+	for key, q := range s.quotes {
+		if q.account != account || (symbol != "" && q.symbol != symbol) {
+			continue
+		}
+		s.orderbookManager.ReplaceQuote(q.symbol, q.liveIDs())
+		s.pullQuote(q)
+		delete(s.quotes, key)
+	}
+
+	return nil
+}
+
+// pullQuote cancels the live sides of q after they left the book, each side
+// keeps its own ClOrdID.
+func (s *OMS) pullQuote(q *quote) {
+	for _, order := range []*model.Order{q.bid, q.offer} {
+		if order == nil || order.IsEnd() {
+			continue
+		}
+		order.UpdateCancelOrder(&model.CancelOrder{
+			GatewayID:     order.GatewayID,
+			OrigGatewayID: order.OrigGatewayID,
+		})
+		s.recordOrder(order)
+	}
+}
+
+func (s *OMS) validateQuote(q *model.Quote) error {
+	if q.QuoteID == "" || q.Account == "" || q.Symbol == "" {
+		return errInvalidQuote
+	}
+	if s.instruments != nil {
+		if _, ok := s.instruments.Get(q.Symbol); !ok {
+			return errUnknownSymbol
+		}
+	}
+	if q.BidSize.IsNegative() || q.OfferSize.IsNegative() {
+		return errInvalidQuantity
+	}
+
+	hasBid, hasOffer := q.BidSize.IsPositive(), q.OfferSize.IsPositive()
+	if (hasBid && !q.BidPx.IsPositive()) || (hasOffer && !q.OfferPx.IsPositive()) {
+		return errInvalidQuote
+	}
+	if hasBid && hasOffer && !q.BidPx.LessThan(q.OfferPx) {
+		return errInvalidQuoteSpread
+	}
+
+	// quotes trade on the main board only
+	for _, size := range []decimal.Decimal{q.BidSize, q.OfferSize} {
+		if !size.IsPositive() {
+			continue
+		}
+		board, err := s.resolveBoard(q.Symbol, size.IntPart(), model.OrderTypeLimit, model.OrderTimeInForceDAY)
+		if err != nil {
+			return err
+		}
+		if board != model.OrderBoardMain {
+			return errInvalidBoardLot
+		}
+	}
+
+	return nil
+}
+
+func newQuoteOrder(q *model.Quote, side model.OrderSide, price, size decimal.Decimal) *model.Order {
+	order := &model.Order{}
+	order.UpdateAddOrder(&model.AddOrder{
+		GatewayID:    q.SideGatewayID(side),
+		Account:      q.Account,
+		Symbol:       q.Symbol,
+		SecurityID:   q.SecurityID,
+		Type:         model.OrderTypeLimit,
+		TimeInForce:  model.OrderTimeInForceDAY,
+		Price:        price,
+		Side:         side,
+		TransactTime: q.TransactTime,
+		SessionID:    q.SessionID,
+		Quantity:     size,
+	})
+	order.QuoteID = q.QuoteID
+	order.UpdateNew()
+
+	return order
+}
+
+// recordOrder stores an event of the current order state without reporting
+// it to the gateway.
+func (s *OMS) recordOrder(order *model.Order) {
+	order.Seq++
+	s.eventstore.AddEvent(model.NewOrderEvent(*order, time.Now()))
+}
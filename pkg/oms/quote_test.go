@@ -0,0 +1,97 @@
+package oms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func newQuote(quoteID string, bidPx, bidSize, offerPx, offerSize int64) *model.Quote {
+	return &model.Quote{
+		QuoteID:   quoteID,
+		Account:   "MM",
+		Symbol:    "TEST",
+		BidPx:     decimal.NewFromInt(bidPx),
+		BidSize:   decimal.NewFromInt(bidSize),
+		OfferPx:   decimal.NewFromInt(offerPx),
+		OfferSize: decimal.NewFromInt(offerSize),
+	}
+}
+
+func TestQuoteReplacesBothSides(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.Quote(ctx, newQuote("Q1", 99, 10, 101, 10)); err != nil {
+		t.Fatalf("quote err=%v", err)
+	}
+	if err := s.Quote(ctx, newQuote("Q2", 98, 5, 102, 5)); err != nil {
+		t.Fatalf("quote err=%v", err)
+	}
+
+	bids, asks := s.orderbookManager.Depth("TEST", 5)
+	if len(bids) != 1 || bids[0].Price != 98 || bids[0].Qty != 5 {
+		t.Fatalf("expected bid 98x5, got %+v", bids)
+	}
+	if len(asks) != 1 || asks[0].Price != 102 || asks[0].Qty != 5 {
+		t.Fatalf("expected ask 102x5, got %+v", asks)
+	}
+
+	// only fills of a quote are reported
+	if r := gw.lastReport("Q1-B"); r != nil {
+		t.Fatalf("unexpected report for replaced quote %+v", r)
+	}
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 98, 2))
+	if r := gw.lastReport("Q2-B"); r == nil || r.ExecType != model.ExecTypeTrade || r.Side != model.OrderSideBuy || r.LastQuantity != 2 {
+		t.Fatalf("expected quote bid fill of 2, got %+v", r)
+	}
+}
+
+func TestQuoteRejectsCrossedSpread(t *testing.T) {
+	s := NewOMS(&mockOrderGateway{}, nil)
+	defer s.Stop()
+
+	if err := s.Quote(context.Background(), newQuote("Q1", 101, 10, 101, 10)); err != errInvalidQuoteSpread {
+		t.Fatalf("expected errInvalidQuoteSpread, got %v", err)
+	}
+}
+
+func TestCancelQuotes(t *testing.T) {
+	s := NewOMS(&mockOrderGateway{}, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	errs := s.MassQuote(ctx, &model.MassQuote{
+		QuoteID: "MQ1",
+		Account: "MM",
+		Quotes: []*model.Quote{
+			{QuoteEntryID: "E1", Symbol: "TEST", BidPx: decimal.NewFromInt(99), BidSize: decimal.NewFromInt(10)},
+			{QuoteEntryID: "E2", Symbol: "OTHER", OfferPx: decimal.NewFromInt(50), OfferSize: decimal.NewFromInt(10)},
+		},
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("entry %d err=%v", i, err)
+		}
+	}
+	// every side keeps its own ClOrdID chain
+	for _, id := range []string{"MQ1-E1-B", "MQ1-E2-S"} {
+		if s.eventstore.GetOrderID(id) == "" {
+			t.Fatalf("expected %s to resolve to its order", id)
+		}
+	}
+
+	s.CancelQuotes(ctx, "MM", "")
+	for _, symbol := range []string{"TEST", "OTHER"} {
+		if bids, asks := s.orderbookManager.Depth(symbol, 0); len(bids) != 0 || len(asks) != 0 {
+			t.Fatalf("expected %s quotes pulled, got %+v %+v", symbol, bids, asks)
+		}
+	}
+	if len(s.quotes) != 0 {
+		t.Fatalf("expected no quotes left")
+	}
+}
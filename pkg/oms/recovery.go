@@ -0,0 +1,149 @@
+package oms
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+// EventLoader reads the persisted order events, repo.IOrderEvent is one.
+type EventLoader interface {
+	LoadEvents(ctx context.Context) ([]*model.OrderEvent, error)
+}
+
+// Recover rebuilds the state lost in a crash from the event log: the
+// orders of this node, their ClOrdID chains, the resting orders of the
+// books, parked stop orders, quotes and the cash and holdings they hold, and
+// the kill switches still engaged.
+// It must run before Start, gateways only take requests on a rebuilt state.
+//
+// The book is restored without matching, resting orders keep the priority
+// of the report that last placed them. An order still PendingNew never got
+// its book result, it is accepted and reported New. What is left of an IOC,
+// FOK or market order never rests and is canceled. Links of contingent order
+// lists are not in the log: live legs come back as plain orders, a bracket
+// leg still held for its entry is canceled.
+func (s *OMS) Recover(ctx context.Context, loader EventLoader) error {
+	if s.started.Load() {
+		return errRecoverAfterStart
+	}
+	if err := s.RestoreKillSwitches(ctx); err != nil {
+		return err
+	}
+	events, err := loader.LoadEvents(ctx)
+	if err != nil {
+		return err
+	}
+
+	// ExecIDs of a node increase with every report
+	execIDs := make(map[*model.OrderEvent]int64, len(events))
+	for _, ev := range events {
+		execIDs[ev], _ = model.ParseID(ev.ExecID)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return execIDs[events[i]] < execIDs[events[j]] })
+
+	orders := make(map[string]*model.Order)
+	placed := make(map[string]int64) // orderID -> ExecID of the report that placed it on the book
+	var configVersion int64
+	for _, ev := range events {
+		model.ObserveID(ev.OrderID)
+		model.ObserveID(ev.ExecID)
+		configVersion = max(configVersion, ev.ConfigVersion)
+		if !model.OwnID(ev.OrderID) {
+			continue
+		}
+		// a redelivered event is older than the state already rebuilt
+		if order := orders[ev.OrderID]; order != nil && ev.Seq < order.Seq {
+			continue
+		}
+
+		s.eventstore.RestoreEvent(ev)
+		orders[ev.OrderID] = model.NewOrderFromEvent(ev)
+		switch ev.ExecType {
+		case model.ExecTypeNew, model.ExecTypeReplaced, model.ExecTypeRestated:
+			placed[ev.OrderID] = execIDs[ev]
+		}
+	}
+	s.raiseConfigVersion(configVersion)
+
+	live := make([]*model.Order, 0, len(orders))
+	for _, order := range orders {
+		if order.IsEnd() {
+			s.eventstore.DeleteChainByOrderID(order.OrderID)
+			continue
+		}
+		live = append(live, order)
+	}
+	sort.Slice(live, func(i, j int) bool { return placed[live[i].OrderID] < placed[live[j].OrderID] })
+
+	for _, order := range live {
+		s.AddOrderToMap(order)
+		s.restoreOrder(ctx, order)
+	}
+	log.Printf("recovered %d orders from %d events, %d live", len(orders), len(events), len(live))
+
+	return nil
+}
+
+// restoreOrder puts a live recovered order back where it was before the
+// crash.
+func (s *OMS) restoreOrder(ctx context.Context, order *model.Order) {
+	if order.QuoteID != "" {
+		s.restoreQuote(order)
+		s.orderbookManager.RestoreOrder(toBookOrder(order))
+		return
+	}
+
+	// a held bracket leg lost the entry it waits for, and the remainder of an
+	// order that never rests was not canceled before the crash
+	if (order.Status == model.OrderStatusPendingNew && order.ListID != "") ||
+		(order.Type != model.OrderTypeStop && !resting(order)) {
+		s.cancelRemainder(ctx, order)
+		return
+	}
+
+	if err := s.ledger.Reserve(order); err != nil {
+		log.Printf("recover order %s: %v", order.OrderID, err)
+	}
+	if err := s.positions.Lock(order); err != nil {
+		log.Printf("recover order %s: %v", order.OrderID, err)
+	}
+
+	if order.Status == model.OrderStatusPendingNew {
+		if err := order.UpdateNew(); err != nil {
+			log.Printf("recover order %s: %v", order.OrderID, err)
+			return
+		}
+		s.reportOrder(ctx, order)
+	}
+
+	if order.Type == model.OrderTypeStop {
+		s.addStopOrder(order)
+		return
+	}
+	s.bookManager(order).RestoreOrder(toBookOrder(order))
+}
+
+func (s *OMS) restoreQuote(order *model.Order) {
+	s.quoteMu.Lock()
+	defer s.quoteMu.Unlock()
+
+	key := quoteKey(order.Account, order.Symbol)
+	q := s.quotes[key]
+	if q == nil {
+		q = &quote{
+			quoteID:   order.QuoteID,
+			account:   order.Account,
+			sessionID: order.SessionID,
+			symbol:    order.Symbol,
+		}
+		s.quotes[key] = q
+	}
+	if order.Side == model.OrderSideBuy {
+		q.bid = order
+	} else {
+		q.offer = order
+	}
+}
@@ -0,0 +1,190 @@
+package oms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
+	"github.com/shopspring/decimal"
+)
+
+// eventLog replays the reports of a gateway as the persisted event log.
+type eventLog []*model.OrderEvent
+
+func (l eventLog) LoadEvents(ctx context.Context) ([]*model.OrderEvent, error) {
+	return l, nil
+}
+
+func eventsOf(gw *mockOrderGateway) eventLog {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	// the log of a crashed OMS comes back in any order
+	events := make(eventLog, 0, len(gw.reports))
+	for i := len(gw.reports) - 1; i >= 0; i-- {
+		events = append(events, model.NewOrderEvent(gw.reports[i], time.Now()))
+	}
+	return events
+}
+
+func TestRecoverRebuildsBooksAndChains(t *testing.T) {
+	gw := &mockOrderGateway{}
+	before := NewOMS(gw, nil)
+	ctx := context.Background()
+
+	_ = before.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 100))
+	_ = before.AddOrder(ctx, newAddOrder("S2", model.OrderSideSell, model.OrderTypeLimit, 102, 100))
+	_ = before.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 40))
+	_ = before.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "S2-R",
+		OrigGatewayID: "S2",
+		NewPrice:      decimal.NewFromInt(101),
+		NewQuantity:   decimal.NewFromInt(50),
+	})
+	_ = before.AddOrder(ctx, newAddOrder("S3", model.OrderSideSell, model.OrderTypeLimit, 103, 10))
+	_ = before.CancelOrder(ctx, &model.CancelOrder{GatewayID: "S3-C", OrigGatewayID: "S3"})
+	_ = before.AddOrder(ctx, newAddOrder("STOP", model.OrderSideBuy, model.OrderTypeStop, 105, 10))
+	before.Stop()
+
+	gw2 := &mockOrderGateway{}
+	s := NewOMS(gw2, nil)
+	defer s.Stop()
+	if err := s.Recover(ctx, eventsOf(gw)); err != nil {
+		t.Fatal(err)
+	}
+	if len(gw2.reports) != 0 {
+		t.Fatalf("expected no reports for recovered orders, got %d", len(gw2.reports))
+	}
+
+	s1, err := s.GetOrderByOrderID(s.eventstore.GetOrderID("S1"))
+	if err != nil || s1.Status != model.OrderStatusPartiallyFilled || s1.LeavesQuantity != 60 || !s1.AvgPrice.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("expected S1 partially filled with leaves 60, got %+v err=%v", s1, err)
+	}
+	if _, err := s.GetOrderByOrderID(s.eventstore.GetOrderID("B1")); err == nil {
+		t.Fatal("expected filled B1 left out")
+	}
+	if len(s.stopOrders["TEST"]) != 1 {
+		t.Fatal("expected the stop order parked")
+	}
+
+	// the ClOrdID chain and the duplicate check survive the restart
+	if err := s.AddOrder(ctx, newAddOrder("S2-R", model.OrderSideSell, model.OrderTypeLimit, 101, 10)); !errors.Is(err, errDuplicateOrder) {
+		t.Fatalf("expected duplicate S2-R, got %v", err)
+	}
+	if chain := s.eventstore.ReconstructChain("S2-R"); len(chain) != 2 || chain[1] != "S2" {
+		t.Fatalf("expected chain S2-R -> S2, got %v", chain)
+	}
+
+	// S1 keeps price priority, S2-R rests at its replaced price
+	_ = s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 101, 80))
+	if r := gw2.lastReport("S1"); r == nil || r.Status != model.OrderStatusFilled || r.OrderID != s1.OrderID || r.Seq != 3 {
+		t.Fatalf("expected S1 filled at seq 3, got %+v", r)
+	}
+	if r := gw2.lastReport("S2-R"); r == nil || r.LastQuantity != 20 || !r.LastPrice.Equal(decimal.NewFromInt(101)) || r.LeavesQuantity != 30 {
+		t.Fatalf("expected S2-R to trade 20 at 101, got %+v", r)
+	}
+
+	if err := s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "S2-C", OrigGatewayID: "S2-R"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := gw2.lastReport("S2-C"); r.Status != model.OrderStatusCanceled {
+		t.Fatalf("expected S2 canceled, got %+v", r)
+	}
+}
+
+func TestRecoverAcceptsPendingNew(t *testing.T) {
+	gw := &mockOrderGateway{}
+	before := NewOMS(gw, &OMSConfig{PendingAcks: true})
+	ctx := context.Background()
+	_ = before.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 100))
+	before.Stop()
+
+	// crashed between the PendingNew ack and the book result
+	events := eventsOf(gw)[1:]
+	if events[0].OrderStatus != model.OrderStatusPendingNew {
+		t.Fatalf("expected the PendingNew event, got %s", events[0].OrderStatus)
+	}
+
+	gw2 := &mockOrderGateway{}
+	s := NewOMS(gw2, nil)
+	defer s.Stop()
+	if err := s.Recover(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+	if r := gw2.lastReport("S1"); r == nil || r.ExecType != model.ExecTypeNew {
+		t.Fatalf("expected S1 reported New, got %+v", r)
+	}
+
+	_ = s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 100))
+	if r := gw2.lastReport("S1"); r.Status != model.OrderStatusFilled {
+		t.Fatalf("expected S1 on the book and filled, got %+v", r)
+	}
+}
+
+func TestRecoverAfterStart(t *testing.T) {
+	s := NewOMS(&mockOrderGateway{}, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	s.Start(ctx)
+	if err := s.Recover(ctx, eventLog{}); !errors.Is(err, errRecoverAfterStart) {
+		t.Fatalf("expected errRecoverAfterStart, got %v", err)
+	}
+}
+
+func TestRecoverCancelsOrdersThatNeverRest(t *testing.T) {
+	gw := &mockOrderGateway{}
+	before := NewOMS(gw, nil)
+	ctx := context.Background()
+	_ = before.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 4))
+	ioc := newAddOrder("I1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10)
+	ioc.TimeInForce = model.OrderTimeInForceIOC
+	_ = before.AddOrder(ctx, ioc)
+	if r := gw.lastReport("I1"); r.ExecType != model.ExecTypeCanceled || r.CumQuantity != 4 || r.LeavesQuantity != 0 {
+		t.Fatalf("expected the IOC remainder canceled, got %+v", r)
+	}
+	_ = before.AddOrderList(ctx, &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeBracket,
+		Orders: []*model.AddOrder{
+			newAddOrder("ENTRY", model.OrderSideBuy, model.OrderTypeLimit, 90, 10),
+			newAddOrder("TP", model.OrderSideSell, model.OrderTypeLimit, 110, 10),
+			newAddOrder("SL", model.OrderSideSell, model.OrderTypeStop, 80, 10),
+		},
+	})
+	before.Stop()
+
+	// crashed before the IOC remainder was canceled
+	var events eventLog
+	for _, ev := range eventsOf(gw) {
+		if ev.GatewayID != "I1" || ev.ExecType != model.ExecTypeCanceled {
+			events = append(events, ev)
+		}
+	}
+
+	gw2 := &mockOrderGateway{}
+	positions, _ := position.NewPositions(ctx, nil, nil)
+	positions.Adjust("ACC-S2", "TEST", 10)
+	s := NewOMS(gw2, &OMSConfig{Positions: positions})
+	defer s.Stop()
+	if err := s.Recover(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"I1", "TP", "SL"} {
+		if r := gw2.lastReport(id); r == nil || r.ExecType != model.ExecTypeCanceled || r.LeavesQuantity != 0 {
+			t.Fatalf("expected %s canceled, got %+v", id, r)
+		}
+	}
+	if pos := positions.Position("ACC-TP", "TEST"); pos.Locked != 0 {
+		t.Fatalf("expected the held leg to lock nothing, got %+v", pos)
+	}
+
+	// the IOC is not on the book
+	_ = s.AddOrder(ctx, newAddOrder("S2", model.OrderSideSell, model.OrderTypeLimit, 100, 6))
+	if r := gw2.lastReport("S2"); r.Status != model.OrderStatusNew {
+		t.Fatalf("expected S2 to rest, got %+v", r)
+	}
+}
@@ -0,0 +1,255 @@
+package oms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func TestEntryErrorsAreReportedRejected(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{
+		Instruments: instrument.NewStore([]*instrument.Instrument{
+			{Symbol: "TEST", ISIN: "VN000000TEST", Exchange: "HOSE", BoardLot: 100},
+		}),
+	})
+	defer s.Stop()
+	ctx := context.Background()
+
+	unknown := newAddOrder("U1", model.OrderSideBuy, model.OrderTypeLimit, 100, 100)
+	unknown.Symbol = "NOPE"
+	unsupported := newAddOrder("T1", model.OrderSideBuy, "", 100, 100)
+	noSide := newAddOrder("D1", "", model.OrderTypeLimit, 100, 100)
+	zeroQty := newAddOrder("Q1", model.OrderSideBuy, model.OrderTypeLimit, 100, 0)
+	mixedLot := newAddOrder("L1", model.OrderSideBuy, model.OrderTypeLimit, 100, 150)
+	zeroStop := newAddOrder("P1", model.OrderSideBuy, model.OrderTypeStop, 0, 100)
+	byISIN := newAddOrder("I1", model.OrderSideBuy, model.OrderTypeLimit, 100, 100)
+	byISIN.Symbol = "VN000000TEST"
+
+	tests := []struct {
+		addOrder *model.AddOrder
+		err      error
+		reason   model.OrderRejectReason
+	}{
+		{unknown, errUnknownSymbol, model.RejectReasonUnknownSymbol},
+		{unsupported, errUnsupportedOrderType, model.RejectReasonUnsupportedOrder},
+		{noSide, errUnsupportedSide, model.RejectReasonUnsupportedOrder},
+		{zeroQty, errInvalidQuantity, model.RejectReasonIncorrectQuantity},
+		{mixedLot, errInvalidBoardLot, model.RejectReasonIncorrectQuantity},
+		{zeroStop, errInvalidStopPrice, model.RejectReasonUnsupportedOrder},
+		{byISIN, nil, ""},
+	}
+	for _, tt := range tests {
+		if err := s.AddOrder(ctx, tt.addOrder); err != tt.err {
+			t.Fatalf("%s: expected %v, got %v", tt.addOrder.GatewayID, tt.err, err)
+		}
+		r := gw.lastReport(tt.addOrder.GatewayID)
+		if tt.err == nil {
+			if r == nil || r.Status != model.OrderStatusNew {
+				t.Fatalf("%s: expected New, got %+v", tt.addOrder.GatewayID, r)
+			}
+			continue
+		}
+		if r == nil || r.Status != model.OrderStatusRejected || r.ExecType != model.ExecTypeRejected ||
+			r.RejectReason != tt.reason || r.Text != tt.err.Error() {
+			t.Fatalf("%s: expected %s reject, got %+v", tt.addOrder.GatewayID, tt.reason, r)
+		}
+	}
+}
+
+func TestDuplicateOrderIsRejectedWithoutTakingTheClOrdID(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("C1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if err := s.AddOrder(ctx, newAddOrder("C1", model.OrderSideBuy, model.OrderTypeLimit, 101, 10)); err != errDuplicateOrder {
+		t.Fatalf("expected duplicate, got %v", err)
+	}
+	r := gw.lastReport("C1")
+	if r == nil || r.Status != model.OrderStatusRejected || r.RejectReason != model.RejectReasonDuplicateOrder {
+		t.Fatalf("expected duplicate reject, got %+v", r)
+	}
+	duplicateID := r.OrderID
+
+	// the ClOrdID still points at the live order
+	if err := s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "C1-X", OrigGatewayID: "C1"}); err != nil {
+		t.Fatalf("cancel err=%v", err)
+	}
+	if r := gw.lastReport("C1-X"); r == nil || r.Status != model.OrderStatusCanceled || r.OrderID == duplicateID {
+		t.Fatalf("expected live order canceled, got %+v", r)
+	}
+}
+
+func TestInvalidOrderListRejectsEveryOrder(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+
+	err := s.AddOrderList(context.Background(), &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeOCO,
+		Orders: []*model.AddOrder{
+			newAddOrder("O1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10),
+		},
+	})
+	if err != errInvalidOrderList {
+		t.Fatalf("expected invalid list, got %v", err)
+	}
+	if r := gw.lastReport("O1"); r == nil || r.Status != model.OrderStatusRejected || r.RejectReason != model.RejectReasonUnsupportedOrder {
+		t.Fatalf("expected list order rejected, got %+v", r)
+	}
+}
+
+func TestRefusedCancelAndReplaceAreAnswered(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{
+		Instruments: instrument.NewStore([]*instrument.Instrument{
+			{Symbol: "TEST", Exchange: "HOSE", BoardLot: 100},
+		}),
+	})
+	defer s.Stop()
+	ctx := context.Background()
+
+	s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 98, 100))
+	s.AddOrder(ctx, newAddOrder("F1", model.OrderSideBuy, model.OrderTypeLimit, 99, 100))
+	s.AddOrder(ctx, newAddOrder("F2", model.OrderSideSell, model.OrderTypeLimit, 99, 100))
+
+	tests := []struct {
+		name   string
+		call   func() error
+		err    error
+		status model.OrderStatus
+		reason model.OrderRejectReason
+	}{
+		{"unknown order", func() error {
+			return s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "X1", OrigGatewayID: "NOPE"})
+		}, errGatewayIDNotFound, model.OrderStatusRejected, model.RejectReasonUnknownOrder},
+		{"filled order", func() error {
+			return s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "X2", OrigGatewayID: "F1"})
+		}, errInvalidOrderStatus, model.OrderStatusFilled, model.RejectReasonTooLateToEnter},
+		{"unknown order replace", func() error {
+			return s.ModifyOrder(ctx, &model.ModifyOrder{GatewayID: "X3", OrigGatewayID: "NOPE", NewQuantity: decimal.NewFromInt(100)})
+		}, errGatewayIDNotFound, model.OrderStatusRejected, model.RejectReasonUnknownOrder},
+		{"filled order replace", func() error {
+			return s.ModifyOrder(ctx, &model.ModifyOrder{GatewayID: "X4", OrigGatewayID: "F2", NewQuantity: decimal.NewFromInt(200)})
+		}, errInvalidOrderStatus, model.OrderStatusFilled, model.RejectReasonTooLateToEnter},
+		{"replace to odd lot", func() error {
+			return s.ModifyOrder(ctx, &model.ModifyOrder{GatewayID: "X5", OrigGatewayID: "B1", NewPrice: decimal.NewFromInt(98), NewQuantity: decimal.NewFromInt(50)})
+		}, errInvalidBoardLot, model.OrderStatusNew, model.RejectReasonIncorrectQuantity},
+	}
+	for i, tt := range tests {
+		if err := tt.call(); err != tt.err {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+		gw.mu.Lock()
+		if len(gw.cancelRejects) != i+1 {
+			gw.mu.Unlock()
+			t.Fatalf("%s: expected a cancel reject", tt.name)
+		}
+		cr := gw.cancelRejects[i]
+		gw.mu.Unlock()
+		if cr.Status != tt.status || cr.Reason != tt.reason {
+			t.Fatalf("%s: unexpected cancel reject %+v", tt.name, cr)
+		}
+	}
+}
+
+func TestBookRefusalIsAnsweredWithoutReport(t *testing.T) {
+	for _, pendingAcks := range []bool{false, true} {
+		gw := &mockOrderGateway{}
+		s := NewOMS(gw, &OMSConfig{PendingAcks: pendingAcks})
+		ctx := context.Background()
+
+		s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10))
+		order, _ := s.GetOrderByOrderID(gw.lastReport("B1").OrderID)
+		// the book lost the order
+		s.orderbookManager.CancelOrder(order.Symbol, order.OrderID)
+		gw.mu.Lock()
+		reports := len(gw.reports)
+		gw.mu.Unlock()
+
+		if err := s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "B1-C", OrigGatewayID: "B1"}); err == nil {
+			t.Fatalf("pendingAcks=%v: expected the cancel to fail", pendingAcks)
+		}
+		if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+			GatewayID:     "B1-R",
+			OrigGatewayID: "B1",
+			NewPrice:      decimal.NewFromInt(101),
+			NewQuantity:   decimal.NewFromInt(10),
+		}); err == nil {
+			t.Fatalf("pendingAcks=%v: expected the replace to fail", pendingAcks)
+		}
+
+		gw.mu.Lock()
+		for _, r := range gw.reports[reports:] {
+			if r.ExecType == model.ExecTypeCanceled || r.ExecType == model.ExecTypeReplaced {
+				t.Errorf("pendingAcks=%v: unexpected report %+v", pendingAcks, r)
+			}
+		}
+		if len(gw.cancelRejects) != 2 {
+			t.Errorf("pendingAcks=%v: expected two cancel rejects, got %+v", pendingAcks, gw.cancelRejects)
+		}
+		for _, cr := range gw.cancelRejects {
+			if cr.Status != model.OrderStatusNew {
+				t.Errorf("pendingAcks=%v: expected the order New, got %+v", pendingAcks, cr)
+			}
+		}
+		gw.mu.Unlock()
+		if order.Status != model.OrderStatusNew || order.GatewayID != "B1" {
+			t.Errorf("pendingAcks=%v: expected the order unchanged, got %+v", pendingAcks, order)
+		}
+		s.Stop()
+	}
+}
+
+func TestReplaceAfterPartialFillKeepsFilledQuantity(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, nil)
+	defer s.Stop()
+	ctx := context.Background()
+
+	s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10))
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 4))
+
+	if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B1-X",
+		OrigGatewayID: "B1",
+		NewPrice:      decimal.NewFromInt(101),
+		NewQuantity:   decimal.NewFromInt(4),
+	}); err != errInvalidQuantity {
+		t.Fatalf("expected a replace down to the filled quantity refused, got %v", err)
+	}
+	gw.mu.Lock()
+	rejects := len(gw.cancelRejects)
+	gw.mu.Unlock()
+	if rejects != 1 {
+		t.Fatalf("expected a cancel reject, got %d", rejects)
+	}
+
+	if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B1-R",
+		OrigGatewayID: "B1",
+		NewPrice:      decimal.NewFromInt(101),
+		NewQuantity:   decimal.NewFromInt(10),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if r := gw.lastReport("B1-R"); r.CumQuantity != 4 || r.LeavesQuantity != 6 {
+		t.Fatalf("expected cum 4 leaves 6 after the replace, got %+v", r)
+	}
+
+	s.AddOrder(ctx, newAddOrder("S2", model.OrderSideSell, model.OrderTypeLimit, 101, 10))
+	if r := gw.lastReport("B1-R"); r.Status != model.OrderStatusFilled || r.CumQuantity != 10 || r.LeavesQuantity != 0 {
+		t.Fatalf("expected B1 filled at 10, got %+v", r)
+	}
+	if r := gw.lastReport("S2"); r.CumQuantity != 6 || r.LeavesQuantity != 4 {
+		t.Fatalf("expected S2 to fill 6, got %+v", r)
+	}
+}
@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/ledger"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// AccountBalanceSQLRepo is the ledger.Store shared by engine shards. Cash is
+// one row per account, each node reserves in its own row of
+// account_reservations, and a reservation locks the account row so it is
+// checked against the reservations of every node.
+type AccountBalanceSQLRepo struct {
+	db   *gorm.DB
+	node int64
+}
+
+func NewAccountBalanceSQLRepo(db *gorm.DB, node int64) *AccountBalanceSQLRepo {
+	return &AccountBalanceSQLRepo{
+		db:   db,
+		node: node,
+	}
+}
+
+func (s *AccountBalanceSQLRepo) dbWithContext(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx)
+}
+
+func (r *AccountBalanceSQLRepo) Balance(ctx context.Context, account string) (ledger.Balance, bool, error) {
+	var rows []ledger.Balance
+	err := r.dbWithContext(ctx).Raw(`SELECT b.account, b.cash,
+		COALESCE((SELECT SUM(reserved) FROM account_reservations r WHERE r.account = b.account), 0) AS reserved
+		FROM account_balances b WHERE b.account = ?`, account).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return ledger.Balance{}, false, err
+	}
+	b := rows[0]
+	b.Available = b.Cash.Sub(b.Reserved)
+	return b, true, nil
+}
+
+func (r *AccountBalanceSQLRepo) Reserve(ctx context.Context, account string, amount decimal.Decimal) error {
+	return r.dbWithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cash []decimal.Decimal
+		if err := tx.Raw(`SELECT cash FROM account_balances WHERE account = ? FOR UPDATE`, account).Scan(&cash).Error; err != nil {
+			return err
+		}
+		if len(cash) == 0 {
+			return ledger.ErrUnknownAccount
+		}
+		var reserved decimal.Decimal
+		if err := tx.Raw(`SELECT COALESCE(SUM(reserved), 0) FROM account_reservations WHERE account = ?`, account).Scan(&reserved).Error; err != nil {
+			return err
+		}
+		if amount.GreaterThan(cash[0].Sub(reserved)) {
+			return ledger.ErrInsufficientBuyingPower
+		}
+		return r.reserve(tx, account, amount)
+	})
+}
+
+func (r *AccountBalanceSQLRepo) Add(ctx context.Context, account string, cash, reserved decimal.Decimal) error {
+	return r.dbWithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if !cash.IsZero() {
+			err := tx.Exec(`INSERT INTO account_balances (account, cash, updated_at) VALUES (?, ?, ?)
+				ON CONFLICT (account) DO UPDATE SET cash = account_balances.cash + excluded.cash, updated_at = excluded.updated_at`,
+				account, cash, time.Now()).Error
+			if err != nil {
+				return err
+			}
+		}
+		if !reserved.IsZero() {
+			return r.reserve(tx, account, reserved)
+		}
+		return nil
+	})
+}
+
+// ResetReservations drops the reservations of this node, its live orders
+// reserve again when they are recovered.
+func (r *AccountBalanceSQLRepo) ResetReservations(ctx context.Context) error {
+	return r.dbWithContext(ctx).Exec(`DELETE FROM account_reservations WHERE node = ?`, r.node).Error
+}
+
+func (r *AccountBalanceSQLRepo) reserve(tx *gorm.DB, account string, amount decimal.Decimal) error {
+	return tx.Exec(`INSERT INTO account_reservations (account, node, reserved) VALUES (?, ?, ?)
+		ON CONFLICT (account, node) DO UPDATE SET reserved = account_reservations.reserved + excluded.reserved`,
+		account, r.node, amount).Error
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
+	"gorm.io/gorm"
+)
+
+// AccountPositionSQLRepo is the position.Store shared by engine shards.
+// Holdings are one row per account and symbol, each node locks in its own
+// row of account_position_locks, and a lock locks the holding row so it is
+// checked against the locks of every node.
+type AccountPositionSQLRepo struct {
+	db   *gorm.DB
+	node int64
+}
+
+func NewAccountPositionSQLRepo(db *gorm.DB, node int64) *AccountPositionSQLRepo {
+	return &AccountPositionSQLRepo{
+		db:   db,
+		node: node,
+	}
+}
+
+func (s *AccountPositionSQLRepo) dbWithContext(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx)
+}
+
+func (r *AccountPositionSQLRepo) Position(ctx context.Context, account, symbol string) (position.Position, error) {
+	pos := position.Position{Account: account, Symbol: symbol}
+	err := r.dbWithContext(ctx).Raw(`SELECT
+		COALESCE((SELECT quantity FROM account_positions WHERE account = ? AND symbol = ?), 0) AS quantity,
+		COALESCE((SELECT SUM(locked) FROM account_position_locks WHERE account = ? AND symbol = ?), 0) AS locked`,
+		account, symbol, account, symbol).Row().Scan(&pos.Quantity, &pos.Locked)
+	pos.Available = pos.Quantity - pos.Locked
+	return pos, err
+}
+
+func (r *AccountPositionSQLRepo) Lock(ctx context.Context, account, symbol string, qty int64, short bool) error {
+	return r.dbWithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the holding row serializes the locks of every node
+		err := tx.Exec(`INSERT INTO account_positions (account, symbol, quantity, updated_at) VALUES (?, ?, 0, ?)
+			ON CONFLICT (account, symbol) DO NOTHING`, account, symbol, time.Now()).Error
+		if err != nil {
+			return err
+		}
+		var quantity int64
+		err = tx.Raw(`SELECT quantity FROM account_positions WHERE account = ? AND symbol = ? FOR UPDATE`,
+			account, symbol).Row().Scan(&quantity)
+		if err != nil {
+			return err
+		}
+		var locked int64
+		err = tx.Raw(`SELECT COALESCE(SUM(locked), 0) FROM account_position_locks WHERE account = ? AND symbol = ?`,
+			account, symbol).Row().Scan(&locked)
+		if err != nil {
+			return err
+		}
+		if !short && qty > quantity-locked {
+			return position.ErrInsufficientHoldings
+		}
+		return r.lock(tx, account, symbol, qty)
+	})
+}
+
+func (r *AccountPositionSQLRepo) Add(ctx context.Context, account, symbol string, quantity, locked int64) error {
+	return r.dbWithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if quantity != 0 {
+			err := tx.Exec(`INSERT INTO account_positions (account, symbol, quantity, updated_at) VALUES (?, ?, ?, ?)
+				ON CONFLICT (account, symbol) DO UPDATE SET quantity = account_positions.quantity + excluded.quantity, updated_at = excluded.updated_at`,
+				account, symbol, quantity, time.Now()).Error
+			if err != nil {
+				return err
+			}
+		}
+		if locked != 0 {
+			return r.lock(tx, account, symbol, locked)
+		}
+		return nil
+	})
+}
+
+// ResetLocks drops the locks of this node, its live orders lock again when
+// they are recovered.
+func (r *AccountPositionSQLRepo) ResetLocks(ctx context.Context) error {
+	return r.dbWithContext(ctx).Exec(`DELETE FROM account_position_locks WHERE node = ?`, r.node).Error
+}
+
+func (r *AccountPositionSQLRepo) lock(tx *gorm.DB, account, symbol string, qty int64) error {
+	return tx.Exec(`INSERT INTO account_position_locks (account, symbol, node, locked) VALUES (?, ?, ?, ?)
+		ON CONFLICT (account, symbol, node) DO UPDATE SET locked = account_position_locks.locked + excluded.locked`,
+		account, symbol, r.node, qty).Error
+}
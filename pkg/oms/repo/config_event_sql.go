@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// configEventRow is a ConfigEvent as stored, Files as JSON.
+type configEventRow struct {
+	Node      int64
+	Version   int64
+	Operator  string
+	Digest    string
+	Files     []byte
+	Timestamp time.Time
+}
+
+func (configEventRow) TableName() string {
+	return "config_events"
+}
+
+type ConfigEventSQLRepo struct {
+	db *gorm.DB
+}
+
+func NewConfigEventSQLRepo(db *gorm.DB) *ConfigEventSQLRepo {
+	return &ConfigEventSQLRepo{
+		db: db,
+	}
+}
+
+func (s *ConfigEventSQLRepo) dbWithContext(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx)
+}
+
+// Create skips a version already stored, a redelivered event leaves one row.
+func (r *ConfigEventSQLRepo) Create(ctx context.Context, ev *model.ConfigEvent) error {
+	files, err := json.Marshal(ev.Files)
+	if err != nil {
+		return err
+	}
+	row := &configEventRow{
+		Node:      ev.Node,
+		Version:   ev.Version,
+		Operator:  ev.Operator,
+		Digest:    ev.Digest,
+		Files:     files,
+		Timestamp: ev.Timestamp,
+	}
+	return r.dbWithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error
+}
+
+// Latest returns the highest version of node, nil when node has none.
+func (r *ConfigEventSQLRepo) Latest(ctx context.Context, node int64) (*model.ConfigEvent, error) {
+	var rows []configEventRow
+	err := r.dbWithContext(ctx).Where("node = ?", node).Order("version DESC").Limit(1).Find(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	row := rows[0]
+	ev := &model.ConfigEvent{
+		Node:      row.Node,
+		Version:   row.Version,
+		Operator:  row.Operator,
+		Digest:    row.Digest,
+		Timestamp: row.Timestamp,
+	}
+	return ev, json.Unmarshal(row.Files, &ev.Files)
+}
@@ -0,0 +1,29 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"gorm.io/gorm"
+)
+
+type InstrumentSQLRepo struct {
+	db *gorm.DB
+}
+
+func NewInstrumentSQLRepo(db *gorm.DB) *InstrumentSQLRepo {
+	return &InstrumentSQLRepo{
+		db: db,
+	}
+}
+
+func (s *InstrumentSQLRepo) dbWithContext(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx)
+}
+
+// LoadInstruments reads the instrument master of the day, it is a
+// riskrule.InstrumentSource.
+func (r *InstrumentSQLRepo) LoadInstruments(ctx context.Context) ([]*instrument.Instrument, error) {
+	var records []*instrument.Instrument
+	return records, r.dbWithContext(ctx).Table("instruments").Find(&records).Error
+}
@@ -3,12 +3,41 @@ package repo
 import (
 	"context"
 
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/ledger"
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
 )
 
-type IOrder interface{}
+type IOrder interface {
+	BulkUpsert(ctx context.Context, records []*model.OrderRecord) error
+	GetByOrderID(ctx context.Context, orderID string) (*model.OrderRecord, error)
+	ListByStatus(ctx context.Context, statuses ...model.OrderStatus) ([]*model.OrderRecord, error)
+	ListByAccount(ctx context.Context, account string) ([]*model.OrderRecord, error)
+	ListBySymbol(ctx context.Context, symbol string) ([]*model.OrderRecord, error)
+}
 
 type IOrderEvent interface {
 	Create(ctx context.Context, record *model.OrderEvent) (*model.OrderEvent, error)
 	BulkCreate(ctx context.Context, records []*model.OrderEvent) ([]*model.OrderEvent, error)
+	LoadEvents(ctx context.Context) ([]*model.OrderEvent, error)
+}
+
+type IConfigEvent interface {
+	Create(ctx context.Context, ev *model.ConfigEvent) error
+	Latest(ctx context.Context, node int64) (*model.ConfigEvent, error)
+}
+
+type IAccountBalance interface {
+	ledger.Store
+	ResetReservations(ctx context.Context) error
+}
+
+type IAccountPosition interface {
+	position.Store
+	ResetLocks(ctx context.Context) error
+}
+
+type IInstrument interface {
+	LoadInstruments(ctx context.Context) ([]*instrument.Instrument, error)
 }
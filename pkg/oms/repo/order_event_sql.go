@@ -5,8 +5,14 @@ import (
 
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var eventIDConflict = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "event_id"}},
+	DoNothing: true,
+}
+
 type OrderEventSQLRepo struct {
 	db *gorm.DB
 }
@@ -21,11 +27,19 @@ func (s *OrderEventSQLRepo) dbWithContext(ctx context.Context) *gorm.DB {
 	return s.db.WithContext(ctx)
 }
 
+// Create and BulkCreate skip events already stored, a redelivered event
+// leaves one row.
 func (r *OrderEventSQLRepo) Create(ctx context.Context, record *model.OrderEvent) (*model.OrderEvent, error) {
-	return record, r.dbWithContext(ctx).Create(record).Error
+	return record, r.dbWithContext(ctx).Clauses(eventIDConflict).Create(record).Error
 }
 
 func (r *OrderEventSQLRepo) BulkCreate(ctx context.Context, records []*model.OrderEvent) ([]*model.OrderEvent, error) {
-	return records, r.dbWithContext(ctx).Create(records).Error
+	return records, r.dbWithContext(ctx).Clauses(eventIDConflict).Create(records).Error
+}
 
+// LoadEvents returns every order event in insert order, recovery sorts them
+// by ExecID.
+func (r *OrderEventSQLRepo) LoadEvents(ctx context.Context) ([]*model.OrderEvent, error) {
+	var records []*model.OrderEvent
+	return records, r.dbWithContext(ctx).Order("id").Find(&records).Error
 }
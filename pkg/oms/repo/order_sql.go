@@ -3,9 +3,20 @@ package repo
 import (
 	"context"
 
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// orderColumns are rewritten by a newer event of the order.
+var orderColumns = []string{
+	"gateway_id", "orig_gateway_id", "symbol", "security_id", "exchange", "side", "type",
+	"time_in_force", "price", "stop_price", "quantity", "account", "transact_time", "board",
+	"session_id", "list_id", "quote_id", "exec_id", "last_exec_id", "status", "exec_type",
+	"cum_quantity", "leaves_quantity", "last_quantity", "last_price", "avg_price", "last_update",
+	"seq", "updated_at",
+}
+
 type OrderSQLRepo struct {
 	db *gorm.DB
 }
@@ -19,3 +30,39 @@ func NewOrderSQLRepo(db *gorm.DB) *OrderSQLRepo {
 func (s *OrderSQLRepo) dbWithContext(ctx context.Context) *gorm.DB {
 	return s.db.WithContext(ctx)
 }
+
+// BulkUpsert writes each record unless the row already holds the same or a
+// later event of the order, so redelivered and late events are no-ops. An
+// order must appear once in records.
+func (r *OrderSQLRepo) BulkUpsert(ctx context.Context, records []*model.OrderRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return r.dbWithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "order_id"}},
+		DoUpdates: clause.AssignmentColumns(orderColumns),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: `"orders"."seq" < "excluded"."seq"`},
+		}},
+	}).Create(records).Error
+}
+
+func (r *OrderSQLRepo) GetByOrderID(ctx context.Context, orderID string) (*model.OrderRecord, error) {
+	var record model.OrderRecord
+	return &record, r.dbWithContext(ctx).Where("order_id = ?", orderID).Take(&record).Error
+}
+
+func (r *OrderSQLRepo) ListByStatus(ctx context.Context, statuses ...model.OrderStatus) ([]*model.OrderRecord, error) {
+	var records []*model.OrderRecord
+	return records, r.dbWithContext(ctx).Where("status IN ?", statuses).Order("id").Find(&records).Error
+}
+
+func (r *OrderSQLRepo) ListByAccount(ctx context.Context, account string) ([]*model.OrderRecord, error) {
+	var records []*model.OrderRecord
+	return records, r.dbWithContext(ctx).Where("account = ?", account).Order("id").Find(&records).Error
+}
+
+func (r *OrderSQLRepo) ListBySymbol(ctx context.Context, symbol string) ([]*model.OrderRecord, error) {
+	var records []*model.OrderRecord
+	return records, r.dbWithContext(ctx).Where("symbol = ?", symbol).Order("id").Find(&records).Error
+}
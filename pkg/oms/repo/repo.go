@@ -7,6 +7,11 @@ import (
 type IRepo interface {
 	Order() IOrder
 	OrderEvent() IOrderEvent
+	ConfigEvent() IConfigEvent
+	// AccountBalance and AccountPosition reserve and lock for engine node.
+	AccountBalance(node int64) IAccountBalance
+	AccountPosition(node int64) IAccountPosition
+	Instrument() IInstrument
 }
 
 type Repo struct {
@@ -26,3 +31,19 @@ func (r *Repo) Order() IOrder {
 func (r *Repo) OrderEvent() IOrderEvent {
 	return NewOrderEventSQLRepo(r.omsDB)
 }
+
+func (r *Repo) ConfigEvent() IConfigEvent {
+	return NewConfigEventSQLRepo(r.omsDB)
+}
+
+func (r *Repo) AccountBalance(node int64) IAccountBalance {
+	return NewAccountBalanceSQLRepo(r.omsDB, node)
+}
+
+func (r *Repo) AccountPosition(node int64) IAccountPosition {
+	return NewAccountPositionSQLRepo(r.omsDB, node)
+}
+
+func (r *Repo) Instrument() IInstrument {
+	return NewInstrumentSQLRepo(r.omsDB)
+}
@@ -0,0 +1,107 @@
+package oms
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/ledger"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
+	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
+)
+
+// preTrade runs the risk chain on a new order then reserves its cost or
+// locks the quantity it sells.
+func (s *OMS) preTrade(order *model.Order) error {
+	if err := s.risk.Check(order); err != nil {
+		return err
+	}
+
+	if err := cashReject(s.ledger.Reserve(order)); err != nil {
+		return err
+	}
+
+	switch err := s.positions.Lock(order); err {
+	case nil:
+		return nil
+	case position.ErrShortSellNotAllowed:
+		return riskrule.Reject(model.RejectReasonBrokerOption, err.Error())
+	default:
+		return riskrule.Reject(model.RejectReasonOrderExceedsLimit, err.Error())
+	}
+}
+
+// cashReject gives the reject of a ledger error.
+func cashReject(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case ledger.ErrUnknownAccount:
+		return riskrule.Reject(model.RejectReasonUnknownAccount, err.Error())
+	default:
+		return riskrule.Reject(model.RejectReasonOrderExceedsLimit, err.Error())
+	}
+}
+
+// entryRejectReasons gives the reason reported for the entry errors of the
+// OMS and the refusals of cancel and replace requests, other errors are
+// reported with reason Other.
+var entryRejectReasons = map[error]model.OrderRejectReason{
+	errDuplicateOrder:       model.RejectReasonDuplicateOrder,
+	errGatewayIDNotFound:    model.RejectReasonUnknownOrder,
+	errInvalidOrderStatus:   model.RejectReasonTooLateToEnter,
+	errUnknownSymbol:        model.RejectReasonUnknownSymbol,
+	errUnsupportedOrderType: model.RejectReasonUnsupportedOrder,
+	errUnsupportedSide:      model.RejectReasonUnsupportedOrder,
+	errInvalidQuantity:      model.RejectReasonIncorrectQuantity,
+	errInvalidBoardLot:      model.RejectReasonIncorrectQuantity,
+	errInvalidStopPrice:     model.RejectReasonUnsupportedOrder,
+	errInvalidOddLotOrder:   model.RejectReasonUnsupportedOrder,
+	errInvalidHiddenOrder:   model.RejectReasonUnsupportedOrder,
+	errInvalidPegOrder:      model.RejectReasonUnsupportedOrder,
+	errInvalidOrderList:     model.RejectReasonUnsupportedOrder,
+	errInvalidPutThrough:    model.RejectReasonUnsupportedOrder,
+	errPriceOutOfBand:       model.RejectReasonPriceExceedsBand,
+}
+
+// rejectReason returns the reason and text reported for err.
+func rejectReason(err error) (model.OrderRejectReason, string) {
+	var rejectErr *riskrule.RejectError
+	if errors.As(err, &rejectErr) {
+		return rejectErr.Reason, rejectErr.Text
+	}
+	if reason, ok := entryRejectReasons[err]; ok {
+		return reason, err.Error()
+	}
+	return model.RejectReasonOther, err.Error()
+}
+
+// rejectOrder ends a new order refused before reaching the book and reports
+// it as Rejected.
+func (s *OMS) rejectOrder(ctx context.Context, order *model.Order, err error) {
+	reason, text := rejectReason(err)
+	order.UpdateReject(reason, text)
+	s.AddOrderToMap(order)
+	s.reportOrder(ctx, order)
+}
+
+// rejectCancel answers a cancel or replace request that is not applied, the
+// order itself is unchanged.
+func (s *OMS) rejectCancel(ctx context.Context, order *model.Order, gatewayID, origGatewayID string, responseTo model.CancelRejectResponseTo, err error) {
+	reason, text := rejectReason(err)
+	s.orderGateway.OnOrderReport(ctx, model.CancelReject{
+		OrderID:       order.OrderID,
+		GatewayID:     gatewayID,
+		OrigGatewayID: origGatewayID,
+		Account:       order.Account,
+		Status:        order.Status,
+		ResponseTo:    responseTo,
+		Reason:        reason,
+		Text:          text,
+	})
+}
+
+// RiskStats returns the counters of every pre-trade risk rule.
+func (s *OMS) RiskStats() []riskrule.RuleStats {
+	return s.risk.Stats()
+}
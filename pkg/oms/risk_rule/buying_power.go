@@ -0,0 +1,39 @@
+package riskrule
+
+import (
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+// CashLedger is the account cash seen by BuyingPowerRule (see ledger.Ledger).
+type CashLedger interface {
+	Available(account string) (decimal.Decimal, bool)
+	// Required returns the cash order needs beyond what it already reserves.
+	Required(order *model.Order) decimal.Decimal
+}
+
+// BuyingPowerRule rejects buy orders costing more than the available cash of
+// the account.
+type BuyingPowerRule struct {
+	cash CashLedger
+}
+
+func NewBuyingPowerRule(cash CashLedger) *BuyingPowerRule {
+	return &BuyingPowerRule{cash: cash}
+}
+
+func (r *BuyingPowerRule) Check(order *model.Order) error {
+	if order.Side != model.OrderSideBuy {
+		return nil
+	}
+
+	available, ok := r.cash.Available(order.Account)
+	if !ok {
+		return Reject(model.RejectReasonUnknownAccount, "unknown account")
+	}
+	if r.cash.Required(order).GreaterThan(available) {
+		return Reject(model.RejectReasonOrderExceedsLimit, "insufficient buying power")
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package riskrule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+// Chain runs its rules in order before an order reaches the book, the first
+// failing rule rejects the order. Every rule keeps its own check, reject and
+// timing counters.
+type Chain struct {
+	rules atomic.Pointer[[]*chainRule]
+	// float64 bits of the price_scale of the limit price config
+	priceScale atomic.Uint64
+
+	// background work of the current rules, restarted by Replace
+	mu   sync.Mutex
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+type chainRule struct {
+	name string
+	rule RiskRule
+
+	checks  atomic.Int64
+	rejects atomic.Int64
+	nanos   atomic.Int64
+}
+
+// RuleStats is a snapshot of the counters of one rule.
+type RuleStats struct {
+	Name    string
+	Checks  int64
+	Rejects int64
+	Total   time.Duration // time spent in Check
+}
+
+// NewChain builds the rules listed in cfg, a nil cfg gives an empty chain.
+func NewChain(cfg *Config, deps *Deps) (*Chain, error) {
+	c := &Chain{}
+	if cfg == nil {
+		return c, nil
+	}
+	if deps == nil {
+		deps = &Deps{}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c.priceScale.Store(math.Float64bits(cfg.LimitPrice.Scale()))
+	readFile := deps.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+
+	for _, name := range cfg.Rules {
+		var rule RiskRule
+		var err error
+		switch name {
+		case RuleTickSize:
+			var data []byte
+			if data, err = readFile(cfg.TickSizeFile); err == nil {
+				rule, err = NewTickSizeRuleFromJSON(data, deps.InstrumentMaster)
+			}
+		case RuleBuyingPower:
+			if deps.Cash == nil {
+				err = errMissingDependency(name)
+				break
+			}
+			rule = NewBuyingPowerRule(deps.Cash)
+		case RuleHoldings:
+			if deps.Positions == nil {
+				err = errMissingDependency(name)
+				break
+			}
+			rule = NewHoldingsRule(deps.Positions)
+		case RuleOrderLimit:
+			rule = NewOrderLimitRule(cfg.OrderLimits)
+		case RuleLimitPrice:
+			source := deps.Instruments
+			if source == nil && cfg.LimitPrice != nil && cfg.LimitPrice.InstrumentFile != "" {
+				source = instrumentFile{path: cfg.LimitPrice.InstrumentFile, readFile: readFile}
+			}
+			if source == nil {
+				err = errMissingDependency(name)
+				break
+			}
+			rule, err = NewLimitPriceRule(context.Background(), cfg.LimitPrice, source)
+		default:
+			err = fmt.Errorf("unknown risk rule %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Add(name, rule)
+	}
+
+	return c, nil
+}
+
+func errMissingDependency(name string) error {
+	return fmt.Errorf("risk rule %q: missing dependency", name)
+}
+
+func (c *Chain) loadRules() []*chainRule {
+	if rules := c.rules.Load(); rules != nil {
+		return *rules
+	}
+	return nil
+}
+
+// Add appends rule at the end of the chain, it is meant for building a
+// chain, use Replace on a chain in use.
+func (c *Chain) Add(name string, rule RiskRule) {
+	rules := append(c.loadRules(), &chainRule{name: name, rule: rule})
+	c.rules.Store(&rules)
+}
+
+// Replace swaps the rules of c for the rules of next in one step. Checks in
+// flight finish on the previous rules and the rule counters start over.
+func (c *Chain) Replace(next *Chain) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules.Store(next.rules.Load())
+	c.priceScale.Store(next.priceScale.Load())
+	if c.ctx != nil {
+		c.stop()
+		c.startRules()
+	}
+}
+
+// PriceScale converts market data prices, such as the ceil, floor and ref
+// prices of the instrument master, to order prices. It is the price_scale of
+// the limit price config, 1 when none is set.
+func (c *Chain) PriceScale() float64 {
+	if c == nil {
+		return 1
+	}
+	if scale := math.Float64frombits(c.priceScale.Load()); scale != 0 {
+		return scale
+	}
+	return 1
+}
+
+// SetPriceSource gives prices to the rules comparing orders with the market,
+// the OMS sets itself once built.
+func (c *Chain) SetPriceSource(prices PriceSource) {
+	if c == nil {
+		return
+	}
+
+	for _, r := range c.loadRules() {
+		if rule, ok := r.rule.(interface{ setPriceSource(PriceSource) }); ok {
+			rule.setPriceSource(prices)
+		}
+	}
+}
+
+// Start runs the background work of the rules, such as the daily refresh of
+// the price limits, until ctx is done.
+func (c *Chain) Start(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ctx = ctx
+	c.startRules()
+}
+
+func (c *Chain) startRules() {
+	ctx, stop := context.WithCancel(c.ctx)
+	c.stop = stop
+	for _, r := range c.loadRules() {
+		if rule, ok := r.rule.(interface{ start(context.Context) }); ok {
+			rule.start(ctx)
+		}
+	}
+}
+
+// Check returns a *RejectError naming the first rule refusing order, rule
+// errors of other types are rejected with reason Other.
+func (c *Chain) Check(order *model.Order) error {
+	if c == nil {
+		return nil
+	}
+
+	for _, r := range c.loadRules() {
+		start := time.Now()
+		err := r.rule.Check(order)
+		r.nanos.Add(int64(time.Since(start)))
+		r.checks.Add(1)
+		if err == nil {
+			continue
+		}
+
+		r.rejects.Add(1)
+		var rejectErr *RejectError
+		if errors.As(err, &rejectErr) {
+			return &RejectError{Rule: r.name, Reason: rejectErr.Reason, Text: rejectErr.Text}
+		}
+		return &RejectError{Rule: r.name, Reason: model.RejectReasonOther, Text: err.Error()}
+	}
+
+	return nil
+}
+
+// BookPrice moves a price set by the book, such as the price of a pegged
+// order, onto the tick of symbol and then into its ceil/floor band. It
+// returns false when the instrument takes no order.
+func (c *Chain) BookPrice(symbol string, side model.OrderSide, price decimal.Decimal) (decimal.Decimal, bool) {
+	if c == nil {
+		return price, true
+	}
+
+	rules := c.loadRules()
+	for _, r := range rules {
+		if rule, ok := r.rule.(interface {
+			roundPrice(string, model.OrderSide, decimal.Decimal) decimal.Decimal
+		}); ok {
+			price = rule.roundPrice(symbol, side, price)
+		}
+	}
+	for _, r := range rules {
+		if rule, ok := r.rule.(interface {
+			clampPrice(string, decimal.Decimal) (decimal.Decimal, bool)
+		}); ok {
+			var valid bool
+			if price, valid = rule.clampPrice(symbol, price); !valid {
+				return price, false
+			}
+		}
+	}
+	return price, true
+}
+
+// Stats returns the counters of every rule in chain order.
+func (c *Chain) Stats() []RuleStats {
+	if c == nil {
+		return nil
+	}
+
+	rules := c.loadRules()
+	stats := make([]RuleStats, len(rules))
+	for i, r := range rules {
+		stats[i] = RuleStats{
+			Name:    r.name,
+			Checks:  r.checks.Load(),
+			Rejects: r.rejects.Load(),
+			Total:   time.Duration(r.nanos.Load()),
+		}
+	}
+	return stats
+}
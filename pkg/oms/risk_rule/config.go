@@ -0,0 +1,85 @@
+package riskrule
+
+import (
+	"fmt"
+
+	"github.com/joripage/orderbook-dev/pkg/misc"
+)
+
+const (
+	RuleTickSize    = "tick_size"
+	RuleBuyingPower = "buying_power"
+	RuleHoldings    = "holdings"
+	RuleOrderLimit  = "order_limit"
+	RuleLimitPrice  = "limit_price"
+)
+
+// Config describes the pre-trade risk chain.
+type Config struct {
+	// Rules lists the rules of the chain in check order.
+	Rules []string `yaml:"rules"`
+
+	TickSizeFile string             `yaml:"tick_size_file"`
+	OrderLimits  *OrderLimitsConfig `yaml:"order_limits"`
+	LimitPrice   *LimitPriceConfig  `yaml:"limit_price"`
+}
+
+// Deps holds the OMS state some rules read, a rule listed in Config fails to
+// build when its dependency is nil.
+type Deps struct {
+	Cash      CashLedger
+	Positions PositionBook
+	// Instruments overrides LimitPrice.InstrumentFile, e.g. the instruments
+	// table.
+	Instruments InstrumentSource
+	// InstrumentMaster gives the exchange and type of a symbol to the tick
+	// size rule, nil falls back to the order exchange.
+	InstrumentMaster InstrumentLookup
+	// ReadFile reads the files named in Config, nil reads from disk.
+	ReadFile func(path string) ([]byte, error)
+}
+
+// LoadConfig reads a risk chain config from a YAML file (see
+// misc.LoadYAML).
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if err := misc.LoadYAML(path, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// ParseConfig reads a risk chain config from the content of a YAML file.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := misc.ParseYAML(data, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate rejects values no rule can apply, NewChain validates cfg before
+// building any rule.
+func (cfg *Config) Validate() error {
+	if l := cfg.OrderLimits; l != nil {
+		limits := map[string]OrderLimit{"firm": l.Firm}
+		for account, limit := range l.Accounts {
+			limits["account "+account] = limit
+		}
+		for symbol, limit := range l.Instruments {
+			limits["instrument "+symbol] = limit
+		}
+		for name, limit := range limits {
+			if limit.MaxQuantity < 0 || limit.MaxNotional < 0 || limit.MaxPriceDeviation < 0 {
+				return fmt.Errorf("order limit %s: negative limit", name)
+			}
+		}
+	}
+	if l := cfg.LimitPrice; l != nil && l.PriceScale < 0 {
+		return fmt.Errorf("limit price: negative price_scale")
+	}
+
+	return nil
+}
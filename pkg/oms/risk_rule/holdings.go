@@ -0,0 +1,41 @@
+package riskrule
+
+import "github.com/joripage/orderbook-dev/pkg/oms/model"
+
+// PositionBook is the account holdings seen by HoldingsRule (see
+// position.Positions).
+type PositionBook interface {
+	Available(account, symbol string) int64
+	// Required returns the quantity order must lock beyond what it already
+	// locks.
+	Required(order *model.Order) int64
+	CanShortSell(account string) bool
+}
+
+// HoldingsRule rejects sell orders beyond the available holdings, short sells
+// are accepted for approved accounts only.
+type HoldingsRule struct {
+	positions PositionBook
+}
+
+func NewHoldingsRule(positions PositionBook) *HoldingsRule {
+	return &HoldingsRule{positions: positions}
+}
+
+func (r *HoldingsRule) Check(order *model.Order) error {
+	if order.Side != model.OrderSideSell {
+		return nil
+	}
+
+	if order.ShortSell {
+		if !r.positions.CanShortSell(order.Account) {
+			return Reject(model.RejectReasonBrokerOption, "short sell not allowed")
+		}
+		return nil
+	}
+	if r.positions.Required(order) > r.positions.Available(order.Account, order.Symbol) {
+		return Reject(model.RejectReasonOrderExceedsLimit, "insufficient holdings")
+	}
+
+	return nil
+}
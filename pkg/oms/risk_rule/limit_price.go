@@ -1,23 +1,210 @@
 package riskrule
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
 )
 
+const noHalt = "No_Halt"
+
+// InstrumentSource loads the instrument master with the ceil, floor and ref
+// prices of the day, from config/market_data.json or the instruments table.
+type InstrumentSource interface {
+	LoadInstruments(ctx context.Context) ([]*instrument.Instrument, error)
+}
+
+// InstrumentFile is an InstrumentSource reading a market data JSON file.
+type InstrumentFile string
+
+func (f InstrumentFile) LoadInstruments(ctx context.Context) ([]*instrument.Instrument, error) {
+	return instrument.LoadFile(string(f))
+}
+
+// instrumentFile reads the file through the ReadFile of Deps.
+type instrumentFile struct {
+	path     string
+	readFile func(path string) ([]byte, error)
+}
+
+func (f instrumentFile) LoadInstruments(ctx context.Context) ([]*instrument.Instrument, error) {
+	data, err := f.readFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return instrument.Parse(data)
+}
+
+type LimitPriceConfig struct {
+	// InstrumentFile is read when no InstrumentSource is given in Deps.
+	InstrumentFile string `yaml:"instrument_file"`
+	// PriceScale converts market data prices to order prices, market data
+	// is quoted in thousand VND so 1000 for VND orders. Zero means 1.
+	PriceScale float64 `yaml:"price_scale"`
+	// RefreshAt is the local time "15:04" new prices are published at,
+	// empty disables the daily refresh.
+	RefreshAt string `yaml:"refresh_at"`
+}
+
+// Scale returns the PriceScale in effect.
+func (cfg *LimitPriceConfig) Scale() float64 {
+	if cfg == nil || cfg.PriceScale == 0 {
+		return 1
+	}
+	return cfg.PriceScale
+}
+
 type limitPrice struct {
-	ceil  float64
-	floor float64
+	ceil      float64
+	floor     float64
+	ref       float64
+	suspended bool
 }
 
+// LimitPriceRule rejects orders priced outside the ceil/floor band of the
+// day, and orders on unknown or suspended instruments.
 type LimitPriceRule struct {
+	cfg    *LimitPriceConfig
+	source InstrumentSource
+
+	mu     sync.RWMutex
 	prices map[string]*limitPrice
 }
 
+// NewLimitPriceRule loads the prices of source once, Start refreshes them
+// every day at cfg.RefreshAt.
+func NewLimitPriceRule(ctx context.Context, cfg *LimitPriceConfig, source InstrumentSource) (*LimitPriceRule, error) {
+	if cfg == nil {
+		cfg = &LimitPriceConfig{}
+	}
+	if cfg.RefreshAt != "" {
+		if _, err := time.Parse("15:04", cfg.RefreshAt); err != nil {
+			return nil, fmt.Errorf("limit price refresh_at: %w", err)
+		}
+	}
+
+	r := &LimitPriceRule{cfg: cfg, source: source}
+	if err := r.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Refresh reloads the prices from the source, the previous prices are kept
+// when the load fails.
+func (r *LimitPriceRule) Refresh(ctx context.Context) error {
+	instruments, err := r.source.LoadInstruments(ctx)
+	if err != nil {
+		return err
+	}
+	r.Update(instruments)
+	return nil
+}
+
+// Update replaces the prices with the ones of instruments.
+func (r *LimitPriceRule) Update(instruments []*instrument.Instrument) {
+	scale := r.cfg.Scale()
+
+	prices := make(map[string]*limitPrice, len(instruments))
+	for _, i := range instruments {
+		prices[i.Symbol] = &limitPrice{
+			ceil:      i.Ceil * scale,
+			floor:     i.Floor * scale,
+			ref:       i.Ref * scale,
+			suspended: isSuspended(i),
+		}
+	}
+
+	r.mu.Lock()
+	r.prices = prices
+	r.mu.Unlock()
+}
+
+func isSuspended(i *instrument.Instrument) bool {
+	return i.IsSuspended ||
+		(i.HaltState != "" && i.HaltState != noHalt) ||
+		(i.MarketHaltState != "" && i.MarketHaltState != noHalt)
+}
+
 func (r *LimitPriceRule) Check(order *model.Order) error {
-	if order.Price > r.prices[order.Symbol].ceil || order.Price < r.prices[order.Symbol].floor {
-		return fmt.Errorf("price limit violation")
+	r.mu.RLock()
+	price, ok := r.prices[order.Symbol]
+	r.mu.RUnlock()
+
+	if !ok {
+		return Reject(model.RejectReasonUnknownSymbol, "unknown symbol")
+	}
+	if price.suspended {
+		return Reject(model.RejectReasonExchangeClosed, "instrument suspended")
+	}
+	// no band published
+	if price.ceil == 0 && price.floor == 0 {
+		return nil
+	}
+	// market orders carry no price, a stop price is checked like a limit
+	for _, p := range []float64{order.Price.InexactFloat64(), order.StopPrice.InexactFloat64()} {
+		if p > 0 && (p > price.ceil || p < price.floor) {
+			return Reject(model.RejectReasonPriceExceedsBand, "price limit violation")
+		}
 	}
 	return nil
 }
+
+// clampPrice moves a price set by the book into the ceil/floor band of
+// symbol, false when the instrument is unknown or suspended.
+func (r *LimitPriceRule) clampPrice(symbol string, price decimal.Decimal) (decimal.Decimal, bool) {
+	r.mu.RLock()
+	limit, ok := r.prices[symbol]
+	r.mu.RUnlock()
+
+	if !ok || limit.suspended {
+		return price, false
+	}
+	if limit.ceil == 0 && limit.floor == 0 {
+		return price, true
+	}
+	if ceil := decimal.NewFromFloat(limit.ceil); price.GreaterThan(ceil) {
+		return ceil, true
+	}
+	if floor := decimal.NewFromFloat(limit.floor); price.LessThan(floor) {
+		return floor, true
+	}
+	return price, true
+}
+
+func (r *LimitPriceRule) start(ctx context.Context) {
+	if r.cfg.RefreshAt == "" {
+		return
+	}
+
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextRefresh(time.Now(), r.cfg.RefreshAt)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if err := r.Refresh(ctx); err != nil {
+				log.Printf("limit price refresh err=%v", err)
+			}
+		}
+	}()
+}
+
+// nextRefresh returns the first time after now at the clock time at.
+func nextRefresh(now time.Time, at string) time.Time {
+	t, _ := time.Parse("15:04", at)
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,80 @@
+package riskrule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+type staticInstruments []*instrument.Instrument
+
+func (s *staticInstruments) LoadInstruments(ctx context.Context) ([]*instrument.Instrument, error) {
+	return *s, nil
+}
+
+func TestLimitPriceRule(t *testing.T) {
+	source := &staticInstruments{
+		{Symbol: "HAG", Ceil: 17, Ref: 15.9, Floor: 14.8, HaltState: "No_Halt"},
+		{Symbol: "SUS", Ceil: 11, Ref: 10, Floor: 9, IsSuspended: true},
+		{Symbol: "HLT", Ceil: 11, Ref: 10, Floor: 9, HaltState: "Halt"},
+	}
+	rule, err := NewLimitPriceRule(context.Background(), &LimitPriceConfig{PriceScale: 1000}, source)
+	if err != nil {
+		t.Fatalf("new rule err=%v", err)
+	}
+
+	tests := []struct {
+		name   string
+		order  model.Order
+		reason model.OrderRejectReason
+	}{
+		{"inside band", model.Order{Symbol: "HAG", Price: decimal.RequireFromString("15900")}, ""},
+		{"at ceil", model.Order{Symbol: "HAG", Price: decimal.RequireFromString("17000")}, ""},
+		{"above ceil", model.Order{Symbol: "HAG", Price: decimal.RequireFromString("17100")}, model.RejectReasonPriceExceedsBand},
+		{"below floor", model.Order{Symbol: "HAG", Price: decimal.RequireFromString("14700")}, model.RejectReasonPriceExceedsBand},
+		{"market order", model.Order{Symbol: "HAG", Type: model.OrderTypeMarket}, ""},
+		{"stop outside band", model.Order{Symbol: "HAG", StopPrice: decimal.RequireFromString("18000")}, model.RejectReasonPriceExceedsBand},
+		{"unknown symbol", model.Order{Symbol: "XYZ", Price: decimal.RequireFromString("10000")}, model.RejectReasonUnknownSymbol},
+		{"suspended", model.Order{Symbol: "SUS", Price: decimal.RequireFromString("10000")}, model.RejectReasonExchangeClosed},
+		{"halted", model.Order{Symbol: "HLT", Price: decimal.RequireFromString("10000")}, model.RejectReasonExchangeClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rule.Check(&tt.order)
+			var rejectErr *RejectError
+			switch {
+			case tt.reason == "" && err != nil:
+				t.Fatalf("unexpected reject %v", err)
+			case tt.reason != "" && (!errors.As(err, &rejectErr) || rejectErr.Reason != tt.reason):
+				t.Fatalf("expected %s, got %v", tt.reason, err)
+			}
+		})
+	}
+
+	// new prices of the day replace the old band
+	*source = staticInstruments{{Symbol: "HAG", Ceil: 18, Ref: 17, Floor: 16}}
+	if err := rule.Refresh(context.Background()); err != nil {
+		t.Fatalf("refresh err=%v", err)
+	}
+	if err := rule.Check(&model.Order{Symbol: "HAG", Price: decimal.RequireFromString("17500")}); err != nil {
+		t.Fatalf("expected refreshed band, got %v", err)
+	}
+	if err := rule.Check(&model.Order{Symbol: "SUS", Price: decimal.RequireFromString("10000")}); err == nil {
+		t.Fatal("expected symbol dropped by refresh to be unknown")
+	}
+}
+
+func TestNextRefresh(t *testing.T) {
+	now := time.Date(2024, 5, 10, 9, 0, 0, 0, time.Local)
+	if next := nextRefresh(now, "08:30"); !next.Equal(time.Date(2024, 5, 11, 8, 30, 0, 0, time.Local)) {
+		t.Fatalf("expected next day, got %v", next)
+	}
+	if next := nextRefresh(now, "15:00"); !next.Equal(time.Date(2024, 5, 10, 15, 0, 0, 0, time.Local)) {
+		t.Fatalf("expected same day, got %v", next)
+	}
+}
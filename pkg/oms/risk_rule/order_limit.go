@@ -0,0 +1,102 @@
+package riskrule
+
+import (
+	"math"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+// PriceSource gives the price orders are compared with, the last trade
+// price or the reference price before the first trade.
+type PriceSource interface {
+	ReferencePrice(symbol string) (float64, bool)
+}
+
+// OrderLimit caps a single order, a zero field is inherited from the level
+// above (instrument -> account -> firm), zero at firm level means no limit.
+type OrderLimit struct {
+	MaxQuantity int64   `yaml:"max_quantity"`
+	MaxNotional float64 `yaml:"max_notional"`
+	// MaxPriceDeviation is a fraction of the reference price, 0.1 = 10%.
+	MaxPriceDeviation float64 `yaml:"max_price_deviation"`
+}
+
+type OrderLimitsConfig struct {
+	Firm        OrderLimit            `yaml:"firm"`
+	Accounts    map[string]OrderLimit `yaml:"accounts"`
+	Instruments map[string]OrderLimit `yaml:"instruments"`
+}
+
+// OrderLimitRule rejects fat-finger orders: too large a quantity or value, or
+// a price too far from the reference price.
+type OrderLimitRule struct {
+	cfg    *OrderLimitsConfig
+	prices PriceSource
+}
+
+func NewOrderLimitRule(cfg *OrderLimitsConfig) *OrderLimitRule {
+	if cfg == nil {
+		cfg = &OrderLimitsConfig{}
+	}
+	return &OrderLimitRule{cfg: cfg}
+}
+
+func (r *OrderLimitRule) setPriceSource(prices PriceSource) {
+	r.prices = prices
+}
+
+// Limit returns the limit applied to account trading symbol.
+func (r *OrderLimitRule) Limit(account, symbol string) OrderLimit {
+	limit := r.cfg.Firm
+	if l, ok := r.cfg.Accounts[account]; ok {
+		limit = inherit(l, limit)
+	}
+	if l, ok := r.cfg.Instruments[symbol]; ok {
+		limit = inherit(l, limit)
+	}
+	return limit
+}
+
+func inherit(l, parent OrderLimit) OrderLimit {
+	if l.MaxQuantity == 0 {
+		l.MaxQuantity = parent.MaxQuantity
+	}
+	if l.MaxNotional == 0 {
+		l.MaxNotional = parent.MaxNotional
+	}
+	if l.MaxPriceDeviation == 0 {
+		l.MaxPriceDeviation = parent.MaxPriceDeviation
+	}
+	return l
+}
+
+func (r *OrderLimitRule) Check(order *model.Order) error {
+	limit := r.Limit(order.Account, order.Symbol)
+
+	if limit.MaxQuantity > 0 && order.Quantity > limit.MaxQuantity {
+		return Reject(model.RejectReasonIncorrectQuantity, "order quantity exceeds limit")
+	}
+
+	var refPrice float64
+	var hasRef bool
+	if r.prices != nil {
+		refPrice, hasRef = r.prices.ReferencePrice(order.Symbol)
+		hasRef = hasRef && refPrice > 0
+	}
+
+	// orders without a limit price are valued at the reference price
+	price := order.Price.InexactFloat64()
+	if order.Type == model.OrderTypeMarket || price <= 0 {
+		price = refPrice
+	}
+	if limit.MaxNotional > 0 && price*float64(order.Quantity) > limit.MaxNotional {
+		return Reject(model.RejectReasonOrderExceedsLimit, "order value exceeds limit")
+	}
+
+	if limit.MaxPriceDeviation > 0 && hasRef && order.Type != model.OrderTypeMarket && order.Price.IsPositive() &&
+		math.Abs(order.Price.InexactFloat64()-refPrice)/refPrice > limit.MaxPriceDeviation {
+		return Reject(model.RejectReasonPriceExceedsBand, "price deviates from reference price")
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package riskrule
+
+import (
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+type fixedPrices map[string]float64
+
+func (p fixedPrices) ReferencePrice(symbol string) (float64, bool) {
+	price, ok := p[symbol]
+	return price, ok
+}
+
+func TestOrderLimitInheritance(t *testing.T) {
+	rule := NewOrderLimitRule(&OrderLimitsConfig{
+		Firm: OrderLimit{MaxQuantity: 1000, MaxNotional: 50_000, MaxPriceDeviation: 0.1},
+		Accounts: map[string]OrderLimit{
+			"BIG": {MaxQuantity: 5000, MaxNotional: 1_000_000},
+		},
+		Instruments: map[string]OrderLimit{
+			"ILLQ": {MaxPriceDeviation: 0.02},
+		},
+	})
+	rule.setPriceSource(fixedPrices{"TEST": 10, "ILLQ": 100})
+
+	if l := rule.Limit("BIG", "ILLQ"); l.MaxQuantity != 5000 || l.MaxNotional != 1_000_000 || l.MaxPriceDeviation != 0.02 {
+		t.Fatalf("unexpected inherited limit %+v", l)
+	}
+
+	tests := []struct {
+		name    string
+		account string
+		symbol  string
+		price   float64
+		qty     int64
+		reason  model.OrderRejectReason
+	}{
+		{"within limits", "A1", "TEST", 10, 1000, ""},
+		{"quantity", "A1", "TEST", 10, 1001, model.RejectReasonIncorrectQuantity},
+		{"account quantity", "BIG", "TEST", 10, 4000, ""},
+		{"notional", "BIG", "TEST", 10.5, 5000, ""},
+		{"firm notional", "A1", "ILLQ", 100, 600, model.RejectReasonOrderExceedsLimit},
+		{"deviation", "A1", "TEST", 11.5, 10, model.RejectReasonPriceExceedsBand},
+		{"instrument deviation", "A1", "ILLQ", 103, 10, model.RejectReasonPriceExceedsBand},
+		{"no reference price", "A1", "NEW", 1, 10, ""},
+	}
+	for _, tt := range tests {
+		err := rule.Check(&model.Order{
+			Account:  tt.account,
+			Symbol:   tt.symbol,
+			Type:     model.OrderTypeLimit,
+			Price:    decimal.NewFromFloat(tt.price),
+			Quantity: tt.qty,
+		})
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected err=%v", tt.name, err)
+			}
+			continue
+		}
+		rejectErr, ok := err.(*RejectError)
+		if !ok || rejectErr.Reason != tt.reason {
+			t.Errorf("%s: expected %s reject, got %v", tt.name, tt.reason, err)
+		}
+	}
+}
@@ -2,6 +2,24 @@ package riskrule
 
 import "github.com/joripage/orderbook-dev/pkg/oms/model"
 
+// RiskRule checks an order before it reaches the book. A rule may normalize
+// the order it accepts, e.g. round its price to the tick.
 type RiskRule interface {
 	Check(order *model.Order) error
 }
+
+// RejectError is returned by a rule refusing an order, Reason and Text are
+// reported to the client (OrdRejReason / Text).
+type RejectError struct {
+	Rule   string
+	Reason model.OrderRejectReason
+	Text   string
+}
+
+func (e *RejectError) Error() string {
+	return e.Text
+}
+
+func Reject(reason model.OrderRejectReason, text string) error {
+	return &RejectError{Reason: reason, Text: text}
+}
@@ -5,49 +5,183 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
 )
 
-type tickSizeConfig struct {
-	MaxPrice int64 `json:"maxPrice"` // 0 = no limit
-	Step     int64 `json:"step"`
+// defaultTickType is the table of the instrument types an exchange has no
+// table for.
+const defaultTickType = "default"
+
+// InstrumentLookup resolves an order symbol in the instrument master,
+// *instrument.Store is one.
+type InstrumentLookup interface {
+	Get(symbol string) (*instrument.Instrument, bool)
+}
+
+// TickSizeStep applies to prices up to MaxPrice, zero MaxPrice has no bound.
+type TickSizeStep struct {
+	MaxPrice decimal.Decimal `json:"maxPrice"`
+	Step     decimal.Decimal `json:"step"`
+}
+
+// TickSizeTable lists the steps by ascending MaxPrice.
+type TickSizeTable []TickSizeStep
+
+// step returns the tick of price, zero when no step applies.
+func (t TickSizeTable) step(price decimal.Decimal) decimal.Decimal {
+	for _, s := range t {
+		if s.MaxPrice.IsZero() || price.LessThanOrEqual(s.MaxPrice) {
+			return s.Step
+		}
+	}
+	return decimal.Zero
+}
+
+// TickSizeConfig is the content of config/tick_size.json.
+type TickSizeConfig struct {
+	// Aliases maps the exchange of the instrument master to a table,
+	// e.g. HASTC -> HNX.
+	Aliases map[string]string `json:"aliases"`
+	// Exchanges holds the tables of an exchange by instrument type
+	// (Stock, ETF, CoveredWarrant, Bond, ...) with a "default" fallback.
+	Exchanges map[string]map[string]TickSizeTable `json:"exchanges"`
+	// Instruments overrides the exchange table of a symbol.
+	Instruments map[string]TickSizeTable `json:"instruments"`
+	// Round moves an off-tick price to the tick on the passive side (down
+	// for a buy, up for a sell) instead of rejecting the order.
+	Round bool `json:"round"`
 }
 
 // TickSizeRule chứa toàn bộ config cho nhiều symbol
 type TickSizeRule struct {
-	Config map[string][]tickSizeConfig
+	cfg         *TickSizeConfig
+	instruments InstrumentLookup
 }
 
 // Load config từ file JSON
-func NewTickSizeRuleFromFile(path string) (*TickSizeRule, error) {
+func NewTickSizeRuleFromFile(path string, instruments InstrumentLookup) (*TickSizeRule, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var cfg map[string][]tickSizeConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	return NewTickSizeRuleFromJSON(data, instruments)
+}
+
+// NewTickSizeRuleFromJSON parses the content of a tick size file, every
+// step must be positive.
+func NewTickSizeRuleFromJSON(data []byte, instruments InstrumentLookup) (*TickSizeRule, error) {
+	cfg := &TickSizeConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
 
-	return &TickSizeRule{Config: cfg}, nil
+	return NewTickSizeRule(cfg, instruments), nil
 }
 
-func (r *TickSizeRule) Check(order *model.Order) error {
-	rules, ok := r.Config[order.Exchange]
-	if !ok { // no config -> no rule
+func (cfg *TickSizeConfig) validate() error {
+	check := func(name string, table TickSizeTable) error {
+		for _, s := range table {
+			if !s.Step.IsPositive() || s.MaxPrice.IsNegative() {
+				return fmt.Errorf("tick size %s: invalid step %s up to %s", name, s.Step, s.MaxPrice)
+			}
+		}
 		return nil
 	}
-
-	price := int64(order.Price)
-	for _, rule := range rules {
-		if rule.MaxPrice == 0 || price <= rule.MaxPrice {
-			if price%rule.Step != 0 {
-				return fmt.Errorf("invalid tick size")
+	for exchange, tables := range cfg.Exchanges {
+		for stockType, table := range tables {
+			if err := check(exchange+"/"+stockType, table); err != nil {
+				return err
 			}
-			return nil
 		}
 	}
+	for symbol, table := range cfg.Instruments {
+		if err := check(symbol, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewTickSizeRule resolves the exchange and type of an order from
+// instruments, or from the order exchange when instruments is nil or
+// misses the symbol.
+func NewTickSizeRule(cfg *TickSizeConfig, instruments InstrumentLookup) *TickSizeRule {
+	return &TickSizeRule{cfg: cfg, instruments: instruments}
+}
+
+// table returns the ticks of symbol, nil when none is configured.
+func (r *TickSizeRule) table(symbol, exchange string) TickSizeTable {
+	if t, ok := r.cfg.Instruments[symbol]; ok {
+		return t
+	}
+
+	stockType := ""
+	if r.instruments != nil {
+		if i, ok := r.instruments.Get(symbol); ok {
+			exchange, stockType = i.Exchange, i.StockType
+		}
+	}
+	if alias, ok := r.cfg.Aliases[exchange]; ok {
+		exchange = alias
+	}
+	tables, ok := r.cfg.Exchanges[exchange]
+	if !ok { // no config -> no rule
+		return nil
+	}
+	if t, ok := tables[stockType]; ok {
+		return t
+	}
+	return tables[defaultTickType]
+}
+
+func (r *TickSizeRule) Check(order *model.Order) error {
+	table := r.table(order.Symbol, order.Exchange)
+	if table == nil || !order.Price.IsPositive() {
+		return nil
+	}
 
+	price := order.Price
+	step := table.step(price)
+	if step.IsZero() || price.Mod(step).IsZero() {
+		return nil
+	}
+	if !r.cfg.Round {
+		return Reject(model.RejectReasonInvalidPriceIncrement, "invalid tick size")
+	}
+
+	rounded := roundToStep(price, step, order.Side)
+	if !rounded.IsPositive() {
+		return Reject(model.RejectReasonInvalidPriceIncrement, "invalid tick size")
+	}
+	order.Price = rounded
 	return nil
 }
+
+// roundPrice moves a price set by the book onto the tick of symbol, prices
+// the book sets are always rounded whatever cfg.Round says.
+func (r *TickSizeRule) roundPrice(symbol string, side model.OrderSide, price decimal.Decimal) decimal.Decimal {
+	table := r.table(symbol, "")
+	if table == nil {
+		return price
+	}
+	return roundToStep(price, table.step(price), side)
+}
+
+// roundToStep rounds an off-tick price on the passive side, down for a buy
+// and up for a sell.
+func roundToStep(price, step decimal.Decimal, side model.OrderSide) decimal.Decimal {
+	if step.IsZero() || price.Mod(step).IsZero() {
+		return price
+	}
+	rounded := price.Div(step).Floor().Mul(step)
+	if side == model.OrderSideSell {
+		rounded = rounded.Add(step)
+	}
+	return rounded
+}
@@ -0,0 +1,75 @@
+package riskrule
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/shopspring/decimal"
+)
+
+func TestTickSizeRule(t *testing.T) {
+	rule, err := NewTickSizeRuleFromFile("../../../config/tick_size.json", instrument.NewStore([]*instrument.Instrument{
+		{Symbol: "HPG", Exchange: "HOSE", StockType: "Stock"},
+		{Symbol: "E1VFVN30", Exchange: "HOSE", StockType: "ETF"},
+		{Symbol: "SHS", Exchange: "HASTC", StockType: "Stock"},
+		{Symbol: "BOND1", Exchange: "HASTC", StockType: "Bond"},
+	}))
+	if err != nil {
+		t.Fatalf("load err=%v", err)
+	}
+
+	tests := []struct {
+		name   string
+		order  model.Order
+		reject bool
+	}{
+		{"hose stock low band", model.Order{Symbol: "HPG", Price: decimal.RequireFromString("9990")}, false},
+		{"hose stock mid band off tick", model.Order{Symbol: "HPG", Price: decimal.RequireFromString("28010")}, true},
+		{"hose stock mid band", model.Order{Symbol: "HPG", Price: decimal.RequireFromString("28050")}, false},
+		{"hose stock high band off tick", model.Order{Symbol: "HPG", Price: decimal.RequireFromString("50050")}, true},
+		{"hose etf", model.Order{Symbol: "E1VFVN30", Price: decimal.RequireFromString("28010")}, false},
+		{"fractional price", model.Order{Symbol: "E1VFVN30", Price: decimal.RequireFromString("28010.5")}, true},
+		{"hastc alias", model.Order{Symbol: "SHS", Price: decimal.RequireFromString("15150")}, true},
+		{"hnx bond", model.Order{Symbol: "BOND1", Price: decimal.RequireFromString("100001")}, false},
+		{"order exchange fallback", model.Order{Symbol: "XYZ", Exchange: "UPCOM", Price: decimal.RequireFromString("15150")}, true},
+		{"unknown exchange", model.Order{Symbol: "XYZ", Price: decimal.RequireFromString("15151")}, false},
+		{"market order", model.Order{Symbol: "HPG", Type: model.OrderTypeMarket}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rule.Check(&tt.order)
+			var rejectErr *RejectError
+			if tt.reject != (err != nil) {
+				t.Fatalf("reject=%v, got %v", tt.reject, err)
+			}
+			if err != nil && (!errors.As(err, &rejectErr) || rejectErr.Reason != model.RejectReasonInvalidPriceIncrement) {
+				t.Fatalf("unexpected reject %v", err)
+			}
+		})
+	}
+}
+
+func TestTickSizeRuleRounds(t *testing.T) {
+	rule := NewTickSizeRule(&TickSizeConfig{
+		Exchanges: map[string]map[string]TickSizeTable{
+			"HOSE": {defaultTickType: {{Step: decimal.RequireFromString("50")}}},
+		},
+		Instruments: map[string]TickSizeTable{"ODD": {{Step: decimal.RequireFromString("0.5")}}},
+		Round:       true,
+	}, nil)
+
+	buy := &model.Order{Symbol: "HPG", Exchange: "HOSE", Side: model.OrderSideBuy, Price: decimal.RequireFromString("28020")}
+	sell := &model.Order{Symbol: "HPG", Exchange: "HOSE", Side: model.OrderSideSell, Price: decimal.RequireFromString("28020")}
+	odd := &model.Order{Symbol: "ODD", Side: model.OrderSideBuy, Price: decimal.RequireFromString("10.7")}
+	for _, order := range []*model.Order{buy, sell, odd} {
+		if err := rule.Check(order); err != nil {
+			t.Fatalf("round err=%v", err)
+		}
+	}
+	if !buy.Price.Equal(decimal.NewFromInt(28000)) || !sell.Price.Equal(decimal.NewFromInt(28050)) ||
+		!odd.Price.Equal(decimal.RequireFromString("10.5")) {
+		t.Fatalf("unexpected rounding buy=%v sell=%v odd=%v", buy.Price, sell.Price, odd.Price)
+	}
+}
@@ -0,0 +1,405 @@
+package oms
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/joripage/orderbook-dev/pkg/oms/instrument"
+	"github.com/joripage/orderbook-dev/pkg/oms/ledger"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/position"
+	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
+	"github.com/shopspring/decimal"
+)
+
+// maxQtyRule rejects orders above max.
+type maxQtyRule struct {
+	max int64
+}
+
+func (r maxQtyRule) Check(order *model.Order) error {
+	if order.Quantity > r.max {
+		return riskrule.Reject(model.RejectReasonOrderExceedsLimit, "quantity above limit")
+	}
+	return nil
+}
+
+func newRiskOMS(gw *mockOrderGateway) *OMS {
+	risk, _ := riskrule.NewChain(nil, nil)
+	risk.Add("max_qty", maxQtyRule{max: 100})
+	return NewOMS(gw, &OMSConfig{Risk: risk})
+}
+
+func TestRiskRejectsNewOrder(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := newRiskOMS(gw)
+	defer s.Stop()
+
+	err := s.AddOrder(context.Background(), newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 200))
+	var rejectErr *riskrule.RejectError
+	if !errors.As(err, &rejectErr) || rejectErr.Rule != "max_qty" {
+		t.Fatalf("expected max_qty reject, got %v", err)
+	}
+
+	r := gw.lastReport("B1")
+	if r == nil || r.Status != model.OrderStatusRejected || r.ExecType != model.ExecTypeRejected {
+		t.Fatalf("expected Rejected report, got %+v", r)
+	}
+	if r.RejectReason != model.RejectReasonOrderExceedsLimit || r.Text != "quantity above limit" || r.LeavesQuantity != 0 {
+		t.Fatalf("unexpected reject details %+v", r)
+	}
+	if bids, _ := s.orderbookManager.Depth("TEST", 5); len(bids) != 0 {
+		t.Fatalf("rejected order reached the book %+v", bids)
+	}
+
+	stats := s.RiskStats()
+	if len(stats) != 1 || stats[0].Checks != 1 || stats[0].Rejects != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRiskRejectsModifyWithCancelReject(t *testing.T) {
+	gw := &mockOrderGateway{}
+	s := newRiskOMS(gw)
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 100)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B2",
+		OrigGatewayID: "B1",
+		NewPrice:      decimal.NewFromInt(100),
+		NewQuantity:   decimal.NewFromInt(300),
+	})
+	if err == nil {
+		t.Fatal("expected modify to be rejected")
+	}
+
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if len(gw.cancelRejects) != 1 {
+		t.Fatalf("expected one cancel reject, got %+v", gw.cancelRejects)
+	}
+	cr := gw.cancelRejects[0]
+	if cr.GatewayID != "B2" || cr.OrigGatewayID != "B1" || cr.ResponseTo != model.CancelRejectResponseToReplace ||
+		cr.Status != model.OrderStatusNew || cr.Reason != model.RejectReasonOrderExceedsLimit {
+		t.Fatalf("unexpected cancel reject %+v", cr)
+	}
+}
+
+func TestBuyingPowerRejectsAndReleases(t *testing.T) {
+	cash, _ := ledger.NewLedger(context.Background(), nil, nil, nil)
+	cash.Deposit("ACC-B1", decimal.NewFromInt(1_000))
+	cash.Deposit("ACC-B2", decimal.NewFromInt(1_000))
+	risk, err := riskrule.NewChain(&riskrule.Config{Rules: []string{riskrule.RuleBuyingPower}}, &riskrule.Deps{Cash: cash})
+	if err != nil {
+		t.Fatalf("new chain err=%v", err)
+	}
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Risk: risk, Ledger: cash})
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 10, 200)); err == nil {
+		t.Fatal("expected order above buying power to be rejected")
+	}
+	if r := gw.lastReport("B1"); r == nil || r.RejectReason != model.RejectReasonOrderExceedsLimit {
+		t.Fatalf("expected OrderExceedsLimit reject, got %+v", r)
+	}
+
+	if err := s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 10, 60)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if b, _ := cash.Balance("ACC-B2"); !b.Reserved.Equal(decimal.NewFromInt(600)) {
+		t.Fatalf("expected 600 reserved, got %+v", b)
+	}
+	if err := s.CancelOrder(ctx, &model.CancelOrder{GatewayID: "B2-C", OrigGatewayID: "B2"}); err != nil {
+		t.Fatalf("cancel err=%v", err)
+	}
+	if b, _ := cash.Balance("ACC-B2"); !b.Reserved.Equal(decimal.NewFromInt(0)) || !b.Cash.Equal(decimal.NewFromInt(1_000)) {
+		t.Fatalf("expected reservation released, got %+v", b)
+	}
+}
+
+func TestBuyingPowerScalesMarketOrders(t *testing.T) {
+	instruments, err := instrument.NewStoreFromFile("../../config/market_data.json")
+	if err != nil {
+		t.Fatalf("load instruments err=%v", err)
+	}
+	cash, _ := ledger.NewLedger(context.Background(), nil, nil, instruments)
+	cash.Deposit("ACC-M1", decimal.NewFromInt(1_000_000))
+	// DVT ceil 11.2 thousand VND is 11200 VND per share
+	risk, err := riskrule.NewChain(&riskrule.Config{
+		Rules:      []string{riskrule.RuleBuyingPower},
+		LimitPrice: &riskrule.LimitPriceConfig{PriceScale: 1000},
+	}, &riskrule.Deps{Cash: cash})
+	if err != nil {
+		t.Fatalf("new chain err=%v", err)
+	}
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Risk: risk, Ledger: cash, Instruments: instruments})
+	defer s.Stop()
+
+	order := newAddOrder("M1", model.OrderSideBuy, model.OrderTypeMarket, 0, 100)
+	order.Symbol = "DVT"
+	if err := s.AddOrder(context.Background(), order); err == nil {
+		t.Fatal("expected market order above buying power to be rejected")
+	}
+	if r := gw.lastReport("M1"); r == nil || r.RejectReason != model.RejectReasonOrderExceedsLimit {
+		t.Fatalf("expected OrderExceedsLimit reject, got %+v", r)
+	}
+}
+
+func TestOrderLimitUsesOrderPrices(t *testing.T) {
+	instruments, err := instrument.NewStoreFromFile("../../config/market_data.json")
+	if err != nil {
+		t.Fatalf("load instruments err=%v", err)
+	}
+	// DVT ref 9.8 thousand VND is 9800 VND before the first trade
+	risk, err := riskrule.NewChain(&riskrule.Config{
+		Rules: []string{riskrule.RuleOrderLimit},
+		OrderLimits: &riskrule.OrderLimitsConfig{
+			Firm: riskrule.OrderLimit{MaxNotional: 500_000, MaxPriceDeviation: 0.1},
+		},
+		LimitPrice: &riskrule.LimitPriceConfig{PriceScale: 1000},
+	}, &riskrule.Deps{})
+	if err != nil {
+		t.Fatalf("new chain err=%v", err)
+	}
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Risk: risk, Instruments: instruments})
+	defer s.Stop()
+	ctx := context.Background()
+
+	if price, ok := s.ReferencePrice("DVT"); !ok || price != 9800 {
+		t.Fatalf("expected reference price 9800, got %v %v", price, ok)
+	}
+
+	tests := []struct {
+		gatewayID string
+		orderType model.OrderType
+		price     int64
+		qty       int64
+		reason    model.OrderRejectReason
+	}{
+		{"L1", model.OrderTypeLimit, 9_900, 10, ""},
+		{"L2", model.OrderTypeLimit, 11_000, 10, model.RejectReasonPriceExceedsBand},
+		{"M1", model.OrderTypeMarket, 0, 50, ""},
+		{"M2", model.OrderTypeMarket, 0, 100, model.RejectReasonOrderExceedsLimit},
+	}
+	for _, tt := range tests {
+		order := newAddOrder(tt.gatewayID, model.OrderSideBuy, tt.orderType, tt.price, tt.qty)
+		order.Symbol = "DVT"
+		s.AddOrder(ctx, order)
+		r := gw.lastReport(tt.gatewayID)
+		if r == nil {
+			t.Fatalf("%s: no report", tt.gatewayID)
+		}
+		if tt.reason == "" && r.Status == model.OrderStatusRejected {
+			t.Errorf("%s: unexpected reject %s", tt.gatewayID, r.Text)
+		}
+		if tt.reason != "" && r.RejectReason != tt.reason {
+			t.Errorf("%s: expected %s reject, got %+v", tt.gatewayID, tt.reason, r)
+		}
+	}
+}
+
+func TestHoldingsRejectsNakedShortSell(t *testing.T) {
+	positions, _ := position.NewPositions(context.Background(), &position.PositionsConfig{
+		ShortSellAccounts: []string{"ACC-S3"},
+	}, nil)
+	positions.Adjust("ACC-S1", "TEST", 100)
+	risk, err := riskrule.NewChain(&riskrule.Config{Rules: []string{riskrule.RuleHoldings}}, &riskrule.Deps{Positions: positions})
+	if err != nil {
+		t.Fatalf("new chain err=%v", err)
+	}
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Risk: risk, Positions: positions})
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 10, 100)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if pos := positions.Position("ACC-S1", "TEST"); pos.Locked != 100 {
+		t.Fatalf("expected 100 locked, got %+v", pos)
+	}
+
+	if err := s.AddOrder(ctx, newAddOrder("S2", model.OrderSideSell, model.OrderTypeLimit, 10, 1)); err == nil {
+		t.Fatal("expected sell without holdings to be rejected")
+	}
+	if r := gw.lastReport("S2"); r == nil || r.Status != model.OrderStatusRejected || r.Text != "insufficient holdings" {
+		t.Fatalf("expected insufficient holdings reject, got %+v", r)
+	}
+
+	short := newAddOrder("S3", model.OrderSideSell, model.OrderTypeLimit, 10, 50)
+	short.ShortSell = true
+	if err := s.AddOrder(ctx, short); err != nil {
+		t.Fatalf("approved short sell err=%v", err)
+	}
+
+	// a fill moves the holding and the lock
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 10, 40)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if pos := positions.Position("ACC-S1", "TEST"); pos.Quantity != 60 || pos.Locked != 60 {
+		t.Fatalf("unexpected seller position %+v", pos)
+	}
+	if pos := positions.Position("ACC-B1", "TEST"); pos.Quantity != 40 {
+		t.Fatalf("unexpected buyer position %+v", pos)
+	}
+}
+
+func TestOrderLimitUsesLastTradePrice(t *testing.T) {
+	risk, _ := riskrule.NewChain(&riskrule.Config{
+		Rules: []string{riskrule.RuleOrderLimit},
+		OrderLimits: &riskrule.OrderLimitsConfig{
+			Firm: riskrule.OrderLimit{MaxQuantity: 1000, MaxPriceDeviation: 0.1},
+		},
+	}, nil)
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Risk: risk})
+	defer s.Stop()
+	ctx := context.Background()
+
+	// no trade and no instrument master: only the quantity is checked
+	if err := s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 10)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 5)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+
+	if err := s.AddOrder(ctx, newAddOrder("B2", model.OrderSideBuy, model.OrderTypeLimit, 120, 5)); err == nil {
+		t.Fatal("expected price 20% above last trade to be rejected")
+	}
+	err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "S1-R",
+		OrigGatewayID: "S1",
+		NewPrice:      decimal.NewFromInt(100),
+		NewQuantity:   decimal.NewFromInt(5000),
+	})
+	if err == nil {
+		t.Fatal("expected replace above max quantity to be rejected")
+	}
+}
+
+func TestTickSizeRoundsNewAndReplacedPrice(t *testing.T) {
+	risk, _ := riskrule.NewChain(nil, nil)
+	risk.Add(riskrule.RuleTickSize, riskrule.NewTickSizeRule(&riskrule.TickSizeConfig{
+		Exchanges: map[string]map[string]riskrule.TickSizeTable{
+			"HOSE": {"default": {{Step: decimal.NewFromInt(50)}}},
+		},
+		Round: true,
+	}, nil))
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Risk: risk})
+	defer s.Stop()
+	ctx := context.Background()
+
+	addOrder := newAddOrder("T1", model.OrderSideBuy, model.OrderTypeLimit, 28020, 100)
+	addOrder.Exchange = "HOSE"
+	if err := s.AddOrder(ctx, addOrder); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if r := gw.lastReport("T1"); r == nil || !r.Price.Equal(decimal.NewFromInt(28000)) {
+		t.Fatalf("expected price rounded down to 28000, got %+v", r)
+	}
+
+	err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID: "T1-R", OrigGatewayID: "T1",
+		NewPrice: decimal.NewFromInt(28120), NewQuantity: decimal.NewFromInt(100),
+	})
+	if err != nil {
+		t.Fatalf("modify err=%v", err)
+	}
+	if r := gw.lastReport("T1-R"); r == nil || !r.Price.Equal(decimal.NewFromInt(28100)) {
+		t.Fatalf("expected replaced price rounded to 28100, got %+v", r)
+	}
+}
+
+func TestBuyingPowerChecksReplacedLeaves(t *testing.T) {
+	cash, _ := ledger.NewLedger(context.Background(), nil, nil, nil)
+	cash.Deposit("ACC-B1", decimal.NewFromInt(1_000))
+	risk, _ := riskrule.NewChain(&riskrule.Config{Rules: []string{riskrule.RuleBuyingPower}}, &riskrule.Deps{Cash: cash})
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Risk: risk, Ledger: cash})
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	s.AddOrder(ctx, newAddOrder("S1", model.OrderSideSell, model.OrderTypeLimit, 100, 4))
+
+	// the 6 left still fit the cash reserved for them
+	if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B1-R",
+		OrigGatewayID: "B1",
+		NewPrice:      decimal.NewFromInt(100),
+		NewQuantity:   decimal.NewFromInt(10),
+	}); err != nil {
+		t.Fatalf("replace err=%v", err)
+	}
+	// 7 left cost more than the account has
+	if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B1-R2",
+		OrigGatewayID: "B1-R",
+		NewPrice:      decimal.NewFromInt(100),
+		NewQuantity:   decimal.NewFromInt(11),
+	}); err == nil {
+		t.Fatal("expected the replace above buying power to be rejected")
+	}
+	if b, _ := cash.Balance("ACC-B1"); !b.Reserved.Equal(decimal.NewFromInt(600)) {
+		t.Fatalf("expected 600 reserved for the leaves, got %+v", b)
+	}
+}
+
+func TestLedgerHoldsReplaceIncrease(t *testing.T) {
+	cash, _ := ledger.NewLedger(context.Background(), nil, nil, nil)
+	cash.Deposit("ACC-B1", decimal.NewFromInt(1_500))
+	// no buying power rule, the ledger itself refuses
+	gw := &mockOrderGateway{}
+	s := NewOMS(gw, &OMSConfig{Ledger: cash, PendingAcks: true})
+	defer s.Stop()
+	ctx := context.Background()
+
+	if err := s.AddOrder(ctx, newAddOrder("B1", model.OrderSideBuy, model.OrderTypeLimit, 100, 10)); err != nil {
+		t.Fatalf("add err=%v", err)
+	}
+	if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B1-R",
+		OrigGatewayID: "B1",
+		NewPrice:      decimal.NewFromInt(100),
+		NewQuantity:   decimal.NewFromInt(20),
+	}); err == nil {
+		t.Fatal("expected the replace above the cash to be refused")
+	}
+	gw.mu.Lock()
+	rejects := len(gw.cancelRejects)
+	gw.mu.Unlock()
+	if rejects != 1 {
+		t.Fatalf("expected a cancel reject, got %d", rejects)
+	}
+	if b, _ := cash.Balance("ACC-B1"); !b.Reserved.Equal(decimal.NewFromInt(1_000)) {
+		t.Fatalf("expected 1000 still reserved, got %+v", b)
+	}
+
+	if err := s.ModifyOrder(ctx, &model.ModifyOrder{
+		GatewayID:     "B1-R2",
+		OrigGatewayID: "B1",
+		NewPrice:      decimal.NewFromInt(100),
+		NewQuantity:   decimal.NewFromInt(15),
+	}); err != nil {
+		t.Fatalf("replace err=%v", err)
+	}
+	if b, _ := cash.Balance("ACC-B1"); !b.Reserved.Equal(decimal.NewFromInt(1_500)) {
+		t.Fatalf("expected 1500 reserved, got %+v", b)
+	}
+	if r := gw.lastReport("B1-R2"); r.ExecType != model.ExecTypeReplaced || r.Quantity != 15 {
+		t.Fatalf("expected Replaced, got %+v", r)
+	}
+}
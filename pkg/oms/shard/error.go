@@ -0,0 +1,10 @@
+package shard
+
+import "errors"
+
+var (
+	errShardUnavailable = errors.New("shard unavailable")
+	errUnknownMethod    = errors.New("unknown method")
+	errUnknownGatewayID = errors.New("gatewayID not routed to any shard")
+	errEmptyOrderList   = errors.New("empty order list")
+)
@@ -0,0 +1,165 @@
+package shard
+
+import (
+	"encoding/gob"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
+)
+
+const (
+	methodAddOrder     = "AddOrder"
+	methodModifyOrder  = "ModifyOrder"
+	methodCancelOrder  = "CancelOrder"
+	methodAddOrderList = "AddOrderList"
+	methodPutThrough   = "PutThrough"
+	methodQuote        = "Quote"
+	methodMassQuote    = "MassQuote"
+	methodCancelQuotes = "CancelQuotes"
+
+	methodEngageKillSwitch  = "EngageKillSwitch"
+	methodReleaseKillSwitch = "ReleaseKillSwitch"
+	methodKillSwitches      = "KillSwitches"
+	methodReloadConfig      = "ReloadConfig"
+)
+
+type frameKind uint8
+
+const (
+	frameRequest frameKind = iota + 1
+	frameResponse
+	frameReport
+)
+
+// request is one IOMS call, only the argument of Method is set.
+type request struct {
+	Method string
+
+	AddOrder     *model.AddOrder
+	ModifyOrder  *model.ModifyOrder
+	CancelOrder  *model.CancelOrder
+	AddOrderList *model.AddOrderList
+	PutThrough   *model.PutThrough
+	Quote        *model.Quote
+	MassQuote    *model.MassQuote
+	KillSwitch   *model.KillSwitch
+
+	// CancelQuotes
+	Account string
+	Symbol  string
+
+	// ReloadConfig
+	Operator string
+}
+
+// frame is the unit sent over a shard connection:
+//   - request:  router -> engine, Seq set by the router
+//   - response: engine -> router, answers the request with the same Seq
+//   - report:   engine -> router, order report or cancel reject of the engine OMS
+type frame struct {
+	Kind    frameKind
+	Seq     uint64
+	Request *request
+	Err     *wireError
+	Errs    []*wireError // MassQuote, one per entry
+	Report  *model.Order
+
+	CancelReject *model.CancelReject
+	KillSwitches []model.KillSwitch // KillSwitches response
+	Version      int64              // ReloadConfig response
+}
+
+// conn is a gob framed shard connection, safe for concurrent send.
+type conn struct {
+	net.Conn
+	enc *gob.Encoder
+	dec *gob.Decoder
+	mu  sync.Mutex
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{
+		Conn: c,
+		enc:  gob.NewEncoder(c),
+		dec:  gob.NewDecoder(c),
+	}
+}
+
+func (c *conn) send(f *frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.enc.Encode(f)
+}
+
+func (c *conn) receive() (*frame, error) {
+	f := &frame{}
+	if err := c.dec.Decode(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// wireError is an error sent over a shard connection. Errors listed in
+// oms.Errors or by the shard travel by name so errors.Is still matches them
+// on the router, a risk reject keeps its rule, reason and text.
+type wireError struct {
+	Name   string
+	Text   string
+	Reject *riskrule.RejectError
+}
+
+func (e *wireError) Error() string {
+	return e.Text
+}
+
+// knownErrors are the errors sent by name.
+var knownErrors = func() map[string]error {
+	known := map[string]error{
+		"shard_unavailable":  errShardUnavailable,
+		"unknown_method":     errUnknownMethod,
+		"unknown_gateway_id": errUnknownGatewayID,
+		"empty_order_list":   errEmptyOrderList,
+	}
+	for name, err := range oms.Errors {
+		known[name] = err
+	}
+	return known
+}()
+
+func toWireError(err error) *wireError {
+	if err == nil {
+		return nil
+	}
+	w := &wireError{Text: err.Error()}
+	var rejectErr *riskrule.RejectError
+	if errors.As(err, &rejectErr) {
+		w.Reject = rejectErr
+	}
+	for name, known := range knownErrors {
+		if errors.Is(err, known) {
+			w.Name = name
+			break
+		}
+	}
+	return w
+}
+
+// fromWireError gives back the error sent by the engine: the known error
+// itself, the risk reject or an error with its text.
+func fromWireError(w *wireError) error {
+	if w == nil {
+		return nil
+	}
+	if known, ok := knownErrors[w.Name]; ok {
+		return known
+	}
+	if w.Reject != nil {
+		return w.Reject
+	}
+	return errors.New(w.Text)
+}
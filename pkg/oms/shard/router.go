@@ -0,0 +1,378 @@
+package shard
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+// Router is the gateway side of a sharded engine. It implements oms.IOMS by
+// sending every command to the shard owning its symbol and merges the report
+// streams of all shards into one OrderGateway. Reports of one symbol keep
+// their order since a symbol lives on exactly one shard.
+type Router struct {
+	cfg          *RouterConfig
+	shards       *ShardMap
+	orderGateway oms.OrderGateway
+	clients      []*client
+
+	// cancel and replace requests follow the shard of the order they refer
+	// to, the routes of an order are dropped once it ends
+	gatewayShard sync.Map // gatewayID -> shard
+	orderMu      sync.Mutex
+	orderIDs     map[string][]string // orderID -> gatewayIDs routed
+}
+
+type RouterConfig struct {
+	// Shards holds the engine addresses, the index in the list is the shard.
+	Shards []string
+	// DialTimeout bounds the wait for every shard to accept the connection.
+	DialTimeout time.Duration
+}
+
+func NewRouter(cfg *RouterConfig, orderGateway oms.OrderGateway) *Router {
+	r := &Router{
+		cfg:          cfg,
+		shards:       NewShardMap(cfg.Shards),
+		orderGateway: orderGateway,
+		orderIDs:     make(map[string][]string),
+	}
+	for _, addr := range cfg.Shards {
+		r.clients = append(r.clients, &client{
+			addr:     addr,
+			pending:  make(map[uint64]chan *frame),
+			onReport: r.onReport,
+		})
+	}
+
+	return r
+}
+
+// Start connects to every shard then starts the order gateway.
+func (r *Router) Start(ctx context.Context) error {
+	deadline := time.Now().Add(r.cfg.DialTimeout)
+	for _, c := range r.clients {
+		if err := c.dial(ctx, deadline); err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			c.close()
+		}()
+	}
+
+	return r.orderGateway.Start(ctx)
+}
+
+func (r *Router) onReport(report interface{}) {
+	switch report := report.(type) {
+	case model.Order:
+		r.trackOrder(&report)
+	case model.CancelReject:
+		// the ClOrdID of a refused request never joins the order
+		if report.GatewayID != report.OrigGatewayID {
+			r.gatewayShard.Delete(report.GatewayID)
+		}
+	}
+	r.orderGateway.OnOrderReport(context.Background(), report)
+}
+
+// trackOrder remembers the gatewayIDs of a live order and drops its routes
+// once it ends.
+func (r *Router) trackOrder(order *model.Order) {
+	// quote sides are routed by symbol
+	if order.QuoteID != "" {
+		return
+	}
+
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
+
+	ids := r.orderIDs[order.OrderID]
+	if !order.IsEnd() {
+		if !slices.Contains(ids, order.GatewayID) {
+			r.orderIDs[order.OrderID] = append(ids, order.GatewayID)
+		}
+		return
+	}
+	for _, id := range append(ids, order.GatewayID, order.OrigGatewayID) {
+		r.gatewayShard.Delete(id)
+	}
+	delete(r.orderIDs, order.OrderID)
+}
+
+func (r *Router) route(gatewayID string, shard int) {
+	r.gatewayShard.Store(gatewayID, shard)
+}
+
+// routed returns the shard of the order of gatewayID. An order unknown to
+// the router, e.g. one recovered by an engine after a router restart,
+// follows the shard of symbol.
+func (r *Router) routed(gatewayID, symbol string) (int, bool) {
+	if shard, ok := r.gatewayShard.Load(gatewayID); ok {
+		return shard.(int), true
+	}
+	if symbol == "" {
+		return 0, false
+	}
+	return r.shards.Shard(symbol), true
+}
+
+func (r *Router) call(shard int, req *request) error {
+	resp, err := r.clients[shard].call(req)
+	if err != nil {
+		return err
+	}
+	return fromWireError(resp.Err)
+}
+
+func (r *Router) AddOrder(ctx context.Context, addOrder *model.AddOrder) error {
+	shard := r.shards.Shard(addOrder.Symbol)
+	r.route(addOrder.GatewayID, shard)
+
+	return r.call(shard, &request{Method: methodAddOrder, AddOrder: addOrder})
+}
+
+func (r *Router) ModifyOrder(ctx context.Context, modifyOrder *model.ModifyOrder) error {
+	shard, ok := r.routed(modifyOrder.OrigGatewayID, modifyOrder.Symbol)
+	if !ok {
+		return errUnknownGatewayID
+	}
+	r.route(modifyOrder.GatewayID, shard)
+
+	return r.call(shard, &request{Method: methodModifyOrder, ModifyOrder: modifyOrder})
+}
+
+func (r *Router) CancelOrder(ctx context.Context, cancelOrder *model.CancelOrder) error {
+	shard, ok := r.routed(cancelOrder.OrigGatewayID, cancelOrder.Symbol)
+	if !ok {
+		return errUnknownGatewayID
+	}
+	r.route(cancelOrder.GatewayID, shard)
+
+	return r.call(shard, &request{Method: methodCancelOrder, CancelOrder: cancelOrder})
+}
+
+// AddOrderList sends the list to the shard of its first order, the engine
+// rejects lists spanning several symbols.
+func (r *Router) AddOrderList(ctx context.Context, addOrderList *model.AddOrderList) error {
+	if len(addOrderList.Orders) == 0 {
+		return errEmptyOrderList
+	}
+	shard := r.shards.Shard(addOrderList.Orders[0].Symbol)
+	for _, addOrder := range addOrderList.Orders {
+		r.route(addOrder.GatewayID, shard)
+	}
+
+	return r.call(shard, &request{Method: methodAddOrderList, AddOrderList: addOrderList})
+}
+
+func (r *Router) PutThrough(ctx context.Context, putThrough *model.PutThrough) error {
+	shard := r.shards.Shard(putThrough.Symbol)
+	r.route(putThrough.BuyGatewayID, shard)
+	r.route(putThrough.SellGatewayID, shard)
+
+	return r.call(shard, &request{Method: methodPutThrough, PutThrough: putThrough})
+}
+
+func (r *Router) Quote(ctx context.Context, quote *model.Quote) error {
+	shard := r.shards.Shard(quote.Symbol)
+
+	return r.call(shard, &request{Method: methodQuote, Quote: quote})
+}
+
+// MassQuote splits the entries by shard and puts the results of every shard
+// back in entry order.
+func (r *Router) MassQuote(ctx context.Context, massQuote *model.MassQuote) []error {
+	errs := make([]error, len(massQuote.Quotes))
+	parts := make(map[int]*model.MassQuote)
+	index := make(map[int][]int)
+	for i, quote := range massQuote.Quotes {
+		shard := r.shards.Shard(quote.Symbol)
+		part, ok := parts[shard]
+		if !ok {
+			part = &model.MassQuote{QuoteID: massQuote.QuoteID, Account: massQuote.Account, SessionID: massQuote.SessionID}
+			parts[shard] = part
+		}
+		part.Quotes = append(part.Quotes, quote)
+		index[shard] = append(index[shard], i)
+	}
+
+	for shard, part := range parts {
+		resp, err := r.clients[shard].call(&request{Method: methodMassQuote, MassQuote: part})
+		for j, i := range index[shard] {
+			switch {
+			case err != nil:
+				errs[i] = err
+			case j < len(resp.Errs):
+				errs[i] = fromWireError(resp.Errs[j])
+			}
+		}
+	}
+
+	return errs
+}
+
+// CancelQuotes goes to the shard of symbol, or to every shard when symbol is
+// empty.
+func (r *Router) CancelQuotes(ctx context.Context, account, symbol string) error {
+	req := &request{Method: methodCancelQuotes, Account: account, Symbol: symbol}
+	if symbol != "" {
+		return r.call(r.shards.Shard(symbol), req)
+	}
+
+	return r.broadcast(req)
+}
+
+// EngageKillSwitch goes to every shard, an account or session trades on all
+// of them.
+func (r *Router) EngageKillSwitch(ctx context.Context, killSwitch *model.KillSwitch) error {
+	return r.broadcast(&request{Method: methodEngageKillSwitch, KillSwitch: killSwitch})
+}
+
+func (r *Router) ReleaseKillSwitch(ctx context.Context, killSwitch *model.KillSwitch) error {
+	return r.broadcast(&request{Method: methodReleaseKillSwitch, KillSwitch: killSwitch})
+}
+
+// KillSwitches asks the first shard, every shard holds the same switches.
+func (r *Router) KillSwitches(ctx context.Context) ([]model.KillSwitch, error) {
+	resp, err := r.clients[0].call(&request{Method: methodKillSwitches})
+	if err != nil {
+		return nil, err
+	}
+	return resp.KillSwitches, fromWireError(resp.Err)
+}
+
+// ReloadConfig reloads every shard from its own files, versions are kept
+// per shard and the highest one is returned.
+func (r *Router) ReloadConfig(ctx context.Context, operator string) (int64, error) {
+	var version int64
+	var firstErr error
+	for _, c := range r.clients {
+		resp, err := c.call(&request{Method: methodReloadConfig, Operator: operator})
+		if err == nil {
+			err = fromWireError(resp.Err)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		version = max(version, resp.Version)
+	}
+	return version, firstErr
+}
+
+func (r *Router) broadcast(req *request) error {
+	var firstErr error
+	for shard := range r.clients {
+		if err := r.call(shard, req); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// client is the connection of the router to one shard.
+type client struct {
+	addr     string
+	conn     *conn
+	seq      uint64
+	onReport func(interface{})
+
+	mu      sync.Mutex
+	pending map[uint64]chan *frame
+	closed  bool
+}
+
+// dial retries until the shard accepts the connection or deadline passes.
+func (c *client) dial(ctx context.Context, deadline time.Time) error {
+	network, address := splitAddr(c.addr)
+	dialer := &net.Dialer{}
+	for {
+		nc, err := dialer.DialContext(ctx, network, address)
+		if err == nil {
+			c.conn = newConn(nc)
+			go c.readLoop()
+			return nil
+		}
+		if time.Now().After(deadline) || ctx.Err() != nil {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (c *client) close() {
+	c.conn.Close()
+}
+
+func (c *client) call(req *request) (*frame, error) {
+	seq := atomic.AddUint64(&c.seq, 1)
+	ch := make(chan *frame, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errShardUnavailable
+	}
+	c.pending[seq] = ch
+	c.mu.Unlock()
+
+	if err := c.conn.send(&frame{Kind: frameRequest, Seq: seq, Request: req}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+		return nil, errShardUnavailable
+	}
+
+	resp, ok := <-ch
+	if !ok {
+		return nil, errShardUnavailable
+	}
+	return resp, nil
+}
+
+func (c *client) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		for seq, ch := range c.pending {
+			close(ch)
+			delete(c.pending, seq)
+		}
+		c.mu.Unlock()
+	}()
+
+	for {
+		f, err := c.conn.receive()
+		if err != nil {
+			return
+		}
+
+		switch f.Kind {
+		case frameReport:
+			if f.Report != nil {
+				c.onReport(*f.Report)
+			}
+			if f.CancelReject != nil {
+				c.onReport(*f.CancelReject)
+			}
+		case frameResponse:
+			c.mu.Lock()
+			ch, ok := c.pending[f.Seq]
+			delete(c.pending, f.Seq)
+			c.mu.Unlock()
+			if ok {
+				ch <- f
+			}
+		}
+	}
+}
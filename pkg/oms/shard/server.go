@@ -0,0 +1,195 @@
+package shard
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+)
+
+// Server runs one engine shard. It serves the commands of the connected
+// routers against its OMS and streams every order report of the OMS back to
+// them, so it is the OrderGateway of that OMS.
+type Server struct {
+	cfg         *ServerConfig
+	omsInstance oms.IOMS
+	listener    net.Listener
+
+	// commands of all routers are applied one at a time
+	execMu sync.Mutex
+
+	mu    sync.Mutex
+	conns map[*conn]struct{}
+}
+
+type ServerConfig struct {
+	// Addr is "host:port" for TCP or "unix:/path" for a Unix socket.
+	Addr string
+}
+
+func NewServer(cfg *ServerConfig) *Server {
+	return &Server{
+		cfg:   cfg,
+		conns: make(map[*conn]struct{}),
+	}
+}
+
+func (s *Server) AddOmsInstance(o oms.IOMS) {
+	s.omsInstance = o
+}
+
+// Start listens on the configured address, it stops when ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	network, address := splitAddr(s.cfg.Addr)
+	if network == "unix" {
+		_ = os.Remove(address)
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go func() {
+		<-ctx.Done()
+		s.stop()
+	}()
+	go s.acceptLoop()
+
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.cfg.Addr
+	}
+	if s.listener.Addr().Network() == "unix" {
+		return "unix:" + s.listener.Addr().String()
+	}
+	return s.listener.Addr().String()
+}
+
+func (s *Server) stop() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+func (s *Server) acceptLoop() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := newConn(nc)
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		go s.serve(c)
+	}
+}
+
+func (s *Server) serve(c *conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		f, err := c.receive()
+		if err != nil {
+			return
+		}
+		if f.Kind != frameRequest || f.Request == nil {
+			continue
+		}
+
+		resp := s.execute(f.Request)
+		resp.Kind, resp.Seq = frameResponse, f.Seq
+		if err := c.send(resp); err != nil {
+			log.Printf("shard send response err=%v", err)
+			return
+		}
+	}
+}
+
+func (s *Server) execute(req *request) *frame {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	ctx := context.Background()
+	var err error
+	switch req.Method {
+	case methodAddOrder:
+		err = s.omsInstance.AddOrder(ctx, req.AddOrder)
+	case methodModifyOrder:
+		err = s.omsInstance.ModifyOrder(ctx, req.ModifyOrder)
+	case methodCancelOrder:
+		err = s.omsInstance.CancelOrder(ctx, req.CancelOrder)
+	case methodAddOrderList:
+		err = s.omsInstance.AddOrderList(ctx, req.AddOrderList)
+	case methodPutThrough:
+		err = s.omsInstance.PutThrough(ctx, req.PutThrough)
+	case methodQuote:
+		err = s.omsInstance.Quote(ctx, req.Quote)
+	case methodMassQuote:
+		errs := s.omsInstance.MassQuote(ctx, req.MassQuote)
+		resp := &frame{Errs: make([]*wireError, len(errs))}
+		for i, err := range errs {
+			resp.Errs[i] = toWireError(err)
+		}
+		return resp
+	case methodCancelQuotes:
+		err = s.omsInstance.CancelQuotes(ctx, req.Account, req.Symbol)
+	case methodEngageKillSwitch:
+		err = s.omsInstance.EngageKillSwitch(ctx, req.KillSwitch)
+	case methodReleaseKillSwitch:
+		err = s.omsInstance.ReleaseKillSwitch(ctx, req.KillSwitch)
+	case methodKillSwitches:
+		switches, err := s.omsInstance.KillSwitches(ctx)
+		return &frame{Err: toWireError(err), KillSwitches: switches}
+	case methodReloadConfig:
+		version, err := s.omsInstance.ReloadConfig(ctx, req.Operator)
+		return &frame{Err: toWireError(err), Version: version}
+	default:
+		err = errUnknownMethod
+	}
+
+	return &frame{Err: toWireError(err)}
+}
+
+// OnOrderReport sends the report to every connected router.
+func (s *Server) OnOrderReport(ctx context.Context, args ...interface{}) {
+	if len(args) == 0 {
+		return
+	}
+	var f *frame
+	switch report := args[0].(type) {
+	case model.Order:
+		f = &frame{Kind: frameReport, Report: &report}
+	case model.CancelReject:
+		f = &frame{Kind: frameReport, CancelReject: &report}
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if err := c.send(f); err != nil {
+			log.Printf("shard send report err=%v", err)
+		}
+	}
+}
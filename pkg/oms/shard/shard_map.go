@@ -0,0 +1,41 @@
+package shard
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// ShardMap assigns every symbol to one engine shard. The assignment only
+// depends on the symbol and the number of shards, so gateways and engines
+// started with the same shard list always agree on the owner of a symbol.
+type ShardMap struct {
+	addrs []string
+}
+
+func NewShardMap(addrs []string) *ShardMap {
+	return &ShardMap{addrs: addrs}
+}
+
+func (m *ShardMap) Len() int {
+	return len(m.addrs)
+}
+
+// Shard returns the index of the shard owning symbol.
+func (m *ShardMap) Shard(symbol string) int {
+	h := fnv.New32a()
+	h.Write([]byte(symbol))
+	return int(h.Sum32() % uint32(len(m.addrs)))
+}
+
+func (m *ShardMap) Addr(shard int) string {
+	return m.addrs[shard]
+}
+
+// splitAddr returns the network and address of a shard address:
+// "unix:/path/engine.sock" for a Unix socket, "host:port" for TCP.
+func splitAddr(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
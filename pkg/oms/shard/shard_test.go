@@ -0,0 +1,193 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joripage/orderbook-dev/pkg/oms"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	riskrule "github.com/joripage/orderbook-dev/pkg/oms/risk_rule"
+	"github.com/shopspring/decimal"
+)
+
+const engineAddrEnv = "SHARD_TEST_ENGINE_ADDR"
+
+// TestHelperEngine is the engine process started by the tests below, it does
+// nothing in a normal test run.
+func TestHelperEngine(t *testing.T) {
+	addr := os.Getenv(engineAddrEnv)
+	if addr == "" {
+		t.Skip("engine helper process")
+	}
+
+	server := NewServer(&ServerConfig{Addr: addr})
+	engine := oms.NewOMS(server, nil)
+	server.AddOmsInstance(engine)
+	if err := server.Start(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	select {}
+}
+
+func startEngine(t *testing.T, addr string) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperEngine$")
+	cmd.Env = append(os.Environ(), engineAddrEnv+"="+addr)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start engine err=%v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+}
+
+type mockOrderGateway struct {
+	mu      sync.Mutex
+	reports []model.Order
+}
+
+func (g *mockOrderGateway) Start(ctx context.Context) error {
+	return nil
+}
+
+func (g *mockOrderGateway) OnOrderReport(ctx context.Context, args ...interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if order, ok := args[0].(model.Order); ok {
+		g.reports = append(g.reports, order)
+	}
+}
+
+// waitReport waits for a report of gatewayID with status.
+func (g *mockOrderGateway) waitReport(t *testing.T, gatewayID string, status model.OrderStatus) model.Order {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		g.mu.Lock()
+		for _, r := range g.reports {
+			if r.GatewayID == gatewayID && r.Status == status {
+				g.mu.Unlock()
+				return r
+			}
+		}
+		g.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s report for %s", status, gatewayID)
+	return model.Order{}
+}
+
+func newAddOrder(gatewayID, symbol string, side model.OrderSide, price, qty int64) *model.AddOrder {
+	return &model.AddOrder{
+		GatewayID:   gatewayID,
+		Account:     "ACC-" + gatewayID,
+		Symbol:      symbol,
+		Type:        model.OrderTypeLimit,
+		TimeInForce: model.OrderTimeInForceGTC,
+		Side:        side,
+		Price:       decimal.NewFromInt(price),
+		Quantity:    decimal.NewFromInt(qty),
+	}
+}
+
+// symbolOnShard returns a symbol owned by shard.
+func symbolOnShard(m *ShardMap, shard int) string {
+	for i := 0; ; i++ {
+		symbol := fmt.Sprintf("SYM%d", i)
+		if m.Shard(symbol) == shard {
+			return symbol
+		}
+	}
+}
+
+func TestShardMapIsDeterministic(t *testing.T) {
+	a := NewShardMap([]string{"a", "b", "c"})
+	b := NewShardMap([]string{"x", "y", "z"})
+	for _, symbol := range []string{"VNM", "FPT", "HPG", "SSI", "VIC"} {
+		if a.Shard(symbol) != b.Shard(symbol) {
+			t.Fatalf("symbol %s mapped to different shards", symbol)
+		}
+	}
+}
+
+func TestRouterAcrossEngineProcesses(t *testing.T) {
+	dir := t.TempDir()
+	addrs := []string{
+		"unix:" + filepath.Join(dir, "engine0.sock"),
+		"unix:" + filepath.Join(dir, "engine1.sock"),
+	}
+	for _, addr := range addrs {
+		startEngine(t, addr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gw := &mockOrderGateway{}
+	router := NewRouter(&RouterConfig{Shards: addrs, DialTimeout: 10 * time.Second}, gw)
+	if err := router.Start(ctx); err != nil {
+		t.Fatalf("start router err=%v", err)
+	}
+
+	// one matching pair on each shard
+	for shard := range addrs {
+		symbol := symbolOnShard(router.shards, shard)
+		sell, buy := fmt.Sprintf("S%d", shard), fmt.Sprintf("B%d", shard)
+		if err := router.AddOrder(ctx, newAddOrder(sell, symbol, model.OrderSideSell, 100, 10)); err != nil {
+			t.Fatalf("add order err=%v", err)
+		}
+		if err := router.AddOrder(ctx, newAddOrder(buy, symbol, model.OrderSideBuy, 100, 10)); err != nil {
+			t.Fatalf("add order err=%v", err)
+		}
+		gw.waitReport(t, sell, model.OrderStatusFilled)
+		gw.waitReport(t, buy, model.OrderStatusFilled)
+	}
+
+	// cancel follows the shard of the original order
+	symbol := symbolOnShard(router.shards, 1)
+	router.AddOrder(ctx, newAddOrder("R1", symbol, model.OrderSideBuy, 90, 10))
+	if err := router.CancelOrder(ctx, &model.CancelOrder{GatewayID: "R1-C", OrigGatewayID: "R1"}); err != nil {
+		t.Fatalf("cancel err=%v", err)
+	}
+	gw.waitReport(t, "R1-C", model.OrderStatusCanceled)
+	// the routes of an ended order are dropped
+	for _, id := range []string{"R1", "R1-C"} {
+		if _, ok := router.routed(id, ""); ok {
+			t.Fatalf("expected route of %s dropped", id)
+		}
+	}
+
+	// an order the router does not know follows the shard of its symbol,
+	// as after a router restart
+	router.AddOrder(ctx, newAddOrder("R2", symbol, model.OrderSideBuy, 90, 10))
+	router.gatewayShard.Delete("R2")
+	if err := router.CancelOrder(ctx, &model.CancelOrder{GatewayID: "R2-C", OrigGatewayID: "R2", Symbol: symbol}); err != nil {
+		t.Fatalf("cancel by symbol err=%v", err)
+	}
+	gw.waitReport(t, "R2-C", model.OrderStatusCanceled)
+
+	// engine errors come back to the caller as they were returned
+	err := router.AddOrderList(ctx, &model.AddOrderList{
+		ListID:          "L1",
+		ContingencyType: model.ContingencyTypeOCO,
+		Orders:          []*model.AddOrder{newAddOrder("L1-1", symbol, model.OrderSideBuy, 90, 10)},
+	})
+	if !errors.Is(err, oms.Errors["invalid_order_list"]) {
+		t.Fatalf("expected invalid order list, got %v", err)
+	}
+
+	router.EngageKillSwitch(ctx, &model.KillSwitch{Scope: model.KillSwitchScopeAccount, Value: "ACC-K1"})
+	err = router.AddOrder(ctx, newAddOrder("K1", symbol, model.OrderSideBuy, 90, 10))
+	var rejectErr *riskrule.RejectError
+	if !errors.As(err, &rejectErr) || rejectErr.Reason != model.RejectReasonBrokerOption {
+		t.Fatalf("expected kill switch reject, got %v", err)
+	}
+}
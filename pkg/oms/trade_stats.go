@@ -0,0 +1,89 @@
+package oms
+
+import "sync"
+
+// TradeStats holds the trading statistics of one symbol for the session.
+// Put-through trades count in the total volume and value but do not move
+// the last matched price.
+type TradeStats struct {
+	Symbol     string
+	LastPrice  float64
+	Volume     int64
+	Value      float64
+	TradeCount int64
+
+	PutThroughVolume int64
+	PutThroughValue  float64
+	PutThroughCount  int64
+}
+
+type tradeStatsStore struct {
+	mu    sync.RWMutex
+	stats map[string]*TradeStats
+}
+
+func newTradeStatsStore() *tradeStatsStore {
+	return &tradeStatsStore{
+		stats: make(map[string]*TradeStats),
+	}
+}
+
+func (s *tradeStatsStore) get(symbol string) *TradeStats {
+	stats, ok := s.stats[symbol]
+	if !ok {
+		stats = &TradeStats{Symbol: symbol}
+		s.stats[symbol] = stats
+	}
+	return stats
+}
+
+func (s *tradeStatsStore) addTrade(symbol string, price float64, qty int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.get(symbol)
+	stats.LastPrice = price
+	stats.Volume += qty
+	stats.Value += price * float64(qty)
+	stats.TradeCount++
+}
+
+func (s *tradeStatsStore) addPutThrough(symbol string, price float64, qty int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.get(symbol)
+	stats.Volume += qty
+	stats.Value += price * float64(qty)
+	stats.PutThroughVolume += qty
+	stats.PutThroughValue += price * float64(qty)
+	stats.PutThroughCount++
+}
+
+func (s *OMS) GetTradeStats(symbol string) (TradeStats, bool) {
+	s.tradeStats.mu.RLock()
+	defer s.tradeStats.mu.RUnlock()
+
+	stats, ok := s.tradeStats.stats[symbol]
+	if !ok {
+		return TradeStats{}, false
+	}
+	return *stats, true
+}
+
+// ReferencePrice returns the last matched price of symbol, or its reference
+// price before the first trade of the session. Both are order prices, the
+// reference price of market data is scaled like the limit price rule does.
+func (s *OMS) ReferencePrice(symbol string) (float64, bool) {
+	if stats, ok := s.GetTradeStats(symbol); ok && stats.LastPrice > 0 {
+		return stats.LastPrice, true
+	}
+	if s.instruments == nil {
+		return 0, false
+	}
+	instrument, ok := s.instruments.Get(symbol)
+	if !ok || instrument.Ref <= 0 {
+		return 0, false
+	}
+	return instrument.Ref * s.risk.PriceScale(), true
+}
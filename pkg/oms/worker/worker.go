@@ -2,9 +2,14 @@
 package worker
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"strings"
 
 	kafkawrapper "github.com/joripage/orderbook-dev/pkg/kafka_wrapper"
 	"github.com/joripage/orderbook-dev/pkg/oms/model"
@@ -13,15 +18,19 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+var errInvalidEvent = errors.New("invalid order event")
+
 type Worker struct {
-	order      repo.IOrder
-	orderEvent repo.IOrderEvent
+	order       repo.IOrder
+	orderEvent  repo.IOrderEvent
+	configEvent repo.IConfigEvent
 }
 
 func NewWorker(repo repo.IRepo) *Worker {
 	return &Worker{
-		order:      repo.Order(),
-		orderEvent: repo.OrderEvent(),
+		order:       repo.Order(),
+		orderEvent:  repo.OrderEvent(),
+		configEvent: repo.ConfigEvent(),
 	}
 }
 
@@ -39,31 +48,79 @@ func (w *Worker) StartConsumer(ctx context.Context, js nats.JetStreamContext, su
 			continue
 		}
 		var orderEvents []*model.OrderEvent
+		var stored []natsMsg
 		for _, msg := range msgs {
 			var orderEvent model.OrderEvent
 			if err := json.Unmarshal(msg.Data, &orderEvent); err != nil {
 				log.Println("unmarshal err", err)
-				_ = msg.Ack()
+				_ = msg.Term()
 				continue
 			}
 			orderEvents = append(orderEvents, &orderEvent)
+			stored = append(stored, msg)
+		}
+		w.storeMsgs(ctx, orderEvents, stored)
+	}
+}
+
+// natsMaxDeliveries is the number of times an event the database refuses is
+// delivered before it is terminated, an outage is waited out without limit.
+const natsMaxDeliveries = 5
+
+// natsMsg is the part of *nats.Msg the worker acknowledges with.
+type natsMsg interface {
+	Ack(opts ...nats.AckOpt) error
+	Nak(opts ...nats.AckOpt) error
+	Term(opts ...nats.AckOpt) error
+	Metadata() (*nats.MsgMetadata, error)
+}
+
+// storeMsgs stores the events of msgs and acks them once stored. Like the
+// Kafka path, a batch the database refuses is stored one event at a time
+// and only the events refused on their own are Nak'd, then terminated on
+// their natsMaxDeliveries-th delivery.
+func (w *Worker) storeMsgs(ctx context.Context, events []*model.OrderEvent, msgs []natsMsg) {
+	err := w.handleEvents(ctx, events)
+	switch {
+	case err == nil:
+		for _, msg := range msgs {
 			_ = msg.Ack()
 		}
-		if len(orderEvents) > 0 {
-			w.orderEvent.BulkCreate(ctx, orderEvents)
+	case kafkawrapper.IsRetryable(err):
+		log.Println("handle events err", err)
+		for _, msg := range msgs {
+			_ = msg.Nak()
+		}
+	case len(events) > 1:
+		for i := range events {
+			w.storeMsgs(ctx, events[i:i+1], msgs[i:i+1])
 		}
+	case lastDelivery(msgs[0]):
+		log.Printf("terminate orderID=%s seq=%d err=%v", events[0].OrderID, events[0].Seq, err)
+		_ = msgs[0].Term()
+	default:
+		log.Printf("refused orderID=%s seq=%d err=%v", events[0].OrderID, events[0].Seq, err)
+		_ = msgs[0].Nak()
 	}
 }
 
+func lastDelivery(msg natsMsg) bool {
+	meta, err := msg.Metadata()
+	return err != nil || meta.NumDelivered >= natsMaxDeliveries
+}
+
 func (w *Worker) StartConsumerKafka(ctx context.Context, subject, durable string) error {
-	// Consumer with 5 workers
+	// Consumer
 	cg, err := kafkawrapper.NewConsumerGroup(kafkawrapper.ConsumerConfig{
-		Brokers:     []string{"localhost:29092"},
-		GroupID:     "jobs-workers-2",
-		Topic:       "ORDERS.events",
-		WorkerCount: 5,
+		Brokers: []string{"localhost:29092"},
+		GroupID: "jobs-workers-2",
+		Topic:   "ORDERS.events",
+		// one batch in flight: offsets are committed in order, a batch held
+		// back by a database outage is never passed by a later commit
+		WorkerCount: 1,
 		MaxRetries:  5,
 		DLQTopic:    "jobs.dlq",
+		AutoCommit:  true,
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -78,16 +135,22 @@ func (w *Worker) StartConsumerKafka(ctx context.Context, subject, durable string
 			// time.Sleep(300 * time.Millisecond)
 			// return nil // return an error to trigger retries/DLQ
 			var orderEvents []*model.OrderEvent
+			var dead []kafkawrapper.Message
 			for _, msg := range msgs {
 				var orderEvent model.OrderEvent
 				if err := json.Unmarshal(msg.Value, &orderEvent); err != nil {
 					log.Println("unmarshal err", err)
+					dead = append(dead, msg)
 					continue
 				}
 				orderEvents = append(orderEvents, &orderEvent)
 			}
-			if len(orderEvents) > 0 {
-				w.orderEvent.BulkCreate(ctx, orderEvents)
+			if err := w.handleEvents(ctx, orderEvents); err != nil {
+				log.Println("handle events err", err)
+				return err
+			}
+			if len(dead) > 0 {
+				return &kafkawrapper.DeadLetterError{Messages: dead, Err: errInvalidEvent}
 			}
 			return nil
 		}); err != nil {
@@ -100,3 +163,126 @@ func (w *Worker) StartConsumerKafka(ctx context.Context, subject, durable string
 
 	return nil
 }
+
+// StartConfigConsumerKafka stores the config events the OMS instances
+// publish, a restart puts the latest one of its node back in force.
+func (w *Worker) StartConfigConsumerKafka(ctx context.Context) error {
+	cg, err := kafkawrapper.NewConsumerGroup(kafkawrapper.ConsumerConfig{
+		Brokers:     []string{"localhost:29092"},
+		GroupID:     "config-workers",
+		Topic:       "CONFIG.events",
+		WorkerCount: 1,
+		MaxRetries:  5,
+		DLQTopic:    "jobs.dlq",
+		AutoCommit:  true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cg.Close()
+
+	go func() {
+		if err := cg.Run(ctx, func(ctx context.Context, msgs []kafkawrapper.Message) error {
+			var dead []kafkawrapper.Message
+			for _, msg := range msgs {
+				ev, err := decodeConfigEvent(msg)
+				if err != nil {
+					log.Println("decode config event err", err)
+					dead = append(dead, msg)
+					continue
+				}
+				if err := w.configEvent.Create(ctx, ev); err != nil {
+					log.Println("store config event err", err)
+					return dbError(err)
+				}
+			}
+			if len(dead) > 0 {
+				return &kafkawrapper.DeadLetterError{Messages: dead, Err: errInvalidEvent}
+			}
+			return nil
+		}); err != nil {
+			log.Printf("config consumer stopped: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	return nil
+}
+
+// decodeConfigEvent reads a config event, gzipped when the content-encoding
+// header says so.
+func decodeConfigEvent(msg kafkawrapper.Message) (*model.ConfigEvent, error) {
+	var r io.Reader = bytes.NewReader(msg.Value)
+	if msg.Headers["content-encoding"] == "gzip" {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	ev := &model.ConfigEvent{}
+	if err := json.NewDecoder(r).Decode(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// handleEvents stores a batch of events and applies it to the orders
+// projection.
+// Both writes are idempotent, a batch delivered again leaves the tables as
+// they are.
+func (w *Worker) handleEvents(ctx context.Context, events []*model.OrderEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if _, err := w.orderEvent.BulkCreate(ctx, events); err != nil {
+		return dbError(err)
+	}
+	return dbError(w.order.BulkUpsert(ctx, latestRecords(events)))
+}
+
+// dbError marks err retryable unless the database refused the data itself,
+// an outage is waited out while a bad event ends in the DLQ. The consumer
+// retries a refused batch one event at a time to find it.
+func dbError(err error) error {
+	var sqlErr interface{ SQLState() string }
+	if errors.As(err, &sqlErr) && !transientSQLState(sqlErr.SQLState()) {
+		return err
+	}
+	return kafkawrapper.Retryable(err)
+}
+
+func transientSQLState(code string) bool {
+	switch {
+	case strings.HasPrefix(code, "08"), // connection exception
+		strings.HasPrefix(code, "53"), // insufficient resources
+		strings.HasPrefix(code, "57"), // operator intervention, server shutting down
+		code == "40001",               // serialization failure
+		code == "40P01":               // deadlock
+		return true
+	}
+	return false
+}
+
+// latestRecords keeps the event with the highest Seq of each order, events
+// of a batch may arrive out of order.
+func latestRecords(events []*model.OrderEvent) []*model.OrderRecord {
+	latest := make(map[string]*model.OrderEvent, len(events))
+	var orderIDs []string
+	for _, ev := range events {
+		prev, ok := latest[ev.OrderID]
+		if !ok {
+			orderIDs = append(orderIDs, ev.OrderID)
+		}
+		if !ok || ev.Seq > prev.Seq {
+			latest[ev.OrderID] = ev
+		}
+	}
+
+	records := make([]*model.OrderRecord, 0, len(orderIDs))
+	for _, orderID := range orderIDs {
+		records = append(records, model.NewOrderRecord(latest[orderID]))
+	}
+	return records
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	kafkawrapper "github.com/joripage/orderbook-dev/pkg/kafka_wrapper"
+	"github.com/joripage/orderbook-dev/pkg/oms/model"
+	"github.com/joripage/orderbook-dev/pkg/oms/repo"
+	"github.com/nats-io/nats.go"
+)
+
+func TestLatestRecordsOutOfOrder(t *testing.T) {
+	events := []*model.OrderEvent{
+		{OrderID: "O1", Seq: 2, OrderStatus: model.OrderStatusPartiallyFilled},
+		{OrderID: "O2", Seq: 1, OrderStatus: model.OrderStatusNew},
+		{OrderID: "O1", Seq: 3, OrderStatus: model.OrderStatusFilled},
+		{OrderID: "O1", Seq: 1, OrderStatus: model.OrderStatusNew},
+		// redelivered
+		{OrderID: "O1", Seq: 3, OrderStatus: model.OrderStatusFilled},
+	}
+
+	records := latestRecords(events)
+	if len(records) != 2 {
+		t.Fatalf("expected one record per order, got %d", len(records))
+	}
+	if r := records[0]; r.OrderID != "O1" || r.Seq != 3 || r.Status != model.OrderStatusFilled {
+		t.Fatalf("expected O1 filled at seq 3, got %+v", r)
+	}
+	if r := records[1]; r.OrderID != "O2" || r.Seq != 1 {
+		t.Fatalf("expected O2 at seq 1, got %+v", r)
+	}
+}
+
+type sqlError string
+
+func (e sqlError) Error() string    { return "sql error " + string(e) }
+func (e sqlError) SQLState() string { return string(e) }
+
+func TestDBErrorRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		retryable bool
+	}{
+		{errors.New("dial tcp: connection refused"), true},
+		{fmt.Errorf("insert: %w", sqlError("08006")), true},
+		{sqlError("57P01"), true},
+		{sqlError("40P01"), true},
+		{sqlError("22001"), false}, // value too long
+		{sqlError("23502"), false}, // not null violation
+	} {
+		if got := kafkawrapper.IsRetryable(dbError(tc.err)); got != tc.retryable {
+			t.Errorf("%v: expected retryable %v, got %v", tc.err, tc.retryable, got)
+		}
+	}
+	if dbError(nil) != nil {
+		t.Fatal("expected nil")
+	}
+}
+
+func TestDecodeConfigEvent(t *testing.T) {
+	ev := &model.ConfigEvent{Node: 3, Version: 2, Operator: "ops", Digest: "d", Files: map[string][]byte{"risk.yaml": []byte("rules: []")}}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_ = json.NewEncoder(zw).Encode(ev)
+	_ = zw.Close()
+
+	got, err := decodeConfigEvent(kafkawrapper.Message{Value: buf.Bytes(), Headers: map[string]string{"content-encoding": "gzip"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Node != 3 || got.Version != 2 || string(got.Files["risk.yaml"]) != "rules: []" {
+		t.Fatalf("unexpected event %+v", got)
+	}
+	if _, err := decodeConfigEvent(kafkawrapper.Message{Value: []byte("not json")}); err == nil {
+		t.Fatal("expected an invalid event to fail")
+	}
+}
+
+type fakeMsg struct {
+	delivered uint64
+	acked     string
+}
+
+func (m *fakeMsg) Ack(...nats.AckOpt) error  { m.acked = "ack"; return nil }
+func (m *fakeMsg) Nak(...nats.AckOpt) error  { m.acked = "nak"; return nil }
+func (m *fakeMsg) Term(...nats.AckOpt) error { m.acked = "term"; return nil }
+func (m *fakeMsg) Metadata() (*nats.MsgMetadata, error) {
+	return &nats.MsgMetadata{NumDelivered: m.delivered}, nil
+}
+
+// refusingEvents refuses the batches holding the order bad.
+type refusingEvents struct {
+	repo.IOrderEvent
+	bad string
+	err error
+}
+
+func (r *refusingEvents) BulkCreate(ctx context.Context, records []*model.OrderEvent) ([]*model.OrderEvent, error) {
+	for _, ev := range records {
+		if ev.OrderID == r.bad {
+			return nil, r.err
+		}
+	}
+	return records, nil
+}
+
+type noopOrders struct{ repo.IOrder }
+
+func (noopOrders) BulkUpsert(ctx context.Context, records []*model.OrderRecord) error { return nil }
+
+func TestStoreMsgsTerminatesRefusedEvents(t *testing.T) {
+	events := &refusingEvents{bad: "BAD", err: sqlError("22001")}
+	w := &Worker{order: noopOrders{}, orderEvent: events}
+	batch := []*model.OrderEvent{{OrderID: "O1", Seq: 1}, {OrderID: "BAD", Seq: 1}, {OrderID: "O2", Seq: 1}}
+
+	store := func(delivered uint64) []*fakeMsg {
+		msgs := []*fakeMsg{{delivered: delivered}, {delivered: delivered}, {delivered: delivered}}
+		w.storeMsgs(context.Background(), batch, []natsMsg{msgs[0], msgs[1], msgs[2]})
+		return msgs
+	}
+
+	// the rest of the batch is stored, the refused event comes back
+	if msgs := store(1); msgs[0].acked != "ack" || msgs[1].acked != "nak" || msgs[2].acked != "ack" {
+		t.Fatalf("expected only BAD redelivered, got %s %s %s", msgs[0].acked, msgs[1].acked, msgs[2].acked)
+	}
+	if msgs := store(natsMaxDeliveries); msgs[1].acked != "term" {
+		t.Fatalf("expected BAD terminated on its last delivery, got %s", msgs[1].acked)
+	}
+
+	// an outage is waited out
+	events.err = errors.New("dial tcp: connection refused")
+	if msgs := store(natsMaxDeliveries); msgs[0].acked != "nak" || msgs[1].acked != "nak" || msgs[2].acked != "nak" {
+		t.Fatalf("expected the batch redelivered, got %s %s %s", msgs[0].acked, msgs[1].acked, msgs[2].acked)
+	}
+}
@@ -0,0 +1,43 @@
+package orderbook
+
+import "sort"
+
+// PriceLevel is one level of the public market depth.
+type PriceLevel struct {
+	Price float64
+	Qty   int64
+	Count int
+}
+
+// depth returns up to levels price levels per side, best price first. Only
+// displayed quantity is published: hidden orders are left out and a price
+// holding only hidden orders does not appear.
+func (ob *orderBook) depth(levels int) (bids, asks []PriceLevel) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	return depthOf(ob.buyOrders, ob.buyHeap, levels), depthOf(ob.sellOrders, ob.sellHeap, levels)
+}
+
+func depthOf(book map[float64]*priceLevel, priceHeap *PriceHeap, levels int) []PriceLevel {
+	var depth []PriceLevel
+	for price, q := range book {
+		if q.displayed.Len() == 0 {
+			continue
+		}
+		depth = append(depth, PriceLevel{
+			Price: price,
+			Qty:   q.displayedQty(),
+			Count: q.displayed.Len(),
+		})
+	}
+
+	sort.Slice(depth, func(i, j int) bool {
+		return priceHeap.less(depth[i].Price, depth[j].Price)
+	})
+	if levels > 0 && len(depth) > levels {
+		depth = depth[:levels]
+	}
+
+	return depth
+}
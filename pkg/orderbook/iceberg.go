@@ -50,7 +50,7 @@ func (im *icebergManager) sliceOnce(order *Order) []*MatchResult {
 
 	slice := &Order{
 		ID:     order.ID + "-slice-" + time.Now().Format("150405"),
-		Symbol: order.Symbol, Side: order.Side, Price: order.Price,
+		Symbol: order.Symbol, Side: order.Side, Price: order.Price, ExactPrice: order.ExactPrice,
 		Qty: qty, Type: LIMIT, TimeInForce: GTC,
 	}
 	return im.book.addOrder(slice)
@@ -1,11 +1,15 @@
 package orderbook
 
+import "github.com/shopspring/decimal"
+
 type MatchResult struct {
 	// BuyOrderID  string
 	// SellOrderID string
 	OrderID        string
 	CounterOrderID string
 	Price          float64
-	Qty            int64
-	Side           Side
+	// ExactPrice is the fill price, the exact price of the resting order
+	ExactPrice decimal.Decimal
+	Qty        int64
+	Side       Side
 }
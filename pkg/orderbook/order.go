@@ -1,5 +1,7 @@
 package orderbook
 
+import "github.com/shopspring/decimal"
+
 type Side string
 
 const (
@@ -25,13 +27,33 @@ const (
 )
 
 type Order struct {
-	ID          string
-	Symbol      string
-	Side        Side
-	Price       float64
+	ID     string
+	Symbol string
+	Side   Side
+	Price  float64 // key of the price level
+	// ExactPrice is Price as sent by the OMS, fills and restatements report
+	// it. Zero takes Price as is.
+	ExactPrice  decimal.Decimal
 	Qty         int64
 	Type        OrderType
-	TimeInForce TimeInForce // IOC, FOK, GTC, etc.
-	VisibleQty  int64       // for Iceberg: public visible quantity
-	hiddenQty   int64       // for Iceberg: internal qty
+	TimeInForce TimeInForce     // IOC, FOK, GTC, etc.
+	VisibleQty  int64           // for Iceberg: public visible quantity
+	hiddenQty   int64           // for Iceberg: internal qty
+	Hidden      bool            // display quantity zero: never shown, matched after displayed orders
+	PegType     PegType         // for pegged order: reference price to follow
+	PegOffset   decimal.Decimal // for pegged order: added to the reference price
+}
+
+// exactPrice returns the decimal price the order rests at.
+func (o *Order) exactPrice() decimal.Decimal {
+	if !o.ExactPrice.IsZero() {
+		return o.ExactPrice
+	}
+	return decimal.NewFromFloat(o.Price)
+}
+
+// setPrice moves the order to price, the book keys it by its float value.
+func (o *Order) setPrice(price decimal.Decimal) {
+	o.Price = price.InexactFloat64()
+	o.ExactPrice = price
 }
@@ -7,7 +7,7 @@ import (
 	"math"
 	"sync"
 
-	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
 )
 
 type orderBooker interface {
@@ -28,8 +28,8 @@ type orderBookConfig struct {
 type orderBook struct {
 	symbol string
 
-	buyOrders  map[float64]*deque.Deque[*Order]
-	sellOrders map[float64]*deque.Deque[*Order]
+	buyOrders  map[float64]*priceLevel
+	sellOrders map[float64]*priceLevel
 
 	buyHeap  *PriceHeap
 	sellHeap *PriceHeap
@@ -38,7 +38,13 @@ type orderBook struct {
 
 	icebergMgr icebergHandler
 
-	callbacks []func([]*MatchResult)
+	// resting pegged orders in arrival order
+	pegs []*Order
+	// tick and band of the peg prices, nil leaves them as computed
+	priceRule PriceRule
+
+	callbacks        []func([]*MatchResult)
+	restateCallbacks []func([]*Restatement)
 
 	mu sync.Mutex
 }
@@ -53,8 +59,8 @@ func newOrderBook(symbol string) *orderBook {
 
 	ob := &orderBook{
 		symbol:     symbol,
-		buyOrders:  make(map[float64]*deque.Deque[*Order]),
-		sellOrders: make(map[float64]*deque.Deque[*Order]),
+		buyOrders:  make(map[float64]*priceLevel),
+		sellOrders: make(map[float64]*priceLevel),
 		buyHeap:    buyHeap,
 		sellHeap:   sellHeap,
 
@@ -70,10 +76,17 @@ func (ob *orderBook) setIcebergManager(im icebergHandler) {
 
 func (ob *orderBook) addOrder(order *Order) []*MatchResult {
 	ob.mu.Lock()
-	defer ob.mu.Unlock()
 
 	var results []*MatchResult
 
+	// a peg enters at the price checked by PegPrice, one without a price
+	// enters behind the opposite side like a repriced one
+	if order.PegType != "" && order.Price <= 0 {
+		if price, ok := ob.pegPrice(order.Side, order.PegType, order.PegOffset); ok {
+			order.setPrice(price)
+		}
+	}
+
 	switch order.Type {
 	case MARKET:
 		results = ob.executeMarket(order)
@@ -83,6 +96,14 @@ func (ob *orderBook) addOrder(order *Order) []*MatchResult {
 		results = ob.executeIceberg(order)
 	}
 
+	if order.PegType != "" && ob.ordersByID[order.ID] == order {
+		ob.pegs = append(ob.pegs, order)
+	}
+	restated := ob.repricePegs()
+	ob.mu.Unlock()
+
+	ob.notifyRestated(restated)
+
 	// if len(results) > 0 {
 	// 	for _, cb := range ob.callbacks {
 	// 		cb(results)
@@ -91,16 +112,35 @@ func (ob *orderBook) addOrder(order *Order) []*MatchResult {
 	return results
 }
 
-func (ob *orderBook) cancelOrder(orderID string) error {
+func (ob *orderBook) restoreOrder(order *Order) {
+	if order.Type == ICEBERG {
+		ob.executeIceberg(order)
+		return
+	}
+
 	ob.mu.Lock()
 	defer ob.mu.Unlock()
 
+	if order.Side == BUY {
+		ob.addToBook(ob.buyOrders, ob.buyHeap, order)
+	} else {
+		ob.addToBook(ob.sellOrders, ob.sellHeap, order)
+	}
+	if order.PegType != "" {
+		ob.pegs = append(ob.pegs, order)
+	}
+}
+
+func (ob *orderBook) cancelOrder(orderID string) error {
+	ob.mu.Lock()
+
 	order, ok := ob.ordersByID[orderID]
 	if !ok {
+		ob.mu.Unlock()
 		return errOrderNotFound
 	}
 
-	var book map[float64]*deque.Deque[*Order]
+	var book map[float64]*priceLevel
 	var heapRef *PriceHeap
 	if order.Side == BUY {
 		book = ob.buyOrders
@@ -110,13 +150,32 @@ func (ob *orderBook) cancelOrder(orderID string) error {
 		heapRef = ob.sellHeap
 	}
 
+	if book[order.Price] == nil {
+		ob.mu.Unlock()
+		return errInvalidOrderPrice
+	}
+
+	ob.removeFromBook(book, heapRef, order)
+	delete(ob.ordersByID, orderID)
+
+	restated := ob.repricePegs()
+	ob.mu.Unlock()
+
+	ob.notifyRestated(restated)
+
+	return nil
+}
+
+// removeFromBook takes order out of its price level, the price level is
+// deleted when it becomes empty.
+func (ob *orderBook) removeFromBook(book map[float64]*priceLevel, heapRef *PriceHeap, order *Order) {
 	q := book[order.Price]
 	if q == nil {
-		return errInvalidOrderPrice
+		return
 	}
 
 	for i := 0; i < q.Len(); i++ {
-		if q.At(i).ID == orderID {
+		if q.At(i).ID == order.ID {
 			q.Remove(i)
 			break
 		}
@@ -127,12 +186,9 @@ func (ob *orderBook) cancelOrder(orderID string) error {
 		delete(book, order.Price)
 		heapRef.Remove(order.Price)
 	}
-	delete(ob.ordersByID, orderID)
-
-	return nil
 }
 
-func (ob *orderBook) modifyOrder(orderID string, newPrice float64, newQty int64) ([]*MatchResult, error) {
+func (ob *orderBook) modifyOrder(orderID string, newPrice decimal.Decimal, newQty int64) ([]*MatchResult, error) {
 	ob.mu.Lock()
 
 	order, ok := ob.ordersByID[orderID]
@@ -141,7 +197,7 @@ func (ob *orderBook) modifyOrder(orderID string, newPrice float64, newQty int64)
 		return nil, errOrderNotFound
 	}
 
-	if order.Price == newPrice && newQty < order.Qty {
+	if order.exactPrice().Equal(newPrice) && newQty < order.Qty {
 		order.Qty = newQty
 		ob.mu.Unlock()
 		return nil, nil
@@ -158,10 +214,14 @@ func (ob *orderBook) modifyOrder(orderID string, newPrice float64, newQty int64)
 		ID:          order.ID,
 		Symbol:      order.Symbol,
 		Side:        order.Side,
-		Price:       newPrice,
+		Price:       newPrice.InexactFloat64(),
+		ExactPrice:  newPrice,
 		Qty:         newQty,
 		Type:        order.Type,
 		TimeInForce: order.TimeInForce,
+		Hidden:      order.Hidden,
+		PegType:     order.PegType,
+		PegOffset:   order.PegOffset,
 	}
 	results := ob.addOrder(newOrder)
 
@@ -183,7 +243,7 @@ func (ob *orderBook) executeMarket(order *Order) []*MatchResult {
 
 func (ob *orderBook) executeLimit(order *Order) []*MatchResult {
 	var results []*MatchResult
-	var sideBook, counterBook map[float64]*deque.Deque[*Order]
+	var sideBook, counterBook map[float64]*priceLevel
 	var sideHeap, counterHeap *PriceHeap
 	var priceCompare func(bookPrice, counterPrice float64) bool
 
@@ -202,6 +262,12 @@ func (ob *orderBook) executeLimit(order *Order) []*MatchResult {
 		priceCompare = func(bookPrice, counterPrice float64) bool { return bookPrice <= counterPrice }
 	}
 
+	// FOK: check the whole quantity can trade before touching the book
+	if order.TimeInForce == FOK &&
+		ob.availableQty(order.Price, counterBook, priceCompare, orderQty) < orderQty {
+		return nil
+	}
+
 	results = ob.matchOrder(
 		order,
 		counterBook,
@@ -210,22 +276,11 @@ func (ob *orderBook) executeLimit(order *Order) []*MatchResult {
 		order.Side,
 	)
 
-	if order.TimeInForce == IOC {
+	// IOC, FOK and market orders never rest
+	if order.TimeInForce == IOC || order.TimeInForce == FOK || order.Type == MARKET {
 		return results // don't save remaining qty
 	}
 
-	if order.TimeInForce == FOK {
-		total := int64(0)
-		for _, r := range results {
-			total += r.Qty
-		}
-		if total < orderQty {
-			// cancel all
-			return nil
-		}
-		return results
-	}
-
 	// GTC add remaining qty to order book
 	if order.Qty > 0 {
 		ob.addToBook(sideBook, sideHeap, order)
@@ -244,7 +299,7 @@ func (ob *orderBook) executeIceberg(order *Order) []*MatchResult {
 
 func (ob *orderBook) matchOrder(
 	order *Order,
-	counterBook map[float64]*deque.Deque[*Order],
+	counterBook map[float64]*priceLevel,
 	counterHeap *PriceHeap,
 	priceCompare func(bookPrice, counterPrice float64) bool,
 	side Side,
@@ -264,8 +319,9 @@ func (ob *orderBook) matchOrder(
 			continue
 		}
 
-		best := q.Front()
-		q.PopFront()
+		// displayed orders first, hidden orders only once the displayed
+		// quantity at this price is exhausted
+		best := q.PopFront()
 
 		matchQty := min(order.Qty, best.Qty)
 		order.Qty -= matchQty
@@ -276,6 +332,7 @@ func (ob *orderBook) matchOrder(
 			OrderID:        best.ID,
 			CounterOrderID: order.ID,
 			Price:          bestPrice,
+			ExactPrice:     best.exactPrice(),
 			Qty:            matchQty,
 			Side: map[Side]Side{
 				BUY:  SELL,
@@ -285,6 +342,12 @@ func (ob *orderBook) matchOrder(
 
 		if best.Qty > 0 {
 			q.PushFront(best)
+		} else {
+			delete(ob.ordersByID, best.ID)
+		}
+		if q.Len() == 0 {
+			heap.Pop(counterHeap)
+			delete(counterBook, bestPrice)
 		}
 
 		if order.Qty == 0 {
@@ -295,9 +358,33 @@ func (ob *orderBook) matchOrder(
 	return results
 }
 
-func (ob *orderBook) addToBook(book map[float64]*deque.Deque[*Order], priceHeap *PriceHeap, order *Order) {
+// availableQty returns the resting quantity tradable at price, counting
+// stops once qty is reached.
+func (ob *orderBook) availableQty(
+	price float64,
+	counterBook map[float64]*priceLevel,
+	priceCompare func(bookPrice, counterPrice float64) bool,
+	qty int64,
+) int64 {
+	total := int64(0)
+	for p, q := range counterBook {
+		if !priceCompare(price, p) {
+			continue
+		}
+		for i := 0; i < q.Len() && total < qty; i++ {
+			total += q.At(i).Qty
+		}
+		if total >= qty {
+			break
+		}
+	}
+
+	return total
+}
+
+func (ob *orderBook) addToBook(book map[float64]*priceLevel, priceHeap *PriceHeap, order *Order) {
 	if book[order.Price] == nil {
-		book[order.Price] = &deque.Deque[*Order]{}
+		book[order.Price] = &priceLevel{}
 		heap.Push(priceHeap, order.Price)
 	}
 	book[order.Price].PushBack(order)
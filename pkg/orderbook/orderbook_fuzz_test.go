@@ -0,0 +1,68 @@
+package orderbook
+
+import (
+	"container/heap"
+	"testing"
+)
+
+// FuzzOrderBook reads the input as steps of 6 bytes (op, side, kind, price,
+// qty, target) and checks every step against the reference model.
+func FuzzOrderBook(f *testing.F) {
+	f.Add([]byte{5, 0, 4, 5, 10, 0, 5, 1, 4, 5, 4, 0})
+	f.Add([]byte{5, 0, 3, 5, 10, 0, 5, 0, 4, 5, 10, 0, 5, 1, 2, 0, 15, 0})
+	f.Add([]byte{5, 1, 4, 5, 3, 0, 5, 0, 1, 8, 9, 0, 2, 0, 0, 2, 1, 0, 0, 0, 0, 0, 0, 1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h := newBookHarness()
+		for i := 0; i+6 <= len(data); i += 6 {
+			if err := h.step(data[i], data[i+1], data[i+2], data[i+3], data[i+4], data[i+5]); err != nil {
+				t.Fatalf("step %d: %v", i/6, err)
+			}
+		}
+	})
+}
+
+// FuzzPriceHeap checks the heap keeps its order and index under random push,
+// pop and remove.
+func FuzzPriceHeap(f *testing.F) {
+	f.Add([]byte{1, 5, 1, 3, 1, 9, 0, 0, 2, 5})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h := NewPriceHeap(func(i, j float64) bool { return i < j })
+		set := map[float64]bool{}
+		for i := 0; i+2 <= len(data); i += 2 {
+			price := float64(data[i+1] % 16)
+			switch data[i] % 3 {
+			case 0:
+				if h.Len() > 0 {
+					top, _ := h.Peek()
+					for p := range set {
+						if p < top {
+							t.Fatalf("peek %v above %v", top, p)
+						}
+					}
+					delete(set, heapPop(h))
+				}
+			case 1:
+				heapPush(h, price)
+				set[price] = true
+			case 2:
+				h.Remove(price)
+				delete(set, price)
+			}
+			if h.Len() != len(set) || len(h.index) != len(set) {
+				t.Fatalf("heap holds %d prices, want %d", h.Len(), len(set))
+			}
+		}
+	})
+}
+
+func heapPush(h *PriceHeap, price float64) {
+	if !h.index[price] {
+		heap.Push(h, price)
+	}
+}
+
+func heapPop(h *PriceHeap) float64 {
+	return heap.Pop(h).(float64)
+}
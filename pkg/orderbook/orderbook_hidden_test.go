@@ -0,0 +1,47 @@
+package orderbook
+
+import "testing"
+
+func TestDisplayedBeforeHiddenAtSamePrice(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.addOrder(&Order{ID: "H1", Side: SELL, Price: 100.0, Qty: 10, Type: LIMIT, Hidden: true})
+	ob.addOrder(&Order{ID: "D1", Side: SELL, Price: 100.0, Qty: 5, Type: LIMIT})
+
+	results := ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 100.0, Qty: 8, Type: LIMIT})
+	if len(results) != 2 || results[0].OrderID != "D1" || results[1].OrderID != "H1" {
+		t.Fatalf("expected D1 to match before H1, got %+v", results)
+	}
+	if q := ob.sellOrders[100.0]; q.Len() != 1 || q.Front().ID != "H1" || q.Front().Qty != 7 {
+		t.Fatalf("expected H1 resting with 7")
+	}
+}
+
+func TestHiddenBetterPriceMatchesFirst(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.addOrder(&Order{ID: "D1", Side: SELL, Price: 101.0, Qty: 5, Type: LIMIT})
+	ob.addOrder(&Order{ID: "H1", Side: SELL, Price: 100.0, Qty: 5, Type: LIMIT, Hidden: true})
+
+	results := ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 101.0, Qty: 5, Type: LIMIT})
+	if len(results) != 1 || results[0].OrderID != "H1" {
+		t.Fatalf("expected H1 to match on price, got %+v", results)
+	}
+}
+
+func TestDepthExcludesHidden(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 99.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "B2", Side: BUY, Price: 99.0, Qty: 20, Type: LIMIT, Hidden: true})
+	ob.addOrder(&Order{ID: "B3", Side: BUY, Price: 100.0, Qty: 5, Type: LIMIT, Hidden: true})
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: 101.0, Qty: 7, Type: LIMIT})
+
+	bids, asks := ob.depth(5)
+	if len(bids) != 1 || bids[0].Price != 99.0 || bids[0].Qty != 10 || bids[0].Count != 1 {
+		t.Fatalf("expected only displayed bid 99x10, got %+v", bids)
+	}
+	if len(asks) != 1 || asks[0].Qty != 7 {
+		t.Fatalf("unexpected asks %+v", asks)
+	}
+}
@@ -3,6 +3,8 @@ package orderbook
 import (
 	"sync"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type OrderBookManagerConfig struct {
@@ -10,9 +12,11 @@ type OrderBookManagerConfig struct {
 }
 
 type OrderBookManager struct {
-	books     sync.Map
-	callbacks []func([]*MatchResult)
-	cfg       *OrderBookManagerConfig
+	books            sync.Map
+	callbacks        []func([]*MatchResult)
+	restateCallbacks []func([]*Restatement)
+	priceRule        PriceRule
+	cfg              *OrderBookManagerConfig
 }
 
 func NewOrderBookManager(cfg *OrderBookManagerConfig) *OrderBookManager {
@@ -33,16 +37,30 @@ func (s *OrderBookManager) AddOrder(order *Order) []*MatchResult {
 	return results
 }
 
+// RestoreOrder puts a recovered order back on its book without matching,
+// orders restored at the same price keep the order they are restored in.
+func (s *OrderBookManager) RestoreOrder(order *Order) {
+	book := s.getOrCreateBook(order.Symbol)
+	book.restoreOrder(order)
+}
+
 func (s *OrderBookManager) CancelOrder(symbol, orderID string) error {
 	book := s.getOrCreateBook(symbol)
 	return book.cancelOrder(orderID)
 }
 
-func (s *OrderBookManager) ModifyOrder(symbol, orderID string, newPrice float64, newQty int64) ([]*MatchResult, error) {
+func (s *OrderBookManager) ModifyOrder(symbol, orderID string, newPrice decimal.Decimal, newQty int64) ([]*MatchResult, error) {
 	book := s.getOrCreateBook(symbol)
 	return book.modifyOrder(orderID, newPrice, newQty)
 }
 
+// ReplaceQuote atomically cancels the orders in cancelIDs and adds the new
+// quote sides, an empty orders list only pulls the quote.
+func (s *OrderBookManager) ReplaceQuote(symbol string, cancelIDs []string, orders ...*Order) []*MatchResult {
+	book := s.getOrCreateBook(symbol)
+	return book.replaceQuote(cancelIDs, orders)
+}
+
 func (s *OrderBookManager) RegisterTradeCallback(cb func([]*MatchResult)) {
 	s.callbacks = append(s.callbacks, cb)
 
@@ -54,15 +72,63 @@ func (s *OrderBookManager) RegisterTradeCallback(cb func([]*MatchResult)) {
 	})
 }
 
+// Depth returns the public market depth of symbol, hidden orders excluded.
+func (s *OrderBookManager) Depth(symbol string, levels int) (bids, asks []PriceLevel) {
+	book := s.getOrCreateBook(symbol)
+	return book.depth(levels)
+}
+
+// RegisterRestateCallback is called with the pegged orders repriced by a book.
+func (s *OrderBookManager) RegisterRestateCallback(cb func([]*Restatement)) {
+	s.restateCallbacks = append(s.restateCallbacks, cb)
+
+	// apply callback to all books
+	s.books.Range(func(_, v any) bool {
+		book := v.(*orderBook)
+		book.registerRestateCallback(cb)
+		return true
+	})
+}
+
+// SetPriceRule makes every book move its peg prices through rule, it is
+// meant to be set once before orders arrive.
+func (s *OrderBookManager) SetPriceRule(rule PriceRule) {
+	s.priceRule = rule
+
+	s.books.Range(func(_, v any) bool {
+		book := v.(*orderBook)
+		book.mu.Lock()
+		book.priceRule = rule
+		book.mu.Unlock()
+		return true
+	})
+}
+
+// PegPrice returns the current price of a pegged order, false when the book
+// has no reference price for it or the price would lock or cross the
+// opposite side.
+func (s *OrderBookManager) PegPrice(symbol string, side Side, pegType PegType, offset decimal.Decimal) (decimal.Decimal, bool) {
+	book := s.getOrCreateBook(symbol)
+
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	return book.pegPrice(side, pegType, offset)
+}
+
 func (s *OrderBookManager) getOrCreateBook(symbol string) *orderBook {
 	if val, ok := s.books.Load(symbol); ok {
 		return val.(*orderBook)
 	}
 
 	book := newOrderBook(symbol)
+	book.priceRule = s.priceRule
 	for _, cb := range s.callbacks {
 		book.registerTradeCallback(cb)
 	}
+	for _, cb := range s.restateCallbacks {
+		book.registerRestateCallback(cb)
+	}
 
 	if s.cfg.EnableIceberg {
 		im := newIcebergManager(book, time.Millisecond*1)
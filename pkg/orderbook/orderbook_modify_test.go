@@ -1,6 +1,10 @@
 package orderbook
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
 
 func TestCancelOrder(t *testing.T) {
 	ob := newOrderBook("test")
@@ -27,7 +31,7 @@ func TestModifyOrder_DecreaseQty(t *testing.T) {
 	}
 	ob.addOrder(order)
 
-	if _, err := ob.modifyOrder("1", 100, 5); err != nil {
+	if _, err := ob.modifyOrder("1", decimal.NewFromInt(100), 5); err != nil {
 		t.Fatalf("expected modify success")
 	}
 
@@ -48,7 +52,7 @@ func TestModifyOrder_IncreaseQty(t *testing.T) {
 	}
 	ob.addOrder(order)
 
-	if _, err := ob.modifyOrder("1", 100, 20); err != nil {
+	if _, err := ob.modifyOrder("1", decimal.NewFromInt(100), 20); err != nil {
 		t.Fatalf("expected modify success")
 	}
 
@@ -66,7 +70,7 @@ func TestModifyOrder_ChangePrice(t *testing.T) {
 	}
 	ob.addOrder(order)
 
-	if _, err := ob.modifyOrder("1", 105, 10); err != nil {
+	if _, err := ob.modifyOrder("1", decimal.NewFromInt(105), 10); err != nil {
 		t.Fatalf("expected modify success")
 	}
 
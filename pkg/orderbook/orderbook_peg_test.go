@@ -0,0 +1,154 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPrimaryPegFollowsBestBid(t *testing.T) {
+	ob := newOrderBook("test")
+	var restated []*Restatement
+	ob.registerRestateCallback(func(r []*Restatement) {
+		restated = append(restated, r...)
+	})
+
+	ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 100.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "P1", Side: BUY, Qty: 10, Type: LIMIT, PegType: PEG_PRIMARY})
+	if p := ob.ordersByID["P1"].Price; p != 100.0 {
+		t.Fatalf("expected peg at 100, got %f", p)
+	}
+
+	ob.addOrder(&Order{ID: "B2", Side: BUY, Price: 101.0, Qty: 10, Type: LIMIT})
+	if len(restated) != 1 || restated[0].OrderID != "P1" || !restated[0].Price.Equal(decimal.NewFromInt(101)) {
+		t.Fatalf("expected P1 restated to 101, got %+v", restated)
+	}
+	// repriced peg joins the back of the new level
+	if q := ob.buyOrders[101.0]; q.Len() != 2 || q.At(0).ID != "B2" || q.At(1).ID != "P1" {
+		t.Fatalf("expected B2 before P1 at 101")
+	}
+
+	// top of book back to 100
+	ob.cancelOrder("B2")
+	if len(restated) != 2 || !restated[1].Price.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("expected P1 restated back to 100, got %+v", restated)
+	}
+	if _, ok := ob.buyOrders[101.0]; ok {
+		t.Fatalf("expected level 101 removed")
+	}
+}
+
+func TestMidpointPeg(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 100.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: 110.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "P1", Side: BUY, Qty: 10, Type: LIMIT, PegType: PEG_MIDPOINT})
+	if p := ob.ordersByID["P1"].Price; p != 105.0 {
+		t.Fatalf("expected peg at 105, got %f", p)
+	}
+
+	// a sell between midpoint and ask matches the peg
+	results := ob.addOrder(&Order{ID: "S2", Side: SELL, Price: 104.0, Qty: 4, Type: LIMIT})
+	if len(results) != 1 || results[0].OrderID != "P1" || results[0].Price != 105.0 {
+		t.Fatalf("expected S2 to match P1 at 105, got %+v", results)
+	}
+}
+
+func TestPegKeepsPriorityWhenPriceUnchanged(t *testing.T) {
+	ob := newOrderBook("test")
+	restated := 0
+	ob.registerRestateCallback(func(r []*Restatement) {
+		restated += len(r)
+	})
+
+	ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 100.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "P1", Side: BUY, Qty: 10, Type: LIMIT, PegType: PEG_PRIMARY})
+	ob.addOrder(&Order{ID: "B2", Side: BUY, Price: 100.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: 110.0, Qty: 10, Type: LIMIT})
+
+	if restated != 0 {
+		t.Fatalf("expected no restatement, got %d", restated)
+	}
+	if q := ob.buyOrders[100.0]; q.At(1).ID != "P1" {
+		t.Fatalf("expected P1 to keep its place")
+	}
+}
+
+func TestMarketPegNeverTradesOnEntry(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: 110.0, Qty: 10, Type: LIMIT})
+	if _, ok := ob.pegPrice(BUY, PEG_MARKET, decimal.Zero); ok {
+		t.Fatal("expected no price for a peg locking the ask")
+	}
+	price, ok := ob.pegPrice(BUY, PEG_MARKET, decimal.NewFromInt(-1))
+	if !ok || !price.Equal(decimal.NewFromInt(109)) {
+		t.Fatalf("expected peg price 109, got %s %v", price, ok)
+	}
+
+	results := ob.addOrder(&Order{ID: "P1", Side: BUY, Price: price.InexactFloat64(), ExactPrice: price, Qty: 10, Type: LIMIT, PegType: PEG_MARKET, PegOffset: decimal.NewFromInt(-1)})
+	if len(results) != 0 || ob.ordersByID["P1"].Price != 109.0 {
+		t.Fatalf("expected P1 resting at 109, got %+v", results)
+	}
+}
+
+func TestReferencePriceSkipsPegOnlyLevels(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 100.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "B2", Side: BUY, Price: 99.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: 110.0, Qty: 10, Type: LIMIT})
+	// the midpoint peg is the best bid, alone at 105
+	ob.addOrder(&Order{ID: "P1", Side: BUY, Qty: 10, Type: LIMIT, PegType: PEG_MIDPOINT})
+	if best, _ := ob.buyHeap.Peek(); best != 105.0 {
+		t.Fatalf("expected best bid 105, got %f", best)
+	}
+
+	if price, ok := ob.referencePrice(BUY); !ok || !price.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("expected reference bid 100, got %s %v", price, ok)
+	}
+}
+
+func TestPegPriceFollowsPriceRule(t *testing.T) {
+	ob := newOrderBook("test")
+	// tick 2, buys rounded down, ceil 101
+	two, ceil := decimal.NewFromInt(2), decimal.NewFromInt(101)
+	ob.priceRule = func(symbol string, side Side, price decimal.Decimal) (decimal.Decimal, bool) {
+		price = price.Div(two).Floor().Mul(two)
+		return decimal.Min(price, ceil), true
+	}
+
+	ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 98.0, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: 105.0, Qty: 10, Type: LIMIT})
+	if price, ok := ob.pegPrice(BUY, PEG_MIDPOINT, decimal.Zero); !ok || !price.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("expected midpoint 101.5 rounded to 100, got %s %v", price, ok)
+	}
+	if price, ok := ob.pegPrice(BUY, PEG_PRIMARY, decimal.NewFromInt(5)); !ok || !price.Equal(ceil) {
+		t.Fatalf("expected primary 103 clamped to 101, got %s %v", price, ok)
+	}
+
+	// a peg keeps the price it is sent with
+	ob.addOrder(&Order{ID: "P1", Side: BUY, Price: 100.0, Qty: 10, Type: LIMIT, PegType: PEG_MIDPOINT})
+	if p := ob.ordersByID["P1"].Price; p != 100.0 {
+		t.Fatalf("expected peg at 100, got %f", p)
+	}
+}
+
+func TestPegAndFillPricesAreExact(t *testing.T) {
+	ob := newOrderBook("test")
+	bid, ask := decimal.RequireFromString("0.1"), decimal.RequireFromString("0.2")
+
+	ob.addOrder(&Order{ID: "B1", Side: BUY, Price: bid.InexactFloat64(), ExactPrice: bid, Qty: 10, Type: LIMIT})
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: ask.InexactFloat64(), ExactPrice: ask, Qty: 10, Type: LIMIT})
+	// (0.1 + 0.2) / 2 is 0.15000000000000002 in float64
+	ob.addOrder(&Order{ID: "P1", Side: BUY, Qty: 10, Type: LIMIT, PegType: PEG_MIDPOINT})
+	if p := ob.ordersByID["P1"].ExactPrice; !p.Equal(decimal.RequireFromString("0.15")) {
+		t.Fatalf("expected peg at 0.15, got %s", p)
+	}
+
+	results := ob.addOrder(&Order{ID: "S2", Side: SELL, Price: bid.InexactFloat64(), ExactPrice: bid, Qty: 4, Type: LIMIT})
+	if len(results) != 1 || results[0].OrderID != "P1" || !results[0].ExactPrice.Equal(decimal.RequireFromString("0.15")) {
+		t.Fatalf("expected S2 to match P1 at 0.15, got %+v", results)
+	}
+}
@@ -0,0 +1,521 @@
+package orderbook
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// refOrder is a resting order of the reference book.
+type refOrder struct {
+	id     string
+	side   Side
+	price  float64
+	qty    int64
+	hidden bool
+	seq    int
+}
+
+// refBook is the reference implementation the book is checked against: a
+// flat list of resting orders scanned on every step, priority is price, then
+// displayed before hidden, then arrival.
+type refBook struct {
+	orders []*refOrder
+	seq    int
+}
+
+func (b *refBook) before(a, c *refOrder) bool {
+	if a.price != c.price {
+		if a.side == BUY {
+			return a.price > c.price
+		}
+		return a.price < c.price
+	}
+	if a.hidden != c.hidden {
+		return !a.hidden
+	}
+	return a.seq < c.seq
+}
+
+func (b *refBook) find(id string) int {
+	for i, o := range b.orders {
+		if o.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// best returns the index of the best order of side crossing price, -1 when
+// none.
+func (b *refBook) best(side Side, price float64) int {
+	best := -1
+	for i, o := range b.orders {
+		if o.side != side {
+			continue
+		}
+		if (side == SELL && o.price > price) || (side == BUY && o.price < price) {
+			continue
+		}
+		if best < 0 || b.before(o, b.orders[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func (b *refBook) add(order *Order) []*MatchResult {
+	counterSide := SELL
+	if order.Side == SELL {
+		counterSide = BUY
+	}
+	price := order.Price
+	if order.Type == MARKET {
+		price = math.MaxFloat64
+		if order.Side == SELL {
+			price = 0
+		}
+	}
+
+	if order.TimeInForce == FOK {
+		available := int64(0)
+		for _, o := range b.orders {
+			if o.side == counterSide &&
+				((counterSide == SELL && o.price <= price) || (counterSide == BUY && o.price >= price)) {
+				available += o.qty
+			}
+		}
+		if available < order.Qty {
+			return nil
+		}
+	}
+
+	var results []*MatchResult
+	qty := order.Qty
+	for qty > 0 {
+		i := b.best(counterSide, price)
+		if i < 0 {
+			break
+		}
+		best := b.orders[i]
+		matchQty := min(qty, best.qty)
+		qty -= matchQty
+		best.qty -= matchQty
+		results = append(results, &MatchResult{
+			OrderID:        best.id,
+			CounterOrderID: order.ID,
+			Price:          best.price,
+			ExactPrice:     decimal.NewFromFloat(best.price),
+			Qty:            matchQty,
+			Side:           counterSide,
+		})
+		if best.qty == 0 {
+			b.orders = append(b.orders[:i], b.orders[i+1:]...)
+		}
+	}
+
+	if qty > 0 && order.Type != MARKET && order.TimeInForce != IOC && order.TimeInForce != FOK {
+		b.seq++
+		b.orders = append(b.orders, &refOrder{
+			id:     order.ID,
+			side:   order.Side,
+			price:  order.Price,
+			qty:    qty,
+			hidden: order.Hidden,
+			seq:    b.seq,
+		})
+	}
+
+	return results
+}
+
+func (b *refBook) cancel(id string) bool {
+	i := b.find(id)
+	if i < 0 {
+		return false
+	}
+	b.orders = append(b.orders[:i], b.orders[i+1:]...)
+	return true
+}
+
+// modify keeps priority only for a pure quantity decrease, like the book.
+func (b *refBook) modify(id string, price float64, qty int64) ([]*MatchResult, bool) {
+	i := b.find(id)
+	if i < 0 {
+		return nil, false
+	}
+	o := b.orders[i]
+	if o.price == price && qty < o.qty {
+		o.qty = qty
+		return nil, true
+	}
+
+	b.cancel(id)
+	return b.add(&Order{ID: id, Side: o.side, Price: price, Qty: qty, Type: LIMIT, TimeInForce: GTC, Hidden: o.hidden}), true
+}
+
+// snapshot lists the resting orders of side in priority order.
+func (b *refBook) snapshot(side Side) []string {
+	var orders []*refOrder
+	for _, o := range b.orders {
+		if o.side == side {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return b.before(orders[i], orders[j]) })
+
+	snapshot := make([]string, len(orders))
+	for i, o := range orders {
+		snapshot[i] = fmt.Sprintf("%s@%v:%d", o.id, o.price, o.qty)
+	}
+	return snapshot
+}
+
+func (b *refBook) restingQty() int64 {
+	total := int64(0)
+	for _, o := range b.orders {
+		total += o.qty
+	}
+	return total
+}
+
+// bookSnapshot lists the resting orders of side in matching order.
+func bookSnapshot(ob *orderBook, side Side) []string {
+	book, priceHeap := ob.buyOrders, ob.buyHeap
+	if side == SELL {
+		book, priceHeap = ob.sellOrders, ob.sellHeap
+	}
+	prices := make([]float64, 0, len(book))
+	for price := range book {
+		prices = append(prices, price)
+	}
+	sort.Slice(prices, func(i, j int) bool { return priceHeap.less(prices[i], prices[j]) })
+
+	var snapshot []string
+	for _, price := range prices {
+		q := book[price]
+		for i := 0; i < q.Len(); i++ {
+			o := q.At(i)
+			snapshot = append(snapshot, fmt.Sprintf("%s@%v:%d", o.ID, o.Price, o.Qty))
+		}
+	}
+	return snapshot
+}
+
+func bookRestingQty(ob *orderBook) int64 {
+	total := int64(0)
+	for _, o := range ob.ordersByID {
+		total += o.Qty
+	}
+	return total
+}
+
+// checkInvariants verifies the structure of the book:
+//   - best bid below best ask
+//   - every level is non-empty, stored in the heap once and holds orders of
+//     its price and side in the queue matching their display
+//   - ordersByID holds exactly the resting orders
+//   - heap order holds
+func checkInvariants(ob *orderBook) error {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	bid, hasBid := ob.buyHeap.Peek()
+	ask, hasAsk := ob.sellHeap.Peek()
+	if hasBid && hasAsk && bid >= ask {
+		return fmt.Errorf("crossed book bid=%v ask=%v", bid, ask)
+	}
+
+	resting := 0
+	for _, side := range []Side{BUY, SELL} {
+		book, priceHeap := ob.buyOrders, ob.buyHeap
+		if side == SELL {
+			book, priceHeap = ob.sellOrders, ob.sellHeap
+		}
+
+		if len(priceHeap.prices) != len(book) || len(priceHeap.index) != len(book) {
+			return fmt.Errorf("%s heap has %d prices for %d levels", side, len(priceHeap.prices), len(book))
+		}
+		for i, price := range priceHeap.prices {
+			if book[price] == nil {
+				return fmt.Errorf("%s heap price %v has no level", side, price)
+			}
+			for _, child := range []int{2*i + 1, 2*i + 2} {
+				if child < len(priceHeap.prices) && priceHeap.less(priceHeap.prices[child], price) {
+					return fmt.Errorf("%s heap order broken at %v", side, price)
+				}
+			}
+		}
+
+		for price, q := range book {
+			if q.Len() == 0 {
+				return fmt.Errorf("%s empty level %v", side, price)
+			}
+			for i := 0; i < q.Len(); i++ {
+				o := q.At(i)
+				if o.Price != price || o.Side != side || o.Qty <= 0 {
+					return fmt.Errorf("%s bad order %+v at level %v", side, o, price)
+				}
+				if o.Hidden != (i >= q.displayed.Len()) {
+					return fmt.Errorf("order %s in the wrong display queue", o.ID)
+				}
+				if ob.ordersByID[o.ID] != o {
+					return fmt.Errorf("order %s missing from ordersByID", o.ID)
+				}
+				resting++
+			}
+		}
+	}
+
+	if resting != len(ob.ordersByID) {
+		return fmt.Errorf("ordersByID has %d orders, levels hold %d", len(ob.ordersByID), resting)
+	}
+
+	return nil
+}
+
+func sameResults(a, b []*MatchResult) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := *a[i], *b[i]
+		if !x.ExactPrice.Equal(y.ExactPrice) {
+			return false
+		}
+		x.ExactPrice, y.ExactPrice = decimal.Zero, decimal.Zero
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSnapshot(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// bookHarness applies the same steps to the book and the reference.
+type bookHarness struct {
+	ob   *orderBook
+	ref  *refBook
+	ids  []string
+	next int
+}
+
+func newBookHarness() *bookHarness {
+	return &bookHarness{
+		ob:  newOrderBook("test"),
+		ref: &refBook{},
+	}
+}
+
+func (h *bookHarness) add(side Side, orderType OrderType, tif TimeInForce, price float64, qty int64, hidden bool) error {
+	h.next++
+	id := fmt.Sprintf("O%d", h.next)
+	newOrder := func() *Order {
+		return &Order{ID: id, Side: side, Price: price, Qty: qty, Type: orderType, TimeInForce: tif, Hidden: hidden}
+	}
+
+	before := bookRestingQty(h.ob)
+	want := h.ref.add(newOrder())
+	got := h.ob.addOrder(newOrder())
+	if !sameResults(got, want) {
+		return fmt.Errorf("add %s: results %v, reference %v", id, got, want)
+	}
+
+	// conservation: traded quantity leaves the book once on the resting
+	// side, the remainder of the incoming order rests or is dropped
+	traded := int64(0)
+	for _, r := range got {
+		traded += r.Qty
+	}
+	rested := int64(0)
+	if o, ok := h.ob.ordersByID[id]; ok {
+		rested = o.Qty
+	}
+	if traded > qty || (rested != 0 && rested != qty-traded) {
+		return fmt.Errorf("add %s: qty %d traded %d rested %d", id, qty, traded, rested)
+	}
+	if after := bookRestingQty(h.ob); after != before-traded+rested {
+		return fmt.Errorf("add %s: resting qty %d -> %d, traded %d rested %d", id, before, after, traded, rested)
+	}
+
+	h.ids = append(h.ids, id)
+	return h.check()
+}
+
+func (h *bookHarness) cancel(i int) error {
+	if len(h.ids) == 0 {
+		return nil
+	}
+	id := h.ids[i%len(h.ids)]
+	want := h.ref.cancel(id)
+	got := h.ob.cancelOrder(id) == nil
+	if got != want {
+		return fmt.Errorf("cancel %s: book %v, reference %v", id, got, want)
+	}
+	return h.check()
+}
+
+func (h *bookHarness) modify(i int, price float64, qty int64) error {
+	if len(h.ids) == 0 {
+		return nil
+	}
+	id := h.ids[i%len(h.ids)]
+	want, wantOK := h.ref.modify(id, price, qty)
+	got, err := h.ob.modifyOrder(id, decimal.NewFromFloat(price), qty)
+	if (err == nil) != wantOK || !sameResults(got, want) {
+		return fmt.Errorf("modify %s: results %v err=%v, reference %v", id, got, err, want)
+	}
+	return h.check()
+}
+
+func (h *bookHarness) check() error {
+	if err := checkInvariants(h.ob); err != nil {
+		return err
+	}
+	for _, side := range []Side{BUY, SELL} {
+		got, want := bookSnapshot(h.ob, side), h.ref.snapshot(side)
+		if !sameSnapshot(got, want) {
+			return fmt.Errorf("%s book %v, reference %v", side, got, want)
+		}
+	}
+	if got, want := bookRestingQty(h.ob), h.ref.restingQty(); got != want {
+		return fmt.Errorf("resting qty %d, reference %d", got, want)
+	}
+	return nil
+}
+
+// step decodes one random step from the values of a generator, shared by
+// the randomized test and the fuzz targets.
+func (h *bookHarness) step(op, side, kind, price, qty, target uint8) error {
+	s := BUY
+	if side%2 == 1 {
+		s = SELL
+	}
+	p := 95.0 + float64(price%11)
+	q := int64(qty%20) + 1
+
+	switch op % 10 {
+	case 0, 1:
+		return h.cancel(int(target))
+	case 2, 3:
+		return h.modify(int(target), p, q)
+	default:
+		switch kind % 8 {
+		case 0:
+			return h.add(s, LIMIT, IOC, p, q, false)
+		case 1:
+			return h.add(s, LIMIT, FOK, p, q, false)
+		case 2:
+			return h.add(s, MARKET, IOC, 0, q, false)
+		case 3:
+			return h.add(s, LIMIT, GTC, p, q, true)
+		default:
+			return h.add(s, LIMIT, GTC, p, q, false)
+		}
+	}
+}
+
+func TestOrderBookMatchesReferenceModel(t *testing.T) {
+	for seed := int64(1); seed <= 50; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		h := newBookHarness()
+		for i := 0; i < 500; i++ {
+			err := h.step(uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)),
+				uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)))
+			if err != nil {
+				t.Fatalf("seed %d step %d: %v", seed, i, err)
+			}
+		}
+	}
+}
+
+// TestConcurrentOrdersKeepInvariants runs the concurrent path of
+// TestConcurrentOrders with crossing prices, cancels and depth reads, then
+// checks the book and the traded quantity.
+func TestConcurrentOrdersKeepInvariants(t *testing.T) {
+	ob := newOrderBook("test")
+
+	var mu sync.Mutex
+	traded := int64(0)
+	submitted := map[Side]int64{}
+	canceled := map[Side]int64{}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			orders := make([]*Order, 0, 500)
+			for i := 0; i < 500; i++ {
+				side := BUY
+				if rnd.Intn(2) == 1 {
+					side = SELL
+				}
+				qty := int64(rnd.Intn(10) + 1)
+				order := &Order{
+					ID:    fmt.Sprintf("W%d-%d", w, i),
+					Side:  side,
+					Price: 98.0 + float64(rnd.Intn(5)),
+					Qty:   qty,
+					Type:  LIMIT,
+				}
+				orders = append(orders, order)
+				results := ob.addOrder(order)
+
+				mu.Lock()
+				submitted[side] += qty
+				for _, r := range results {
+					traded += r.Qty
+				}
+				mu.Unlock()
+
+				// cancel an earlier order of this worker, once off the book
+				// its quantity no longer changes
+				if i%7 == 3 {
+					target := orders[rnd.Intn(len(orders))]
+					if ob.cancelOrder(target.ID) == nil {
+						mu.Lock()
+						canceled[target.Side] += target.Qty
+						mu.Unlock()
+					}
+				}
+
+				if i%10 == 0 {
+					ob.depth(5)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if err := checkInvariants(ob); err != nil {
+		t.Fatal(err)
+	}
+	resting := map[Side]int64{}
+	for _, o := range ob.ordersByID {
+		resting[o.Side] += o.Qty
+	}
+	for _, side := range []Side{BUY, SELL} {
+		if submitted[side] != traded+resting[side]+canceled[side] {
+			t.Fatalf("%s submitted %d, traded %d resting %d canceled %d",
+				side, submitted[side], traded, resting[side], canceled[side])
+		}
+	}
+}
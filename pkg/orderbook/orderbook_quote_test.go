@@ -0,0 +1,49 @@
+package orderbook
+
+import "testing"
+
+func TestReplaceQuoteSwapsBothSides(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.replaceQuote(nil, []*Order{
+		{ID: "Q1B", Side: BUY, Price: 99.0, Qty: 10, Type: LIMIT},
+		{ID: "Q1S", Side: SELL, Price: 101.0, Qty: 10, Type: LIMIT},
+	})
+	ob.replaceQuote([]string{"Q1B", "Q1S"}, []*Order{
+		{ID: "Q2B", Side: BUY, Price: 98.0, Qty: 5, Type: LIMIT},
+		{ID: "Q2S", Side: SELL, Price: 102.0, Qty: 5, Type: LIMIT},
+	})
+
+	bids, asks := ob.depth(5)
+	if len(bids) != 1 || bids[0].Price != 98.0 || bids[0].Qty != 5 {
+		t.Fatalf("expected bid 98x5, got %+v", bids)
+	}
+	if len(asks) != 1 || asks[0].Price != 102.0 || asks[0].Qty != 5 {
+		t.Fatalf("expected ask 102x5, got %+v", asks)
+	}
+	if _, ok := ob.ordersByID["Q1B"]; ok {
+		t.Fatalf("expected Q1B removed")
+	}
+}
+
+func TestReplaceQuoteTradesAgainstBook(t *testing.T) {
+	ob := newOrderBook("test")
+	ob.addOrder(&Order{ID: "S1", Side: SELL, Price: 100.0, Qty: 4, Type: LIMIT})
+
+	results := ob.replaceQuote(nil, []*Order{
+		{ID: "QB", Side: BUY, Price: 100.0, Qty: 10, Type: LIMIT},
+		{ID: "QS", Side: SELL, Price: 101.0, Qty: 10, Type: LIMIT},
+	})
+	if len(results) != 1 || results[0].OrderID != "S1" || results[0].Qty != 4 {
+		t.Fatalf("expected quote bid to take S1, got %+v", results)
+	}
+	if q := ob.buyOrders[100.0]; q == nil || q.Front().Qty != 6 {
+		t.Fatalf("expected bid remainder 6 resting")
+	}
+
+	// pulling the quote leaves an empty book
+	ob.replaceQuote([]string{"QB", "QS"}, nil)
+	if bids, asks := ob.depth(0); len(bids) != 0 || len(asks) != 0 {
+		t.Fatalf("expected empty book, got %+v %+v", bids, asks)
+	}
+}
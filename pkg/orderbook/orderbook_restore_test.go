@@ -0,0 +1,29 @@
+package orderbook
+
+import "testing"
+
+func TestRestoreOrderDoesNotMatch(t *testing.T) {
+	ob := newOrderBook("test")
+
+	// a crossed book only comes back from a bad log, restore must not trade it
+	ob.restoreOrder(&Order{ID: "B1", Side: BUY, Price: 101.0, Qty: 10, Type: LIMIT})
+	ob.restoreOrder(&Order{ID: "S1", Side: SELL, Price: 100.0, Qty: 10, Type: LIMIT})
+	if ob.ordersByID["B1"] == nil || ob.ordersByID["S1"] == nil {
+		t.Fatal("expected both orders resting")
+	}
+}
+
+func TestRestoreOrderKeepsPriority(t *testing.T) {
+	ob := newOrderBook("test")
+
+	ob.restoreOrder(&Order{ID: "S1", Side: SELL, Price: 100.0, Qty: 5, Type: LIMIT})
+	ob.restoreOrder(&Order{ID: "S2", Side: SELL, Price: 100.0, Qty: 5, Type: LIMIT})
+
+	results := ob.addOrder(&Order{ID: "B1", Side: BUY, Price: 100.0, Qty: 7, Type: LIMIT})
+	if len(results) != 2 || results[0].OrderID != "S1" || results[0].Qty != 5 || results[1].OrderID != "S2" || results[1].Qty != 2 {
+		t.Fatalf("expected S1 then S2, got %+v", results)
+	}
+	if q := ob.sellOrders[100.0]; q.Len() != 1 || q.Front().Qty != 3 {
+		t.Fatal("expected S2 resting with 3")
+	}
+}
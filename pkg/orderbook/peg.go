@@ -0,0 +1,179 @@
+package orderbook
+
+import "github.com/shopspring/decimal"
+
+// Pegged orders are limit orders whose price follows a reference price of
+// the book plus PegOffset:
+//   - PEG_PRIMARY:  best price of the same side
+//   - PEG_MARKET:   best price of the opposite side
+//   - PEG_MIDPOINT: middle of best bid and best ask
+//
+// Reference prices only look at non-pegged orders so pegs never follow each
+// other, peg prices are computed on the exact prices of these orders. Pegs are repriced after every change of the book with these
+// priority rules:
+//   - pegs are repriced in arrival order
+//   - a peg whose price does not change keeps its place in the queue
+//   - a repriced peg loses time priority and joins the back of the queue at
+//     its new price level
+//   - pegs never trade on their own price: a new price that would lock or
+//     cross the opposite side is skipped and the peg stays at its previous
+//     price, a new peg has no price until it would rest behind the opposite
+//     side
+type PegType string
+
+const (
+	PEG_PRIMARY  PegType = "PEG_PRIMARY"
+	PEG_MARKET   PegType = "PEG_MARKET"
+	PEG_MIDPOINT PegType = "PEG_MIDPOINT"
+)
+
+// PriceRule moves a price computed by the book onto a price symbol takes,
+// such as its tick and its ceil/floor band, false when there is none.
+type PriceRule func(symbol string, side Side, price decimal.Decimal) (decimal.Decimal, bool)
+
+// Restatement reports an order repriced by the book.
+type Restatement struct {
+	OrderID string
+	Price   decimal.Decimal
+	Qty     int64
+}
+
+// referencePrice returns the best price of side among displayed non-pegged
+// orders, levels are visited from the best one on.
+func (ob *orderBook) referencePrice(side Side) (decimal.Decimal, bool) {
+	book, priceHeap := ob.buyOrders, ob.buyHeap
+	if side == SELL {
+		book, priceHeap = ob.sellOrders, ob.sellHeap
+	}
+
+	var best decimal.Decimal
+	found := false
+	priceHeap.Ascend(func(price float64) bool {
+		q := book[price]
+		if q == nil {
+			return true
+		}
+		for i := 0; i < q.displayed.Len(); i++ {
+			if order := q.displayed.At(i); order.PegType == "" {
+				best, found = order.exactPrice(), true
+				return false
+			}
+		}
+		return true
+	})
+
+	return best, found
+}
+
+func (ob *orderBook) pegPrice(side Side, pegType PegType, offset decimal.Decimal) (decimal.Decimal, bool) {
+	bid, hasBid := ob.referencePrice(BUY)
+	ask, hasAsk := ob.referencePrice(SELL)
+
+	var price decimal.Decimal
+	switch pegType {
+	case PEG_PRIMARY:
+		if side == BUY {
+			if !hasBid {
+				return decimal.Zero, false
+			}
+			price = bid
+		} else {
+			if !hasAsk {
+				return decimal.Zero, false
+			}
+			price = ask
+		}
+	case PEG_MARKET:
+		if side == BUY {
+			if !hasAsk {
+				return decimal.Zero, false
+			}
+			price = ask
+		} else {
+			if !hasBid {
+				return decimal.Zero, false
+			}
+			price = bid
+		}
+	case PEG_MIDPOINT:
+		if !hasBid || !hasAsk {
+			return decimal.Zero, false
+		}
+		price = bid.Add(ask).Div(decimal.NewFromInt(2))
+	default:
+		return decimal.Zero, false
+	}
+
+	price = price.Add(offset)
+	if ob.priceRule != nil {
+		var ok bool
+		if price, ok = ob.priceRule(ob.symbol, side, price); !ok {
+			return decimal.Zero, false
+		}
+	}
+	if !price.IsPositive() {
+		return decimal.Zero, false
+	}
+	// the price must stay strictly behind the opposite best
+	counterHeap := ob.sellHeap
+	if side == SELL {
+		counterHeap = ob.buyHeap
+	}
+	if counterPrice, ok := counterHeap.Peek(); ok && !counterHeap.less(price.InexactFloat64(), counterPrice) {
+		return decimal.Zero, false
+	}
+	return price, true
+}
+
+// repricePegs moves every resting peg to its current reference price and
+// returns the restated orders.
+func (ob *orderBook) repricePegs() []*Restatement {
+	if len(ob.pegs) == 0 {
+		return nil
+	}
+
+	var restated []*Restatement
+	pegs := ob.pegs[:0]
+	for _, order := range ob.pegs {
+		// drop filled or canceled pegs
+		if ob.ordersByID[order.ID] != order || order.Qty == 0 {
+			continue
+		}
+		pegs = append(pegs, order)
+
+		price, ok := ob.pegPrice(order.Side, order.PegType, order.PegOffset)
+		if !ok || price.Equal(order.exactPrice()) {
+			continue
+		}
+
+		book, priceHeap := ob.buyOrders, ob.buyHeap
+		if order.Side == SELL {
+			book, priceHeap = ob.sellOrders, ob.sellHeap
+		}
+
+		ob.removeFromBook(book, priceHeap, order)
+		order.setPrice(price)
+		ob.addToBook(book, priceHeap, order)
+		restated = append(restated, &Restatement{
+			OrderID: order.ID,
+			Price:   price,
+			Qty:     order.Qty,
+		})
+	}
+	ob.pegs = pegs
+
+	return restated
+}
+
+func (ob *orderBook) registerRestateCallback(fn func([]*Restatement)) {
+	ob.restateCallbacks = append(ob.restateCallbacks, fn)
+}
+
+func (ob *orderBook) notifyRestated(restated []*Restatement) {
+	if len(restated) == 0 {
+		return
+	}
+	for _, cb := range ob.restateCallbacks {
+		cb(restated)
+	}
+}
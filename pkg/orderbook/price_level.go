@@ -0,0 +1,70 @@
+package orderbook
+
+import "github.com/gammazero/deque"
+
+// priceLevel keeps the orders resting at one price. Displayed orders have
+// priority over hidden (display quantity zero) orders at the same price,
+// time priority applies inside each queue. Index i walks the displayed
+// queue first, then the hidden one.
+type priceLevel struct {
+	displayed deque.Deque[*Order]
+	hidden    deque.Deque[*Order]
+}
+
+func (l *priceLevel) queue(order *Order) *deque.Deque[*Order] {
+	if order.Hidden {
+		return &l.hidden
+	}
+	return &l.displayed
+}
+
+func (l *priceLevel) Len() int {
+	return l.displayed.Len() + l.hidden.Len()
+}
+
+func (l *priceLevel) At(i int) *Order {
+	if i < l.displayed.Len() {
+		return l.displayed.At(i)
+	}
+	return l.hidden.At(i - l.displayed.Len())
+}
+
+func (l *priceLevel) Remove(i int) *Order {
+	if i < l.displayed.Len() {
+		return l.displayed.Remove(i)
+	}
+	return l.hidden.Remove(i - l.displayed.Len())
+}
+
+// Front returns the order with the highest priority.
+func (l *priceLevel) Front() *Order {
+	if l.displayed.Len() > 0 {
+		return l.displayed.Front()
+	}
+	return l.hidden.Front()
+}
+
+func (l *priceLevel) PopFront() *Order {
+	if l.displayed.Len() > 0 {
+		return l.displayed.PopFront()
+	}
+	return l.hidden.PopFront()
+}
+
+// PushFront puts a partially matched order back at the head of its queue.
+func (l *priceLevel) PushFront(order *Order) {
+	l.queue(order).PushFront(order)
+}
+
+func (l *priceLevel) PushBack(order *Order) {
+	l.queue(order).PushBack(order)
+}
+
+// displayedQty is the quantity shown in depth for this price.
+func (l *priceLevel) displayedQty() int64 {
+	qty := int64(0)
+	for i := 0; i < l.displayed.Len(); i++ {
+		qty += l.displayed.At(i).Qty
+	}
+	return qty
+}
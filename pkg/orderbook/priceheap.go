@@ -52,6 +52,38 @@ func (h *PriceHeap) Peek() (float64, bool) {
 	return h.prices[0], true
 }
 
+// Ascend calls fn with the prices of the heap from the best one on until fn
+// returns false. Only the visited part of the heap is explored, stopping at
+// the first price costs as much as Peek.
+func (h *PriceHeap) Ascend(fn func(price float64) bool) {
+	if len(h.prices) == 0 {
+		return
+	}
+
+	// heap indexes whose parents were visited
+	next := []int{0}
+	for len(next) > 0 {
+		best := 0
+		for i := 1; i < len(next); i++ {
+			if h.less(h.prices[next[i]], h.prices[next[best]]) {
+				best = i
+			}
+		}
+		i := next[best]
+		next[best] = next[len(next)-1]
+		next = next[:len(next)-1]
+
+		if !fn(h.prices[i]) {
+			return
+		}
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(h.prices) {
+				next = append(next, child)
+			}
+		}
+	}
+}
+
 func (h *PriceHeap) Remove(price float64) {
 	for i := range h.prices {
 		if h.prices[i] == price {
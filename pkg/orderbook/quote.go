@@ -0,0 +1,33 @@
+package orderbook
+
+// replaceQuote swaps the resting sides of a two-sided quote in one step: the
+// orders in cancelIDs leave the book and the new sides are matched and
+// rested under the same lock, so no incoming order ever sees half a quote.
+func (ob *orderBook) replaceQuote(cancelIDs []string, orders []*Order) []*MatchResult {
+	ob.mu.Lock()
+
+	for _, id := range cancelIDs {
+		order, ok := ob.ordersByID[id]
+		if !ok {
+			continue
+		}
+		if order.Side == BUY {
+			ob.removeFromBook(ob.buyOrders, ob.buyHeap, order)
+		} else {
+			ob.removeFromBook(ob.sellOrders, ob.sellHeap, order)
+		}
+		delete(ob.ordersByID, id)
+	}
+
+	var results []*MatchResult
+	for _, order := range orders {
+		results = append(results, ob.executeLimit(order)...)
+	}
+
+	restated := ob.repricePegs()
+	ob.mu.Unlock()
+
+	ob.notifyRestated(restated)
+
+	return results
+}